@@ -53,7 +53,7 @@ func rootPath() string {
 }
 
 func schemaPath() string {
-	return path.Join(rootPath(), "../schema.json")
+	return path.Join(rootPath(), "../schema/schema.json")
 }
 
 func corpusRootPath() string {
@@ -0,0 +1,80 @@
+// vectorfmt converts test vectors authored in YAML to canonical JSON.
+//
+// Usage:
+//
+//	vectorfmt [-check] <vector.yaml>...
+//
+// Each input is validated and written next to it, replacing the .yaml (or
+// .yml) extension with .json. With -check, nothing is written; instead,
+// vectorfmt fails if any .json file is missing or not in canonical form, which
+// is meant to be run in CI over contributor submissions.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+func main() {
+	check := flag.Bool("check", false, "check that the json files are up to date, instead of writing them")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-check] <vector.yaml>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var failed bool
+	for _, path := range flag.Args() {
+		if err := format(path, *check); err != nil {
+			fmt.Printf("❌ %s: %s\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("✅ %s\n", path)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func format(path string, check bool) error {
+	ext := filepath.Ext(path)
+	if ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("not a yaml file")
+	}
+	out := strings.TrimSuffix(path, ext) + ".json"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	if err := schema.ConvertYAMLToJSON(f, &buf); err != nil {
+		return err
+	}
+
+	if !check {
+		return ioutil.WriteFile(out, buf.Bytes(), 0644)
+	}
+	existing, err := ioutil.ReadFile(out)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(existing, buf.Bytes()) {
+		return fmt.Errorf("%s is not up to date; run vectorfmt", out)
+	}
+	return nil
+}
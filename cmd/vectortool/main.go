@@ -0,0 +1,176 @@
+// vectortool inspects, validates and formats test vectors.
+//
+// Usage:
+//
+//	vectortool validate [<vector.json>]
+//	vectortool inspect [<vector.json>]
+//	vectortool fmt [<vector.json>]
+//
+// validate checks the vector against the JSON Schema, and runs
+// TestVector.Validate on it, printing the errors it finds. inspect prints a
+// summary of the vector: its class, id, epoch range, message count and size
+// stats. fmt rewrites the vector as canonical JSON (see schema.WriteIndented).
+//
+// Each subcommand reads the vector from stdin when no file is given, in which
+// case fmt writes the result to stdout rather than in place. Gzipped vectors
+// are accepted too; fmt keeps files ending in .gz compressed.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+// errInvalid is returned by validate for invalid vectors, once it has reported
+// them.
+var errInvalid = errors.New("invalid test vector")
+
+var commands = map[string]func(path string, raw []byte) error{
+	"validate": validate,
+	"inspect":  inspect,
+	"fmt":      format,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s validate|inspect|fmt [<vector.json>]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+
+	var path string
+	if len(os.Args) == 3 {
+		path = os.Args[2]
+	}
+	raw, err := read(path)
+	if err == nil {
+		err = cmd(path, raw)
+	}
+	if err != nil {
+		if !errors.Is(err, errInvalid) {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name(path), err)
+		}
+		os.Exit(1)
+	}
+}
+
+// name returns the name of the input for messages.
+func name(path string) string {
+	if path == "" {
+		return "<stdin>"
+	}
+	return path
+}
+
+// read reads the vector at path, or on stdin if path is empty, decompressing
+// it if it's gzipped.
+func read(path string) ([]byte, error) {
+	var r io.Reader = os.Stdin
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(raw, []byte{0x1f, 0x8b}) {
+		return raw, nil
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip stream: %w", err)
+	}
+	return ioutil.ReadAll(gr)
+}
+
+func validate(path string, raw []byte) error {
+	err := schema.SchemaValidate(raw)
+	if err == nil {
+		_, err = schema.LoadTestVector(bytes.NewReader(raw))
+	}
+	if err != nil {
+		fmt.Printf("❌ %s\n\t- %s\n", name(path), err)
+		return errInvalid
+	}
+	fmt.Printf("✅ %s\n", name(path))
+	return nil
+}
+
+func inspect(path string, raw []byte) error {
+	tv, err := schema.LoadTestVector(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	id := "-"
+	if tv.Meta != nil && tv.Meta.ID != "" {
+		id = tv.Meta.ID
+	}
+	epochs := "-"
+	if min, max, ok := tv.EpochRange(); ok {
+		epochs = fmt.Sprintf("%d to %d", min, max)
+	}
+	s := tv.SizeStats()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "class:\t%s\n", tv.Class)
+	fmt.Fprintf(w, "id:\t%s\n", id)
+	fmt.Fprintf(w, "epochs:\t%s\n", epochs)
+	fmt.Fprintf(w, "messages:\t%d\n", tv.MessageCount())
+	fmt.Fprintf(w, "size:\t%d bytes\n", s.Total())
+	fmt.Fprintf(w, "  car:\t%d bytes\n", s.CAR)
+	fmt.Fprintf(w, "  messages:\t%d bytes\n", s.Messages)
+	fmt.Fprintf(w, "  diagnostics:\t%d bytes\n", s.Diagnostics)
+	fmt.Fprintf(w, "  other:\t%d bytes\n", s.Other)
+	fmt.Fprintf(w, "  json overhead:\t%d bytes\n", s.JSONOverhead)
+	return w.Flush()
+}
+
+func format(path string, raw []byte) error {
+	tv, err := schema.LoadTestVector(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := schema.WriteIndented(&buf, tv); err != nil {
+		return err
+	}
+	if path == "" {
+		_, err := buf.WriteTo(os.Stdout)
+		return err
+	}
+	out := buf.Bytes()
+	if strings.HasSuffix(path, ".gz") {
+		var gz bytes.Buffer
+		gw := gzip.NewWriter(&gz)
+		if _, err := gw.Write(out); err != nil {
+			return err
+		}
+		if err := gw.Close(); err != nil {
+			return err
+		}
+		out = gz.Bytes()
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
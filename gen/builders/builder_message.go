@@ -35,7 +35,7 @@ type MessageVectorBuilder struct {
 }
 
 // MessageVector creates a builder for a message-class vector.
-func MessageVector(metadata *schema.Metadata, selector schema.Selector, mode Mode, hints []string, pv ProtocolVersion) *MessageVectorBuilder {
+func MessageVector(metadata *schema.Metadata, selector schema.Selector, mode Mode, hints []schema.Hint, pv ProtocolVersion) *MessageVectorBuilder {
 	bc := &BuilderCommon{
 		Stage:           StagePreconditions,
 		ProtocolVersion: pv,
@@ -73,13 +73,15 @@ func MessageVector(metadata *schema.Metadata, selector schema.Selector, mode Mod
 // the driver should use the total maximum supply of Filecoin as specified in
 // the protocol when executing these messages.
 func (b *MessageVectorBuilder) SetCirculatingSupply(supply abi.TokenAmount) {
-	b.vector.Pre.CircSupply = supply.Int
+	amount := schema.NewTokenAmountFromBig(supply.Int)
+	b.vector.Pre.CircSupply = &amount
 }
 
 // SetBaseFee sets the base fee for this vector. If not set, the driver should
 // use 100 attoFIL as the base fee when executing this vector.
 func (b *MessageVectorBuilder) SetBaseFee(basefee abi.TokenAmount) {
-	b.vector.Pre.BaseFee = basefee.Int
+	amount := schema.NewTokenAmountFromBig(basefee.Int)
+	b.vector.Pre.BaseFee = &amount
 }
 
 // CommitPreconditions flushes the state tree, recording the new CID in the
@@ -137,7 +139,7 @@ func (b *MessageVectorBuilder) CommitApplies() {
 		var receipt *schema.Receipt
 		if !am.Failed {
 			receipt = &schema.Receipt{
-				ExitCode:    int64(am.Result.ExitCode),
+				ExitCode:    schema.ExitCode(am.Result.ExitCode),
 				ReturnValue: am.Result.Return,
 				GasUsed:     am.Result.GasUsed,
 			}
@@ -168,6 +170,9 @@ func (b *MessageVectorBuilder) Finish() *schema.TestVector {
 		panic(err)
 	}
 	b.vector.CAR = car
+	if err := b.vector.SetCARChecksum(); err != nil {
+		panic(err)
+	}
 
 	msgs := b.Messages.All()
 	traces := make([]types.ExecutionTrace, 0, len(msgs))
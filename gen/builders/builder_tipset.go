@@ -55,7 +55,7 @@ var _ Builder = (*TipsetVectorBuilder)(nil)
 
 // TipsetVector creates a new TipsetVectorBuilder. For usage details, read the
 // godocs on that type.
-func TipsetVector(metadata *schema.Metadata, selector schema.Selector, mode Mode, hints []string, pv ProtocolVersion) *TipsetVectorBuilder {
+func TipsetVector(metadata *schema.Metadata, selector schema.Selector, mode Mode, hints []schema.Hint, pv ProtocolVersion) *TipsetVectorBuilder {
 	bc := &BuilderCommon{
 		Stage:           StagePreconditions,
 		ProtocolVersion: pv,
@@ -188,7 +188,7 @@ func (b *TipsetVectorBuilder) CommitApplies() {
 		for i, res := range ret.AppliedResults {
 			// store the receipt in the vector.
 			b.vector.Post.Receipts = append(b.vector.Post.Receipts, &schema.Receipt{
-				ExitCode:    int64(res.ExitCode),
+				ExitCode:    schema.ExitCode(res.ExitCode),
 				ReturnValue: res.Return,
 				GasUsed:     res.GasUsed,
 			})
@@ -202,7 +202,7 @@ func (b *TipsetVectorBuilder) CommitApplies() {
 			mcid := ret.AppliedMessages[i].Cid()
 			for _, m := range b.Tipsets.Messages() {
 				if m.Message.Cid() == mcid {
-					m.baseFee = conformance.BaseFeeOrDefault(b.vector.Pre.BaseFee)
+					m.baseFee = conformance.BaseFeeOrDefault(b.vector.Pre.BaseFee.BigInt())
 					m.Result = res
 					break
 				}
@@ -246,11 +246,16 @@ func (b *TipsetVectorBuilder) Finish() *schema.TestVector {
 		panic("called Finish at the wrong time")
 	}
 
-	car, err := EncodeCAR(b.StateTracker.Stores.DAGService, b.vector.Pre.StateTree.RootCID, b.vector.Post.StateTree.RootCID)
+	// include the receipts AMTs, so that drivers can resolve the receipts roots.
+	roots := append([]cid.Cid{b.vector.Pre.StateTree.RootCID, b.vector.Post.StateTree.RootCID}, b.vector.Post.ReceiptsRoots...)
+	car, err := EncodeCAR(b.StateTracker.Stores.DAGService, roots...)
 	if err != nil {
 		panic(err)
 	}
 	b.vector.CAR = car
+	if err := b.vector.SetCARChecksum(); err != nil {
+		panic(err)
+	}
 
 	b.Stage = StageFinished
 	b.Assert = nil
@@ -13,7 +13,7 @@ import (
 	"github.com/filecoin-project/test-vectors/schema"
 )
 
-const LotusExecutionTraceV1 = "Lotus-ExecutionTrace-V1"
+const LotusExecutionTraceV1 = schema.DiagnosticsFormatLotusExecutionTraceV1
 
 // EncodeTraces takes a set of serialized lotus ExecutionTraces and writes them
 // to the test vector serialized diagnostic format.
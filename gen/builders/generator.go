@@ -127,7 +127,7 @@ type VectorDef struct {
 	// expressed in the vector), etc.
 	//
 	// Refer to the schema.Hint* constants for common hints.
-	Hints []string
+	Hints []schema.Hint
 
 	// Mode tunes certain elements of how the generation and assertion of
 	// a test vector will be conducted, such as being lenient to assertion
@@ -352,7 +352,7 @@ func vectorFilename(group string, item *VectorDef, vector *schema.TestVector) st
 	filename := fmt.Sprintf("%s--%s--%s.json", group, item.Metadata.ID, vector.Pre.Variants[0].ID)
 
 	// Prefix the file with "x--" if the vector is known to be broken.
-	var broken = map[string]struct{}{schema.HintIncorrect: {}}
+	var broken = map[schema.Hint]struct{}{schema.HintIncorrect: {}}
 	for _, hint := range item.Hints {
 		if _, ok := broken[hint]; ok {
 			filename = brokenVectorPrefix + filename
@@ -408,8 +408,9 @@ func (g *Generator) generateVariants(b VectorDef) []*schema.TestVector {
 		b.SupportedVersions = KnownProtocolVersions
 	}
 
-	// stamp with our generation data.
+	// stamp with our generation data, and the schema version we produce.
 	b.Metadata.Gen = genData
+	b.Metadata.SchemaVersion = schema.CurrentVersion
 
 	var result []*schema.TestVector
 	for _, version := range b.SupportedVersions {
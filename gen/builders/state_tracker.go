@@ -218,14 +218,14 @@ func (st *StateTracker) ApplyMessage(am *ApplicableMessage) {
 	var postRoot cid.Cid
 	var err error
 
-	am.baseFee = conformance.BaseFeeOrDefault(st.vector.Pre.BaseFee)
+	am.baseFee = conformance.BaseFeeOrDefault(st.vector.Pre.BaseFee.BigInt())
 	am.Applied = true
 	am.Result, postRoot, err = st.Driver.ExecuteMessage(st.Stores.Blockstore, conformance.ExecuteMessageParams{
 		Preroot:    st.CurrRoot,
 		Epoch:      st.bc.ProtocolVersion.FirstEpoch + am.EpochOffset,
 		Message:    am.Message,
-		BaseFee:    conformance.BaseFeeOrDefault(st.vector.Pre.BaseFee),
-		CircSupply: conformance.CircSupplyOrDefault(st.vector.Pre.CircSupply),
+		BaseFee:    conformance.BaseFeeOrDefault(st.vector.Pre.BaseFee.BigInt()),
+		CircSupply: conformance.CircSupplyOrDefault(st.vector.Pre.CircSupply.BigInt()),
 	})
 	if err != nil {
 		am.Failed = true
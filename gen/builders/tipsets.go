@@ -66,7 +66,7 @@ func (tss *TipsetSeq) Next(baseFee abi.TokenAmount) *Tipset {
 		tss: tss,
 		Tipset: schema.Tipset{
 			EpochOffset: int64(tss.epochOffset),
-			BaseFee:     *baseFee.Int,
+			BaseFee:     schema.NewTokenAmountFromBig(baseFee.Int),
 		},
 	}
 	tss.tipsets = append(tss.tipsets, ts)
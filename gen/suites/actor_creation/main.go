@@ -88,7 +88,7 @@ func main() {
 				Comment: "this should not return SysErrSenderInvalid; it should return something else, likely an SysErrSerialization",
 			},
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: createActorInitExecUnparsableParams,
 		},
 		&VectorDef{
@@ -99,7 +99,7 @@ func main() {
 				Comment: "this should not return SysErrSenderInvalid; it should return something else, likely an ErrSerialization because the error is in actor space",
 			},
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: createActorCtorUnparsableParamsViaInitExec,
 		},
 	)
@@ -57,7 +57,7 @@ func main() {
 				Comment: "should abort with SysErrorIllegalArgument if the beneficiary is the calling actor, will be fixed in https://github.com/filecoin-project/lotus/pull/3478",
 			},
 			Selector:    schema.Selector{"chaos_actor": "true"},
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: deleteActorWithBeneficiary(big.NewInt(50), chaos.Address, exitcode.Ok),
 		},
 	)
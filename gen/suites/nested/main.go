@@ -90,7 +90,7 @@ func main() {
 			},
 			MessageFunc: nestedSends_FailMissingParams,
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 		},
 		&VectorDef{
 			Metadata: &Metadata{
@@ -101,7 +101,7 @@ func main() {
 			},
 			MessageFunc: nestedSends_FailMismatchParams,
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 		},
 		&VectorDef{
 			Metadata: &Metadata{
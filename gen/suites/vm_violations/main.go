@@ -153,7 +153,7 @@ func main() {
 		// 		Desc:    "verifies that CreateActor aborts when provided an address.Undef",
 		// 	},
 		// 	Mode:     ModeLenientAssertions,
-		// 	Hints:    []string{HintIncorrect, HintNegate},
+		// 	Hints:    []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 		// 	Selector: map[string]string{"chaos_actor":"true"},
 		// 	MessageFunc:     createActor(undefAddr, builtin.AccountActorCodeID, exitcode.SysErrorIllegalArgument),
 		// },
@@ -264,7 +264,7 @@ func main() {
 			},
 			Selector:    map[string]string{"chaos_actor": "true"},
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: mutateState(valPfx+"readonly", chaos.MutateReadonly, exitcode.SysErrorIllegalActor),
 		},
 		&VectorDef{
@@ -276,7 +276,7 @@ func main() {
 			},
 			Selector:    map[string]string{"chaos_actor": "true"},
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: mutateState(valPfx+"after-transaction", chaos.MutateAfterTransaction, exitcode.SysErrorIllegalActor),
 		},
 	)
@@ -299,7 +299,7 @@ func main() {
 			},
 			Selector:    map[string]string{"chaos_actor": "true"},
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: actorAbort(-1, "negative exit code abort", exitcode.SysErrorIllegalActor),
 		},
 		{
@@ -310,7 +310,7 @@ func main() {
 			},
 			Selector:    map[string]string{"chaos_actor": "true"},
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: actorPanic("no exit code abort"),
 		},
 	}
@@ -342,7 +342,7 @@ func main() {
 			},
 			Selector:    map[string]string{"chaos_actor": "true"},
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: actorAbort(xc, fmt.Sprintf("%s abort", xc), exitcode.SysErrorIllegalActor),
 		})
 	}
@@ -368,7 +368,7 @@ func main() {
 			},
 			Selector:    map[string]string{"chaos_actor": "true"},
 			Mode:        ModeLenientAssertions,
-			Hints:       []string{schema.HintIncorrect, schema.HintNegate},
+			Hints:       []schema.Hint{schema.HintIncorrect, schema.HintNegate},
 			MessageFunc: receiverAlwaysIDAddress,
 		},
 	)
@@ -1,8 +1,16 @@
 module github.com/filecoin-project/test-vectors/schema
 
-go 1.14
+go 1.16
 
 require (
 	github.com/filecoin-project/go-address v0.0.3
+	github.com/ipfs/go-block-format v0.0.2
 	github.com/ipfs/go-cid v0.0.7
+	github.com/ipfs/go-datastore v0.4.5
+	github.com/ipfs/go-ipfs-blockstore v1.0.2
+	github.com/ipld/go-car v0.1.1-0.20200923150018-8cdef32e2da4
+	github.com/multiformats/go-multihash v0.0.13
+	github.com/whyrusleeping/cbor-gen v0.0.0-20200810223238-211df3b9e24c
+	github.com/xeipuuv/gojsonschema v1.2.0
+	gopkg.in/yaml.v3 v3.0.1
 )
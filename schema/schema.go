@@ -3,13 +3,19 @@ package schema
 import (
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
-	"math/big"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/ipfs/go-cid"
 )
 
+// Conventional names of the CARs of a vector, within TestVector.CARs.
+const (
+	// CARPre names the CAR holding the precondition state.
+	CARPre = "pre"
+	// CARPost names the CAR holding the postcondition state.
+	CARPost = "post"
+)
+
 // Class represents the type of test vector this instance is.
 type Class string
 
@@ -25,17 +31,46 @@ const (
 	ClassBlockSeq Class = "blockseq"
 )
 
+// Hint is a flag that conveys information to the driver about how to treat
+// a vector.
+type Hint string
+
 const (
 	// HintIncorrect is a standard hint to convey that a vector is knowingly
 	// incorrect. Drivers may choose to skip over these vectors, or if it's
 	// accompanied by HintNegate, they may perform the assertions as explained
 	// in its godoc.
-	HintIncorrect = "incorrect"
+	HintIncorrect Hint = "incorrect"
 
 	// HintNegate is a standard hint to convey to drivers that, if this vector
 	// is run, they should negate the postcondition checks (i.e. check that the
 	// postcondition state is expressly NOT the one encoded in this vector).
-	HintNegate = "negate"
+	HintNegate Hint = "negate"
+
+	// HintDuplicateMessages is a standard hint to convey that messages
+	// included in more than one block of a tipset are so on purpose (e.g. to
+	// test that they're executed only once). It silences the corresponding
+	// Lint warning.
+	HintDuplicateMessages Hint = "duplicate-messages"
+
+	// HintPostStateUnknown is a standard hint to convey that the
+	// postcondition state tree of the vector is unknown, and is therefore
+	// absent; drivers must only check the receipts. See SplitByMessage.
+	HintPostStateUnknown Hint = "post-state-unknown"
+
+	// HintExternalBlocks is a standard hint to convey that the CAR of the
+	// vector lacks blocks that are stored in a blockstore shared across
+	// vectors. See OptimizeCAR and LoadCARWithShared.
+	HintExternalBlocks Hint = "external-blocks"
+
+	// HintDetachedCAR is a standard hint to convey that the CAR of the vector
+	// is stored apart from it, and the car field is empty; it must be merged
+	// back before the vector is loaded. See SplitCAR and MergeCAR.
+	HintDetachedCAR Hint = "detached-car"
+
+	// HintVendorPrefix is the prefix of hints defined outside this package.
+	// Validate rejects any other hint that is not a standard one.
+	HintVendorPrefix = "x-"
 )
 
 // Well known selectors.
@@ -51,6 +86,15 @@ const (
 	// values include: "genesis" (protocol version at birth), "breeze", "smoke",
 	// "actorsv2".
 	SelectorMinProtocolVersion = "min_protocol_version"
+
+	// SelectorNetworkVersion constrains the network version the VM runs
+	// with, usually through a numeric comparison (e.g. ">=16"). The value is
+	// a network version number, as in Variant.NetworkVersion.
+	SelectorNetworkVersion = "nv"
+
+	// SelectorNetwork constrains the network whose parameters the VM is
+	// configured with, e.g. "mainnet" or "calibnet".
+	SelectorNetwork = "network"
 )
 
 // Selector is a predicate the driver can use to determine if this test vector
@@ -60,12 +104,31 @@ type Selector map[string]string
 
 // Metadata provides information on the generation of this test case
 type Metadata struct {
-	ID      string           `json:"id"`
+	ID string `json:"id"`
+	// Version is the revision of this test case, bumped when the vector
+	// is changed; it's unrelated to SchemaVersion.
 	Version string           `json:"version,omitempty"`
 	Desc    string           `json:"description,omitempty"`
 	Comment string           `json:"comment,omitempty"`
 	Gen     []GenerationData `json:"gen"`
 	Tags    []string         `json:"tags,omitempty"`
+
+	// CARChecksum is the base58-encoded multihash of the CAR of the vector,
+	// as stored (i.e. compressed, when it's gzipped). It's optional; when
+	// present, Validate checks the CAR against it. See SetCARChecksum.
+	CARChecksum string `json:"car_checksum,omitempty"`
+
+	// SkipReason explains why a vector hinted as incorrect is broken, for
+	// drivers to log when skipping it. See TestVector.ShouldSkip.
+	SkipReason string `json:"skip_reason,omitempty"`
+
+	// Related links this vector to other vectors, e.g. the one it was
+	// derived from. See AddRelation.
+	Related []RelatedVector `json:"related,omitempty"`
+
+	// SchemaVersion is the version of the schema the vector was produced
+	// with. Absent in vectors predating the field; see Migrate.
+	SchemaVersion string `json:"schema_version,omitempty"`
 }
 
 // GenerationData tags the source of this test case.
@@ -105,33 +168,115 @@ type Preconditions struct {
 	// StateTree is the starting state tree for this vector.
 	StateTree *StateTree `json:"state_tree,omitempty"`
 
+	// NamedStateTrees are additional state trees, keyed by name, for
+	// specialized vectors that need to reference more than one state (e.g.
+	// to compare two snapshots). They are optional, and their roots must be
+	// present in the CAR. When both forms are present, StateTree takes
+	// precedence: it's always the state the VM is seeded with, and named
+	// state trees are only ever consulted by name.
+	NamedStateTrees map[string]StateTree `json:"named_state_trees,omitempty"`
+
 	// BaseFee is an optional base fee to inject into the VM when feeding this
 	// message. If absent, it defaults to 100 attoFIL.
-	BaseFee *big.Int `json:"basefee,omitempty"`
+	BaseFee *TokenAmount `json:"basefee,omitempty"`
+
+	// NetworkVersion is the network version to run the vector with, which
+	// drivers use to select the actors bundle. It's optional; when present,
+	// it's authoritative over the network version the selector may pin (see
+	// TestVector.NetworkVersion), and the variants must agree with it.
+	NetworkVersion *NetworkVersion `json:"network_version,omitempty"`
 
 	// CircSupply is optional. If specified, it is the value that will be
 	// injected in the VM when feeding this message. If absent, the default
 	// value will be injected (TotalFilecoin, the maximum supply of Filecoin
 	// that will ever exist). It is usually odd to set it, and it's only here
 	// for specialized vectors.
-	CircSupply *big.Int `json:"circ_supply,omitempty"`
+	CircSupply *TokenAmount `json:"circ_supply,omitempty"`
+
+	// PreconditionsBlockSeq contains the preconditions for blockseq-class
+	// vectors; it is required for that class, and must be absent otherwise.
+	*PreconditionsBlockSeq `json:"blockseq,omitempty"`
 }
 
 // Receipt represents a receipt to match against.
 type Receipt struct {
 	// ExitCode must be interpreted by the driver as an exitcode.ExitCode
 	// in Lotus, or equivalent type in other implementations.
-	ExitCode    int64              `json:"exit_code"`
+	ExitCode    ExitCode           `json:"exit_code"`
 	ReturnValue Base64EncodedBytes `json:"return"`
 	GasUsed     int64              `json:"gas_used"`
+
+	// GasUsedTolerance is the amount by which the gas used may deviate from
+	// GasUsed, either way, for the receipt to match (see GasMatches). It
+	// accommodates small rounding differences across implementations, and
+	// defaults to zero, i.e. an exact match.
+	GasUsedTolerance int64 `json:"gas_used_tolerance,omitempty"`
+
+	// GasBreakdown optionally splits GasUsed into named components, e.g.
+	// "compute" and "storage", for vectors asserting the gas model in detail.
+	// When present, its components must add up to GasUsed (see
+	// GasBreakdownTotal). Drivers that can't report component gas ignore it.
+	GasBreakdown map[string]int64 `json:"gas_breakdown,omitempty"`
+
+	// ErrorMessage is the reason the message is expected to abort with, for
+	// receipts with a non-zero exit code. It's optional, and matched as a
+	// substring of the error the implementation reports (see ErrorMatches),
+	// as the wording around it differs across implementations. Under
+	// HintNegate, it's negated along with the rest of the postconditions.
+	ErrorMessage string `json:"error_message,omitempty"`
+
+	// Events are the events the message is expected to emit, in order, as
+	// CBOR-encoded event entries. They are optional.
+	Events []Base64EncodedBytes `json:"events,omitempty"`
+
+	// EventsRoot is the root of the AMT of Events, as the VM commits to it.
+	// It's optional, and can only be set alongside Events, as messages that
+	// emit no events have no events root.
+	EventsRoot *cid.Cid `json:"events_root,omitempty"`
 }
 
 // Postconditions contain a representation of VM state at th end of the test
 type Postconditions struct {
-	ApplyMessageFailures []int      `json:"apply_message_failures,omitempty"`
-	StateTree            *StateTree `json:"state_tree"`
-	Receipts             []*Receipt `json:"receipts"`
-	ReceiptsRoots        []cid.Cid  `json:"receipts_roots,omitempty"`
+	// ApplyMessageFailures are the indices, within ApplyMessages, of the
+	// messages whose application is expected to fail outright, rather than
+	// produce a receipt with a non-zero exit code. Their receipts are null.
+	ApplyMessageFailures []int `json:"apply_message_failures,omitempty"`
+
+	StateTree *StateTree `json:"state_tree"`
+	Receipts  []*Receipt `json:"receipts"`
+
+	// ReceiptsRoots are the roots of the AMTs of Receipts, as the VM commits
+	// to them. They are optional; when present, message-class vectors carry
+	// exactly one root, committing to all receipts, and tipset-class vectors
+	// carry one root per applied tipset, in order, each committing to the
+	// receipts of the messages in that tipset.
+	ReceiptsRoots []cid.Cid `json:"receipts_roots,omitempty"`
+
+	// ChainHead is the CIDs of the blocks of the tipset that is expected to
+	// be the head of the chain after all blocks have arrived. Only used by
+	// blockseq-class vectors. The order of the CIDs is not meaningful; see
+	// CanonicalizeOrder.
+	ChainHead []cid.Cid `json:"chain_head,omitempty"`
+
+	// PartialState are assertions on the state of specific actors, which
+	// drivers check in addition to the state tree root, when present, or
+	// instead of it, for vectors hinted with HintPostStateUnknown. Unlike the
+	// root, they're unaffected by changes to unrelated actors. They're
+	// optional.
+	PartialState []ActorAssertion `json:"partial_state,omitempty"`
+
+	// StateDiff is the expected change to the state tree, a CBOR-encoded
+	// StateDiff, which documents what the vector does in terms of actors,
+	// and lets drivers check it by diffing state trees. When the vector also
+	// has precondition and postcondition state tree roots, applying the diff
+	// to the former must yield the latter; see ApplyDiff. It's optional; use
+	// SetStateDiff and DecodeStateDiff to access it.
+	StateDiff Base64EncodedBytes `json:"state_diff,omitempty"`
+
+	// CustomChecks are the names of implementation-specific postcondition
+	// checks, registered with RegisterPostconditionChecker, which Check runs
+	// in order, after the built-in ones. They're optional.
+	CustomChecks []string `json:"custom_checks,omitempty"`
 }
 
 func (b Base64EncodedBytes) String() string {
@@ -181,8 +326,9 @@ type TestVector struct {
 	// should negate the postconditions (i.e. test that they are NOT the ones
 	// expressed in the vector), etc.
 	//
-	// Refer to the Hint* constants for common hints.
-	Hints []string `json:"hints,omitempty"`
+	// Refer to the Hint* constants for common hints. Non-standard hints must
+	// carry the HintVendorPrefix.
+	Hints []Hint `json:"hints,omitempty"`
 
 	Meta *Metadata `json:"_meta"`
 
@@ -191,6 +337,14 @@ type TestVector struct {
 	// objects.
 	CAR Base64EncodedBytes `json:"car"`
 
+	// CARs are additional named CARs, for vectors whose blocks are best kept
+	// apart, e.g. a postcondition state holding blocks that can't be derived
+	// from the precondition state and the messages; CARPre and CARPost are
+	// the conventional names. Loaders merge them with CAR, which may then be
+	// empty, into a single blockstore, so roots may resolve in any of them.
+	// They're optional.
+	CARs map[string]Base64EncodedBytes `json:"cars,omitempty"`
+
 	// Randomness encodes randomness to be replayed during the execution of this
 	// test vector. See godocs on the Randomness type for more info.
 	Randomness Randomness `json:"randomness,omitempty"`
@@ -199,6 +353,14 @@ type TestVector struct {
 
 	ApplyMessages []Message `json:"apply_messages,omitempty"`
 	ApplyTipsets  []Tipset  `json:"apply_tipsets,omitempty"`
+	ApplyBlockseq *BlockSeq `json:"apply_blockseq,omitempty"`
+
+	// NullRounds are the epoch offsets of the null rounds interleaved with
+	// the messages of a message-class vector, i.e. epochs in which no tipset
+	// is produced. Drivers must advance the VM clock across them, rather than
+	// apply messages in them. They must be strictly increasing, and distinct
+	// from the epoch offsets of the messages.
+	NullRounds []int64 `json:"null_rounds,omitempty"`
 
 	Post        *Postconditions `json:"postconditions"`
 	Diagnostics *Diagnostics    `json:"diagnostics,omitempty"`
@@ -211,6 +373,31 @@ type Message struct {
 	// It.must be interpreted by the driver as an abi.ChainEpoch in Lotus, or
 	// equivalent type in other implementations.
 	EpochOffset *int64 `json:"epoch_offset,omitempty"`
+
+	// Signature, if set, is the signature of the message, which drivers must
+	// apply as a signed message, checking the signature like the VM would.
+	// If absent, the message is applied unsigned, i.e. the signature is
+	// assumed to be valid.
+	Signature *Signature `json:"signature,omitempty"`
+
+	// From, if set, overrides the sender of the serialized message, e.g. to
+	// check the signature against another address than the one it was made
+	// for.
+	From *address.Address `json:"from,omitempty"`
+}
+
+// SignatureType is the type of a message signature.
+type SignatureType string
+
+const (
+	SignatureSecp256k1 = SignatureType("secp256k1")
+	SignatureBLS       = SignatureType("bls")
+)
+
+// Signature is the signature of a message.
+type Signature struct {
+	Type SignatureType      `json:"type"`
+	Data Base64EncodedBytes `json:"data"`
 }
 
 type Tipset struct {
@@ -219,11 +406,17 @@ type Tipset struct {
 	// in Lotus, or equivalent type in other implementations.
 	EpochOffset int64 `json:"epoch_offset"`
 
-	// BaseFee must be interpreted by the driver as an abi.TokenAmount in Lotus,
-	// or equivalent type in other implementations.
-	BaseFee big.Int `json:"basefee"`
+	// BaseFee is the base fee to inject into the VM when applying this tipset.
+	BaseFee TokenAmount `json:"basefee"`
 
 	Blocks []Block `json:"blocks,omitempty"`
+
+	// ExpectedStateRoot is the root of the state tree expected after applying
+	// this tipset, letting drivers localize failures in vectors spanning
+	// several tipsets. It's either present on every tipset of the vector or
+	// on none, and on the last tipset it's the root of the postcondition
+	// state tree.
+	ExpectedStateRoot *cid.Cid `json:"expected_state_root,omitempty"`
 }
 
 type Block struct {
@@ -232,20 +425,20 @@ type Block struct {
 	Messages  []Base64EncodedBytes `json:"messages"`
 }
 
-// Validate validates this test vector against the JSON schema, and applies
-// further validation rules that cannot be enforced through JSON Schema.
-func (tv TestVector) Validate() error {
-	if tv.Class == ClassMessage {
-		if len(tv.Post.Receipts) != len(tv.ApplyMessages) {
-			return fmt.Errorf("length of postcondition receipts must match length of messages to apply")
-		}
-	}
-	return nil
+// MarshalJSONDeterministic encodes the test vector to JSON, such that
+// re-encoding an unchanged vector always yields identical bytes. Object keys
+// of all maps (Selector, BlockSeq.MessageRepo keyed by CID string) are emitted
+// in sorted order; encoding/json already sorts map keys, and this method is the
+// place where that guarantee is pinned, so that callers relying on
+// byte-for-byte reproducibility need not depend on encoder internals.
+func (tv TestVector) MarshalJSONDeterministic() ([]byte, error) {
+	return json.Marshal(&tv)
 }
 
-// MustMarshalJSON encodes the test vector to JSON and panics if it errors.
+// MustMarshalJSON encodes the test vector to JSON deterministically (see
+// MarshalJSONDeterministic) and panics if it errors.
 func (tv TestVector) MustMarshalJSON() []byte {
-	b, err := json.Marshal(&tv)
+	b, err := tv.MarshalJSONDeterministic()
 	if err != nil {
 		panic(err)
 	}
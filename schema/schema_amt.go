@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// amtWidth is the number of slots of each node of the AMTs receipts are
+// committed to: a bit width of 3, in the legacy AMT format that block headers
+// have used since genesis, whose root doesn't record its bit width.
+const amtWidth = 8
+
+// amtRoot returns the root CID of the AMT holding the CBOR-encoded values at
+// consecutive indices from zero, computing the CIDs of its nodes without
+// storing them.
+func amtRoot(values [][]byte) (cid.Cid, error) {
+	height, capacity := 0, amtWidth
+	for len(values) > capacity {
+		height++
+		capacity *= amtWidth
+	}
+	node, err := amtNodeBytes(values, height)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	var buf bytes.Buffer
+	if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 3); err != nil {
+		return cid.Undef, err
+	}
+	if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajUnsignedInt, uint64(height)); err != nil {
+		return cid.Undef, err
+	}
+	if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajUnsignedInt, uint64(len(values))); err != nil {
+		return cid.Undef, err
+	}
+	buf.Write(node)
+	return cidBuilder.Sum(buf.Bytes())
+}
+
+// amtNodeBytes encodes the node holding the values, at the height; leaves are
+// at height zero, and hold the values themselves, while the nodes above
+// link to the nodes below.
+func amtNodeBytes(values [][]byte, height int) ([]byte, error) {
+	var (
+		bmap  byte
+		links []cid.Cid
+	)
+	if height > 0 {
+		span := 1
+		for i := 0; i < height; i++ {
+			span *= amtWidth
+		}
+		for i := 0; i*span < len(values); i++ {
+			end := (i + 1) * span
+			if end > len(values) {
+				end = len(values)
+			}
+			child, err := amtNodeBytes(values[i*span:end], height-1)
+			if err != nil {
+				return nil, err
+			}
+			c, err := cidBuilder.Sum(child)
+			if err != nil {
+				return nil, err
+			}
+			bmap |= 1 << uint(i)
+			links = append(links, c)
+		}
+	} else {
+		for i := range values {
+			bmap |= 1 << uint(i)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 3); err != nil {
+		return nil, err
+	}
+	if err := writeByteString(&buf, []byte{bmap}); err != nil {
+		return nil, err
+	}
+	if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(links))); err != nil {
+		return nil, err
+	}
+	for _, c := range links {
+		if err := cbg.WriteCid(&buf, c); err != nil {
+			return nil, fmt.Errorf("writing amt link: %w", err)
+		}
+	}
+	var leaves [][]byte
+	if height == 0 {
+		leaves = values
+	}
+	if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(leaves))); err != nil {
+		return nil, err
+	}
+	for _, v := range leaves {
+		buf.Write(v)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+package schema
+
+// Tags derived by AutoTag. Vectors are also tagged with their class.
+const (
+	// TagMultiMessage tags vectors applying more than one message, either
+	// directly or within tipsets.
+	TagMultiMessage = "multi-message"
+
+	// TagExpectsFailure tags vectors expecting the application of some of
+	// their messages to fail outright (see ApplyMessageFailures).
+	TagExpectsFailure = "expects-failure"
+
+	// TagHighGas tags vectors whose receipts use at least HighGasThreshold
+	// gas in total.
+	TagHighGas = "high-gas"
+
+	// TagHasDiagnostics tags vectors carrying diagnostics.
+	TagHasDiagnostics = "has-diagnostics"
+)
+
+// HighGasThreshold is the total gas used from which AutoTag tags a vector
+// with TagHighGas: a tenth of the block gas limit.
+const HighGasThreshold = 1_000_000_000
+
+// AutoTag derives tags from the content of the vector, adds them to its
+// metadata (which is created if absent) with Metadata.AddTag, and returns
+// them. Tags already present are kept. The derived tags are the class of the
+// vector, and TagMultiMessage, TagExpectsFailure, TagHighGas and
+// TagHasDiagnostics, as they apply.
+func AutoTag(tv *TestVector) []string {
+	var tags []string
+	if tv.Class != "" {
+		tags = append(tags, string(tv.Class))
+	}
+
+	msgs := len(tv.ApplyMessages)
+	for _, ts := range tv.ApplyTipsets {
+		for _, b := range ts.Blocks {
+			msgs += len(b.Messages)
+		}
+	}
+	if msgs > 1 {
+		tags = append(tags, TagMultiMessage)
+	}
+	if tv.Post != nil && len(tv.Post.ApplyMessageFailures) > 0 {
+		tags = append(tags, TagExpectsFailure)
+	}
+	if tv.TotalGasUsed() >= HighGasThreshold {
+		tags = append(tags, TagHighGas)
+	}
+	if tv.Diagnostics != nil {
+		tags = append(tags, TagHasDiagnostics)
+	}
+
+	if tv.Meta == nil {
+		tv.Meta = new(Metadata)
+	}
+	for _, t := range tags {
+		tv.Meta.AddTag(t)
+	}
+	return tags
+}
@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAutoTag(t *testing.T) {
+	tv := fullTestVector(t)
+	tv.Post.Receipts[0].GasUsed = HighGasThreshold
+
+	tags := AutoTag(tv)
+	expected := []string{"tipset", TagMultiMessage, TagExpectsFailure, TagHighGas, TagHasDiagnostics}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected tags %v, got %v", expected, tags)
+	}
+	// existing tags are kept, and the result is sorted.
+	if expected := []string{"a", "b", TagExpectsFailure, TagHasDiagnostics, TagHighGas, TagMultiMessage, "tipset"}; !reflect.DeepEqual(tv.Meta.Tags, expected) {
+		t.Fatalf("expected metadata tags %v, got %v", expected, tv.Meta.Tags)
+	}
+
+	// tagging is idempotent.
+	AutoTag(tv)
+	if len(tv.Meta.Tags) != 7 {
+		t.Fatalf("expected re-tagging not to duplicate tags, got %v", tv.Meta.Tags)
+	}
+
+	single := &TestVector{Class: ClassMessage, ApplyMessages: []Message{{}}, Post: &Postconditions{Receipts: []*Receipt{{GasUsed: HighGasThreshold - 1}}}}
+	if tags := AutoTag(single); !reflect.DeepEqual(tags, []string{"message"}) {
+		t.Fatalf("expected only the class tag, got %v", tags)
+	}
+	if single.Meta == nil || !single.Meta.HasTag("message") {
+		t.Fatal("expected metadata to be created")
+	}
+}
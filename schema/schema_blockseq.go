@@ -0,0 +1,317 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// OffsetMillis is a time offset that serializes to JSON as an integer number
+// of milliseconds.
+type OffsetMillis time.Duration
+
+// MaxOffset is the largest OffsetMillis that can be encoded: a year. Blocks
+// of any sensible vector arrive well within that, and bounding offsets keeps
+// them from overflowing time.Duration when decoded.
+const MaxOffset = OffsetMillis(365 * 24 * time.Hour)
+
+// check returns an error if the offset is negative, or exceeds MaxOffset, in
+// which case it can't be encoded.
+func (o OffsetMillis) check() error {
+	switch {
+	case o < 0:
+		return fmt.Errorf("offset %s is negative", time.Duration(o))
+	case o > MaxOffset:
+		return fmt.Errorf("offset %s exceeds the maximum of %s", time.Duration(o), time.Duration(MaxOffset))
+	}
+	return nil
+}
+
+// checkMillis returns an error if the offset of ms milliseconds exceeds
+// MaxOffset.
+func checkMillis(ms uint64) error {
+	if max := time.Duration(MaxOffset).Milliseconds(); ms > uint64(max) {
+		return fmt.Errorf("offset of %d ms exceeds the maximum of %d ms", ms, max)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshal for OffsetMillis
+func (o OffsetMillis) MarshalJSON() ([]byte, error) {
+	if err := o.check(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(time.Duration(o).Milliseconds())
+}
+
+// UnmarshalJSON implements json.Unmarshal for OffsetMillis
+func (o *OffsetMillis) UnmarshalJSON(v []byte) error {
+	var ms uint64
+	if err := json.Unmarshal(v, &ms); err != nil {
+		return err
+	}
+	if err := checkMillis(ms); err != nil {
+		return err
+	}
+	*o = OffsetMillis(time.Duration(ms) * time.Millisecond)
+	return nil
+}
+
+// PreconditionsBlockSeq contains the preconditions that are specific to
+// blockseq-class vectors.
+type PreconditionsBlockSeq struct {
+	// GenesisTs is the timestamp of the genesis block, in seconds since the
+	// unix epoch. Block arrival offsets are relative to this timestamp.
+	GenesisTs uint64 `json:"genesis_ts"`
+}
+
+// BlockSeq is a sequence of blocks that arrive from the network at concrete
+// points in time.
+type BlockSeq struct {
+	// Blocks are the blocks that arrive, in order of arrival.
+	Blocks []TimestampedRawBlock `json:"blocks"`
+
+	// MessageRepo holds the serialized messages referenced by the blocks,
+	// keyed by message CID. Drivers must serve messages from this repo when
+	// the implementation fetches the messages included in a block.
+	MessageRepo MessageRepo `json:"message_repo,omitempty"`
+}
+
+// MessageRepo maps message CIDs to serialized messages.
+//
+// Messages are always keyed by CIDv1 dag-cbor, which is how blocks reference
+// them. Keys are canonicalized to that form when encoding and decoding JSON:
+// CIDv0 keys, and bare base58 multihashes (as emitted by older tooling), are
+// taken to be the multihash of a dag-cbor message. Keys carrying any other
+// codec are rejected, as they could never be looked up by drivers.
+type MessageRepo map[cid.Cid]Base64EncodedBytes
+
+// MarshalJSON implements json.Marshaler for MessageRepo.
+func (r MessageRepo) MarshalJSON() ([]byte, error) {
+	if r == nil {
+		return []byte("null"), nil
+	}
+	// a plain map, so that encoding/json emits it with sorted CID string keys.
+	out := make(map[cid.Cid]Base64EncodedBytes, len(r))
+	for c, msg := range r {
+		k, err := canonicalMessageCID(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := putMessage(out, k, msg); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for MessageRepo.
+func (r *MessageRepo) UnmarshalJSON(b []byte) error {
+	var in map[string]Base64EncodedBytes
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	if in == nil {
+		*r = nil
+		return nil
+	}
+	ret := make(MessageRepo, len(in))
+	for s, msg := range in {
+		c, err := cid.Decode(s)
+		if err != nil {
+			mh, mhErr := multihash.FromB58String(s)
+			if mhErr != nil {
+				return fmt.Errorf("invalid message repo key %q: %w", s, err)
+			}
+			c = cid.NewCidV1(cid.DagCBOR, mh)
+		}
+		if c, err = canonicalMessageCID(c); err != nil {
+			return err
+		}
+		if err := putMessage(ret, c, msg); err != nil {
+			return err
+		}
+	}
+	*r = ret
+	return nil
+}
+
+// canonicalMessageCID returns the CIDv1 dag-cbor form of a message CID.
+func canonicalMessageCID(c cid.Cid) (cid.Cid, error) {
+	switch {
+	case !c.Defined():
+		return cid.Undef, fmt.Errorf("message repo key is undefined")
+	case c.Version() == 0:
+		return cid.NewCidV1(cid.DagCBOR, c.Hash()), nil
+	case c.Type() != cid.DagCBOR:
+		name, ok := cid.CodecToStr[c.Type()]
+		if !ok {
+			name = fmt.Sprintf("0x%x", c.Type())
+		}
+		return cid.Undef, fmt.Errorf("message repo key %s has codec %s; messages must be keyed by dag-cbor cids", c, name)
+	}
+	return c, nil
+}
+
+// canonicalize returns a copy of the repo with its keys in canonical form. Keys
+// that don't canonicalize, or that canonicalize to the key of a different
+// message, are kept as is, for MarshalJSON to report.
+func (r MessageRepo) canonicalize() MessageRepo {
+	ret := make(MessageRepo, len(r))
+	for c, msg := range r {
+		if k, err := canonicalMessageCID(c); err == nil {
+			if existing, ok := r[k]; !ok || bytes.Equal(existing, msg) {
+				c = k
+			}
+		}
+		ret[c] = msg
+	}
+	return ret
+}
+
+// putMessage adds a message under its canonical CID, failing if two keys that
+// canonicalize to the same CID carry different messages.
+func putMessage(m map[cid.Cid]Base64EncodedBytes, c cid.Cid, msg Base64EncodedBytes) error {
+	if existing, ok := m[c]; ok && !bytes.Equal(existing, msg) {
+		return fmt.Errorf("message repo keys for %s carry different messages", c)
+	}
+	m[c] = msg
+	return nil
+}
+
+// TimestampedRawBlock is a serialized block (a BlockMsg in Lotus, or
+// equivalent type in other implementations), along with the time at which it
+// arrives from the network.
+type TimestampedRawBlock struct {
+	// OffsetMs is the offset from the genesis timestamp at which this block
+	// arrives.
+	OffsetMs OffsetMillis `json:"offset_ms"`
+
+	Bytes Base64EncodedBytes `json:"bytes"`
+}
+
+// BlocksUntil returns the blocks of this sequence that arrive at or before the
+// given offset from the genesis timestamp, in order of arrival. It's meant
+// for partial replays of the sequence.
+func (bs BlockSeq) BlocksUntil(offset time.Duration) []TimestampedRawBlock {
+	var ret []TimestampedRawBlock
+	for _, b := range bs.Blocks {
+		if time.Duration(b.OffsetMs) <= offset {
+			ret = append(ret, b)
+		}
+	}
+	return ret
+}
+
+// Duration returns the offset from the genesis timestamp at which the last
+// block of this sequence arrives, or zero if there are no blocks.
+func (bs BlockSeq) Duration() time.Duration {
+	var ret time.Duration
+	for _, b := range bs.Blocks {
+		if d := time.Duration(b.OffsetMs); d > ret {
+			ret = d
+		}
+	}
+	return ret
+}
+
+// ValidateRepo decodes every block in this sequence, and checks that all
+// messages referenced by them are present in the MessageRepo. It returns an
+// error listing the CIDs of any missing messages.
+func (bs BlockSeq) ValidateRepo() error {
+	var (
+		missing []string
+		seen    = make(map[cid.Cid]struct{})
+	)
+	for i, b := range bs.Blocks {
+		cids, err := blockMessageCIDs(b.Bytes)
+		if err != nil {
+			return fmt.Errorf("decoding block at index %d: %w", i, err)
+		}
+		for _, c := range cids {
+			if _, ok := bs.MessageRepo[c]; ok {
+				continue
+			}
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			missing = append(missing, c.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("messages referenced by blocks are missing from the message repo: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// UnreferencedMessages returns the CIDs of the messages in the MessageRepo
+// that no block in this sequence references, sorted by their string form.
+// Orphan entries are harmless to drivers, but usually signal a mistake in the
+// generation of the vector.
+func (bs BlockSeq) UnreferencedMessages() ([]cid.Cid, error) {
+	referenced := make(map[cid.Cid]struct{})
+	for i, b := range bs.Blocks {
+		cids, err := blockMessageCIDs(b.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("decoding block at index %d: %w", i, err)
+		}
+		for _, c := range cids {
+			referenced[c] = struct{}{}
+		}
+	}
+
+	var ret []cid.Cid
+	for c := range bs.MessageRepo {
+		if _, ok := referenced[c]; !ok {
+			ret = append(ret, c)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].String() < ret[j].String() })
+	return ret, nil
+}
+
+// blockMessageCIDs decodes a serialized BlockMsg, and returns the CIDs of the
+// BLS messages followed by the CIDs of the secp256k1 messages it references.
+// The block header is skipped over without being interpreted.
+func blockMessageCIDs(raw []byte) ([]cid.Cid, error) {
+	br := bytes.NewReader(raw)
+	maj, n, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if maj != cbg.MajArray || n != 3 {
+		return nil, fmt.Errorf("expected block to be a cbor array of 3 elements")
+	}
+
+	var header cbg.Deferred
+	if err := header.UnmarshalCBOR(br); err != nil {
+		return nil, fmt.Errorf("reading block header: %w", err)
+	}
+
+	var ret []cid.Cid
+	for _, kind := range []string{"bls", "secpk"} {
+		maj, n, err := cbg.CborReadHeader(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s messages: %w", kind, err)
+		}
+		if maj != cbg.MajArray {
+			return nil, fmt.Errorf("expected %s messages to be a cbor array", kind)
+		}
+		for i := uint64(0); i < n; i++ {
+			c, err := cbg.ReadCid(br)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s message cid at index %d: %w", kind, i, err)
+			}
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,195 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// mkBlockMsg serializes a BlockMsg-shaped cbor array with a dummy header and
+// the supplied message CIDs.
+func mkBlockMsg(t *testing.T, bls []cid.Cid, secpk []cid.Cid) []byte {
+	var buf bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 3)
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 0) // header
+	for _, cids := range [][]cid.Cid{bls, secpk} {
+		_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(cids)))
+		for _, c := range cids {
+			if err := cbg.WriteCid(&buf, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func mkCid(t *testing.T, data string) cid.Cid {
+	c, err := cid.V1Builder{Codec: cid.DagCBOR, MhType: multihash.BLAKE2B_MIN + 31}.Sum([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestBlockSeqValidateRepo(t *testing.T) {
+	var (
+		a, b, c = mkCid(t, "a"), mkCid(t, "b"), mkCid(t, "c")
+		repo    = map[cid.Cid]Base64EncodedBytes{a: []byte("a"), c: []byte("c")}
+	)
+
+	bs := BlockSeq{
+		Blocks: []TimestampedRawBlock{
+			{Bytes: mkBlockMsg(t, []cid.Cid{a}, nil)},
+			{Bytes: mkBlockMsg(t, nil, []cid.Cid{a, b})},
+		},
+		MessageRepo: repo,
+	}
+
+	err := bs.ValidateRepo()
+	if err == nil || !strings.Contains(err.Error(), b.String()) || strings.Contains(err.Error(), a.String()) {
+		t.Fatalf("expected error listing only the missing message, got: %v", err)
+	}
+
+	orphans, err := bs.UnreferencedMessages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || !orphans[0].Equals(c) {
+		t.Fatalf("expected %s to be unreferenced, got: %v", c, orphans)
+	}
+
+	repo[b] = []byte("b")
+	if err := bs.ValidateRepo(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	bs.Blocks[0].Bytes = []byte{0x80}
+	if err := bs.ValidateRepo(); err == nil || !strings.Contains(err.Error(), "block at index 0") {
+		t.Fatalf("expected decoding error, got: %v", err)
+	}
+}
+
+func TestMessageRepoJSON(t *testing.T) {
+	c := mkCid(t, "msg")
+	b58 := c.Hash().B58String()
+	for _, key := range []string{c.String(), b58} {
+		var repo MessageRepo
+		if err := json.Unmarshal([]byte(`{"`+key+`": "bXNn"}`), &repo); err != nil {
+			t.Fatalf("decoding key %s: %s", key, err)
+		}
+		if string(repo[c]) != "msg" {
+			t.Fatalf("expected key %s to be canonicalized to %s, got: %v", key, c, repo)
+		}
+	}
+
+	// CIDv0 keys are canonicalized on encoding too; they are sha2-256 only.
+	v0, err := cid.Decode("QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(MessageRepo{v0: []byte("msg")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"` + cid.NewCidV1(cid.DagCBOR, v0.Hash()).String() + `":"bXNn"}`; string(b) != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	raw := cid.NewCidV1(cid.Raw, c.Hash())
+	if _, err := json.Marshal(MessageRepo{raw: []byte("msg")}); err == nil || !strings.Contains(err.Error(), "has codec raw") {
+		t.Fatalf("expected a codec error, got: %v", err)
+	}
+	var repo MessageRepo
+	if err := json.Unmarshal([]byte(`{"`+raw.String()+`": "bXNn"}`), &repo); err == nil || !strings.Contains(err.Error(), "has codec raw") {
+		t.Fatalf("expected a codec error, got: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"`+c.String()+`": "bXNn", "`+b58+`": "b3RoZXI="}`), &repo); err == nil || !strings.Contains(err.Error(), "carry different messages") {
+		t.Fatalf("expected a conflicting keys error, got: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"not-a-cid": ""}`), &repo); err == nil || !strings.Contains(err.Error(), `invalid message repo key "not-a-cid"`) {
+		t.Fatalf("expected an invalid key error, got: %v", err)
+	}
+}
+
+func TestBlockSeqBlocksUntil(t *testing.T) {
+	var bs BlockSeq
+	if bs.Duration() != 0 || len(bs.BlocksUntil(time.Hour)) != 0 {
+		t.Fatal("expected an empty sequence to have no duration nor blocks")
+	}
+
+	for i, ms := range []int64{0, 1000, 1000, 2500} {
+		bs.Blocks = append(bs.Blocks, TimestampedRawBlock{
+			OffsetMs: OffsetMillis(time.Duration(ms) * time.Millisecond),
+			Bytes:    []byte{byte(i)},
+		})
+	}
+	if d := bs.Duration(); d != 2500*time.Millisecond {
+		t.Fatalf("expected a duration of 2.5s, got %s", d)
+	}
+	for _, c := range []struct {
+		offset time.Duration
+		blocks int
+	}{
+		{-time.Second, 0},
+		{0, 1},
+		{999 * time.Millisecond, 1},
+		{time.Second, 3},
+		{bs.Duration(), 4},
+	} {
+		blocks := bs.BlocksUntil(c.offset)
+		if len(blocks) != c.blocks {
+			t.Fatalf("expected %d blocks until %s, got %d", c.blocks, c.offset, len(blocks))
+		}
+		for i, b := range blocks {
+			if b.Bytes[0] != byte(i) {
+				t.Fatalf("expected blocks until %s in order of arrival, got %v", c.offset, blocks)
+			}
+		}
+	}
+}
+
+func TestOffsetMillisBounds(t *testing.T) {
+	for _, o := range []OffsetMillis{0, OffsetMillis(1500 * time.Millisecond), MaxOffset} {
+		b, err := json.Marshal(o)
+		if err != nil {
+			t.Fatalf("unexpected error marshalling offset %s: %s", time.Duration(o), err)
+		}
+		var decoded OffsetMillis
+		if err := json.Unmarshal(b, &decoded); err != nil || decoded != o {
+			t.Fatalf("expected offset %s to round-trip, got %s (%v)", time.Duration(o), time.Duration(decoded), err)
+		}
+	}
+
+	for _, o := range []OffsetMillis{-OffsetMillis(time.Millisecond), MaxOffset + OffsetMillis(time.Millisecond)} {
+		if _, err := json.Marshal(o); err == nil {
+			t.Errorf("expected marshalling offset %s to fail", time.Duration(o))
+		}
+	}
+
+	var o OffsetMillis
+	for _, raw := range []string{"-1", "31536000001", "18446744073709551615"} {
+		if err := json.Unmarshal([]byte(raw), &o); err == nil {
+			t.Errorf("expected unmarshalling offset %s to fail", raw)
+		}
+	}
+
+	tv := fullTestVector(t)
+	tv.ApplyBlockseq.Blocks[0].OffsetMs = -1
+	if err := tv.EncodeCBOR(new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "is negative") {
+		t.Fatalf("expected a negative offset cbor encoding error, got: %v", err)
+	}
+	bs := TestVector{
+		Class:         ClassBlockSeq,
+		Pre:           &Preconditions{PreconditionsBlockSeq: &PreconditionsBlockSeq{GenesisTs: 1598306400}},
+		ApplyBlockseq: &BlockSeq{Blocks: []TimestampedRawBlock{{OffsetMs: -1, Bytes: []byte{0x83}}}},
+	}
+	if err := bs.Validate(); err == nil || !strings.Contains(err.Error(), "block at index 0: offset -1ns is negative") {
+		t.Fatalf("expected a negative offset validation error, got: %v", err)
+	}
+}
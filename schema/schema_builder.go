@@ -0,0 +1,254 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// MessageVectorBuilder assembles message-class test vectors through a fluent
+// API, keeping every message paired with its expected receipt:
+//
+//	tv, err := NewMessageVector().
+//		WithMeta(Metadata{ID: "transfer"}).
+//		WithCAR(car).
+//		WithPreState(preRoot).
+//		AddMessage(msg, 0).
+//		ExpectReceipt(ExitOK, nil, 1234).
+//		WithPostState(postRoot).
+//		Build()
+//
+// Usage errors are recorded as they happen, and reported by Build.
+type MessageVectorBuilder struct {
+	tv       TestVector
+	receipts []*Receipt
+	err      error
+}
+
+// NewMessageVector returns a builder for a message-class test vector.
+func NewMessageVector() *MessageVectorBuilder {
+	return &MessageVectorBuilder{
+		tv: TestVector{
+			Class: ClassMessage,
+			Pre:   &Preconditions{},
+			Post:  &Postconditions{},
+		},
+	}
+}
+
+// WithMeta sets the metadata of the vector.
+func (b *MessageVectorBuilder) WithMeta(meta Metadata) *MessageVectorBuilder {
+	b.tv.Meta = &meta
+	return b
+}
+
+// WithSelector sets the selector of the vector.
+func (b *MessageVectorBuilder) WithSelector(sel Selector) *MessageVectorBuilder {
+	b.tv.Selector = sel
+	return b
+}
+
+// WithCAR sets the gzipped CAR holding the state trees of the vector.
+func (b *MessageVectorBuilder) WithCAR(car []byte) *MessageVectorBuilder {
+	b.tv.CAR = car
+	return b
+}
+
+// WithVariant adds a variant with which the vector can run.
+func (b *MessageVectorBuilder) WithVariant(v Variant) *MessageVectorBuilder {
+	b.tv.Pre.Variants = append(b.tv.Pre.Variants, v)
+	return b
+}
+
+// WithPreState sets the root of the state tree to seed before applying the
+// messages.
+func (b *MessageVectorBuilder) WithPreState(root cid.Cid) *MessageVectorBuilder {
+	b.tv.Pre.StateTree = &StateTree{RootCID: root}
+	return b
+}
+
+// WithPostState sets the root of the state tree expected after applying the
+// messages.
+func (b *MessageVectorBuilder) WithPostState(root cid.Cid) *MessageVectorBuilder {
+	b.tv.Post.StateTree = &StateTree{RootCID: root}
+	return b
+}
+
+// AddMessage adds a serialized message to apply at the given offset from the
+// variant epoch. It must be followed by a call to ExpectReceipt.
+func (b *MessageVectorBuilder) AddMessage(msg []byte, epochOffset int64) *MessageVectorBuilder {
+	if b.err == nil && len(b.receipts) < len(b.tv.ApplyMessages) {
+		b.err = fmt.Errorf("message at index %d was added before the receipt of the previous message was expected", len(b.tv.ApplyMessages))
+	}
+	b.tv.ApplyMessages = append(b.tv.ApplyMessages, Message{Bytes: msg, EpochOffset: &epochOffset})
+	return b
+}
+
+// AddNullRound adds a null round at the given offset from the variant epoch.
+// Null rounds, like messages, must be added in epoch order.
+func (b *MessageVectorBuilder) AddNullRound(epochOffset int64) *MessageVectorBuilder {
+	b.tv.NullRounds = append(b.tv.NullRounds, epochOffset)
+	return b
+}
+
+// ExpectReceipt sets the receipt expected for the last added message.
+func (b *MessageVectorBuilder) ExpectReceipt(exitCode ExitCode, ret []byte, gasUsed int64) *MessageVectorBuilder {
+	if len(b.receipts) >= len(b.tv.ApplyMessages) {
+		if b.err == nil {
+			b.err = fmt.Errorf("receipt at index %d was expected without a message to pair it with", len(b.receipts))
+		}
+		return b
+	}
+	b.receipts = append(b.receipts, &Receipt{ExitCode: exitCode, ReturnValue: ret, GasUsed: gasUsed})
+	return b
+}
+
+// Build returns the assembled test vector, after validating it.
+func (b *MessageVectorBuilder) Build() (*TestVector, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.receipts) < len(b.tv.ApplyMessages) {
+		return nil, fmt.Errorf("message at index %d has no expected receipt", len(b.receipts))
+	}
+
+	tv := b.tv
+	pre, post := *b.tv.Pre, *b.tv.Post
+	tv.Pre, tv.Post = &pre, &post
+	tv.Post.Receipts = append([]*Receipt(nil), b.receipts...)
+	if err := tv.Validate(); err != nil {
+		return nil, fmt.Errorf("validating test vector: %w", err)
+	}
+	return &tv, nil
+}
+
+// BlockSeqBuilder assembles blockseq-class test vectors. Messages are
+// registered once through RegisterMessage, and the MessageRepo of the vector
+// is populated with the messages the added blocks reference:
+//
+//	b := NewBlockSeqVector(genesisTs)
+//	c, err := b.RegisterMessage(msg)
+//	...
+//	tv, err := b.AddBlock(3*time.Second, blockMsg).
+//		WithChainHead(blockCid).
+//		Build()
+//
+// Usage errors are recorded as they happen, and reported by Build.
+type BlockSeqBuilder struct {
+	tv       TestVector
+	messages map[cid.Cid]Base64EncodedBytes
+	refs     [][]cid.Cid
+	err      error
+}
+
+// NewBlockSeqVector returns a builder for a blockseq-class test vector, whose
+// genesis block has the given timestamp, in seconds since the unix epoch.
+func NewBlockSeqVector(genesisTs uint64) *BlockSeqBuilder {
+	return &BlockSeqBuilder{
+		tv: TestVector{
+			Class:         ClassBlockSeq,
+			Pre:           &Preconditions{PreconditionsBlockSeq: &PreconditionsBlockSeq{GenesisTs: genesisTs}},
+			ApplyBlockseq: &BlockSeq{},
+			Post:          &Postconditions{},
+		},
+		messages: make(map[cid.Cid]Base64EncodedBytes),
+	}
+}
+
+// WithMeta sets the metadata of the vector.
+func (b *BlockSeqBuilder) WithMeta(meta Metadata) *BlockSeqBuilder {
+	b.tv.Meta = &meta
+	return b
+}
+
+// WithCAR sets the gzipped CAR holding the state trees of the vector.
+func (b *BlockSeqBuilder) WithCAR(car []byte) *BlockSeqBuilder {
+	b.tv.CAR = car
+	return b
+}
+
+// WithPreState sets the root of the state tree to seed before blocks arrive.
+func (b *BlockSeqBuilder) WithPreState(root cid.Cid) *BlockSeqBuilder {
+	b.tv.Pre.StateTree = &StateTree{RootCID: root}
+	return b
+}
+
+// WithPostState sets the root of the state tree expected once all blocks have
+// arrived.
+func (b *BlockSeqBuilder) WithPostState(root cid.Cid) *BlockSeqBuilder {
+	b.tv.Post.StateTree = &StateTree{RootCID: root}
+	return b
+}
+
+// WithChainHead sets the CIDs of the blocks expected to form the head of the
+// chain once all blocks have arrived.
+func (b *BlockSeqBuilder) WithChainHead(blocks ...cid.Cid) *BlockSeqBuilder {
+	b.tv.Post.ChainHead = blocks
+	return b
+}
+
+// RegisterMessage makes a serialized message (a Message for BLS messages, or a
+// SignedMessage for secp256k1 messages) available to the blocks of the
+// vector, and returns its CID.
+func (b *BlockSeqBuilder) RegisterMessage(msg []byte) (cid.Cid, error) {
+	c, err := cidBuilder.Sum(msg)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("computing message cid: %w", err)
+	}
+	b.messages[c] = msg
+	return c, nil
+}
+
+// AddBlock adds a serialized BlockMsg that arrives at the given offset from
+// the genesis timestamp. The messages it references must be registered
+// through RegisterMessage before Build is called.
+func (b *BlockSeqBuilder) AddBlock(offset time.Duration, blockMsg []byte) *BlockSeqBuilder {
+	idx := len(b.tv.ApplyBlockseq.Blocks)
+	cids, err := blockMessageCIDs(blockMsg)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("decoding block at index %d: %w", idx, err)
+	}
+	b.tv.ApplyBlockseq.Blocks = append(b.tv.ApplyBlockseq.Blocks, TimestampedRawBlock{
+		OffsetMs: OffsetMillis(offset),
+		Bytes:    blockMsg,
+	})
+	b.refs = append(b.refs, cids)
+	return b
+}
+
+// Build returns the assembled test vector, after validating it. It fails if
+// any block references a message that was never registered; registered
+// messages that no block references are left out of the MessageRepo.
+func (b *BlockSeqBuilder) Build() (*TestVector, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	repo := make(map[cid.Cid]Base64EncodedBytes)
+	for i, cids := range b.refs {
+		for _, c := range cids {
+			msg, ok := b.messages[c]
+			if !ok {
+				return nil, fmt.Errorf("block at index %d references unregistered message %s", i, c)
+			}
+			repo[c] = msg
+		}
+	}
+
+	if len(repo) == 0 {
+		repo = nil
+	}
+
+	tv := b.tv
+	pre, post := *b.tv.Pre, *b.tv.Post
+	bs := BlockSeq{
+		Blocks:      append([]TimestampedRawBlock(nil), b.tv.ApplyBlockseq.Blocks...),
+		MessageRepo: repo,
+	}
+	tv.Pre, tv.Post, tv.ApplyBlockseq = &pre, &post, &bs
+	if err := tv.Validate(); err != nil {
+		return nil, fmt.Errorf("validating test vector: %w", err)
+	}
+	return &tv, nil
+}
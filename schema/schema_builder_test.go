@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestMessageVectorBuilder(t *testing.T) {
+	pre, post := mkCid(t, "pre"), mkCid(t, "post")
+	tv, err := NewMessageVector().
+		WithMeta(Metadata{ID: "built"}).
+		WithCAR([]byte("car")).
+		WithVariant(Variant{ID: "genesis", Epoch: 0, NetworkVersion: 0}).
+		WithPreState(pre).
+		AddMessage([]byte("msg1"), 0).
+		ExpectReceipt(ExitOK, nil, 100).
+		AddMessage([]byte("msg2"), 1).
+		ExpectReceipt(ExitSysErrOutOfGas, []byte("ret"), 200).
+		WithPostState(post).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tv.Class != ClassMessage || tv.Meta.ID != "built" || !tv.Pre.StateTree.RootCID.Equals(pre) || !tv.Post.StateTree.RootCID.Equals(post) {
+		t.Fatalf("unexpected vector: %+v", tv)
+	}
+	if len(tv.ApplyMessages) != 2 || *tv.ApplyMessages[1].EpochOffset != 1 {
+		t.Fatalf("unexpected messages: %+v", tv.ApplyMessages)
+	}
+	if len(tv.Post.Receipts) != 2 || tv.Post.Receipts[1].ExitCode != ExitSysErrOutOfGas || tv.Post.Receipts[1].GasUsed != 200 {
+		t.Fatalf("unexpected receipts: %+v", tv.Post.Receipts)
+	}
+
+	cases := map[string]*MessageVectorBuilder{
+		"message at index 0 has no expected receipt":   NewMessageVector().AddMessage(nil, 0),
+		"message at index 1 was added before":          NewMessageVector().AddMessage(nil, 0).AddMessage(nil, 0),
+		"receipt at index 0 was expected without":      NewMessageVector().ExpectReceipt(ExitOK, nil, 0),
+		"receipt at index 1 was expected without":      NewMessageVector().AddMessage(nil, 0).ExpectReceipt(ExitOK, nil, 0).ExpectReceipt(ExitOK, nil, 0),
+		"receipt at index 0 has negative exit code -1": NewMessageVector().AddMessage(nil, 0).ExpectReceipt(-1, nil, 0),
+	}
+	for expected, b := range cases {
+		if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q, got: %v", expected, err)
+		}
+	}
+}
+
+func TestBlockSeqBuilder(t *testing.T) {
+	b := NewBlockSeqVector(1598306400).WithMeta(Metadata{ID: "built"})
+	var cids []cid.Cid
+	for _, msg := range []string{"bls", "secpk", "orphan"} {
+		c, err := b.RegisterMessage([]byte(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cids = append(cids, c)
+	}
+	if !cids[0].Equals(mkCid(t, "bls")) {
+		t.Fatalf("unexpected message cid %s", cids[0])
+	}
+
+	tv, err := b.
+		AddBlock(time.Second, mkBlockMsg(t, cids[:1], nil)).
+		AddBlock(2*time.Second, mkBlockMsg(t, nil, cids[1:2])).
+		WithChainHead(mkCid(t, "head")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tv.ApplyBlockseq.Blocks) != 2 || tv.ApplyBlockseq.Blocks[1].OffsetMs != OffsetMillis(2*time.Second) {
+		t.Fatalf("unexpected blocks: %+v", tv.ApplyBlockseq.Blocks)
+	}
+	if err := tv.ApplyBlockseq.ValidateRepo(); err != nil {
+		t.Fatal(err)
+	}
+	if orphans, _ := tv.ApplyBlockseq.UnreferencedMessages(); len(orphans) != 0 {
+		t.Fatalf("expected unreferenced messages to be left out of the repo, got %v", orphans)
+	}
+
+	// referencing unregistered messages fails.
+	_, err = b.AddBlock(3*time.Second, mkBlockMsg(t, []cid.Cid{mkCid(t, "unknown")}, nil)).Build()
+	if err == nil || !strings.Contains(err.Error(), "block at index 2 references unregistered message") {
+		t.Fatalf("expected an unregistered message error, got: %v", err)
+	}
+
+	_, err = NewBlockSeqVector(1).AddBlock(0, []byte("garbage")).Build()
+	if err == nil || !strings.Contains(err.Error(), "decoding block at index 0") {
+		t.Fatalf("expected a decoding error, got: %v", err)
+	}
+}
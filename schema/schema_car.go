@@ -0,0 +1,344 @@
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipld/go-car"
+	"github.com/multiformats/go-multihash"
+)
+
+// LoadCAR reads the CAR embedded in this vector, along with its named CARs,
+// into an in-memory blockstore. The CARs may be gzipped, as they are in
+// generated vectors.
+//
+// It fails if the state trees the preconditions and postconditions refer to
+// (including named state trees) are absent from the CAR, as that is a common
+// generation mistake that otherwise surfaces as cryptic block-not-found
+// errors in drivers.
+func (tv TestVector) LoadCAR(ctx context.Context) (blockstore.Blockstore, error) {
+	bs, err := tv.loadCAR(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tv.checkCARRoots(bs, false); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// NewFromCAR returns a skeleton message-class vector embedding the supplied
+// CAR, as recorded from an execution, whose precondition and postcondition
+// state trees are rooted at the given CIDs. Both roots must be present in the
+// CAR. The vector has no messages yet; callers append them to ApplyMessages,
+// along with their receipts.
+func NewFromCAR(car []byte, preRoot, postRoot cid.Cid) (*TestVector, error) {
+	if !preRoot.Defined() || !postRoot.Defined() {
+		return nil, fmt.Errorf("precondition and postcondition state tree roots must be defined")
+	}
+	tv := &TestVector{
+		Class: ClassMessage,
+		CAR:   car,
+		Pre:   &Preconditions{StateTree: &StateTree{RootCID: preRoot}},
+		Post:  &Postconditions{StateTree: &StateTree{RootCID: postRoot}},
+	}
+	if _, err := tv.LoadCAR(context.Background()); err != nil {
+		return nil, err
+	}
+	return tv, nil
+}
+
+// ValidateCARReachability loads the CARs embedded in this vector, and checks
+// that the precondition and postcondition state tree roots (including named
+// state trees), as well as every postcondition receipts root and events root,
+// resolve to blocks in one of them.
+//
+// Genesis vectors (see IsGenesis) must also have a postcondition state tree,
+// holding the genesis state. The actors named by the partial state assertions
+// must be present in the postcondition state tree, if any, and the state diff
+// applied to the precondition state tree must yield the postcondition one.
+//
+// Unlike Validate, it needs to decode the whole CAR, so it's comparatively
+// expensive.
+func (tv TestVector) ValidateCARReachability() error {
+	if tv.IsGenesis() && (tv.Post == nil || tv.Post.StateTree == nil || !tv.Post.StateTree.RootCID.Defined()) {
+		return fmt.Errorf("genesis vector has no postcondition state tree root; its car must hold the genesis state")
+	}
+	bs, err := tv.loadCAR(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := tv.checkCARRoots(bs, true); err != nil {
+		return err
+	}
+	if err := tv.checkPartialState(bs); err != nil {
+		return err
+	}
+	return tv.checkStateDiff(bs)
+}
+
+// loadCAR reads the CARs embedded in this vector into an in-memory
+// blockstore.
+func (tv TestVector) loadCAR(ctx context.Context) (blockstore.Blockstore, error) {
+	if tv.HasHint(HintDetachedCAR) {
+		return nil, fmt.Errorf("the car of the test vector is detached; see MergeCAR")
+	}
+	cars := tv.embeddedCARs()
+	if len(cars) == 0 {
+		return nil, fmt.Errorf("test vector has no car")
+	}
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	for _, c := range cars {
+		if err := readCAR(ctx, c.data, bs); err != nil {
+			if c.name != "" {
+				err = fmt.Errorf("car %q: %w", c.name, err)
+			}
+			return nil, err
+		}
+	}
+	return bs, nil
+}
+
+// namedCAR is a CAR embedded in a vector. The default CAR has no name.
+type namedCAR struct {
+	name string
+	data []byte
+}
+
+// embeddedCARs returns the non-empty CARs of the vector: the default CAR
+// first, then the named CARs, in order of name.
+func (tv TestVector) embeddedCARs() []namedCAR {
+	var ret []namedCAR
+	if len(tv.CAR) > 0 {
+		ret = append(ret, namedCAR{data: tv.CAR})
+	}
+	names := make([]string, 0, len(tv.CARs))
+	for name := range tv.CARs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(tv.CARs[name]) > 0 {
+			ret = append(ret, namedCAR{name: name, data: tv.CARs[name]})
+		}
+	}
+	return ret
+}
+
+// readCAR reads the blocks of the CAR, which may be gzipped, into bs.
+func readCAR(ctx context.Context, data []byte, bs blockstore.Blockstore) error {
+	r, err := openCAR(data)
+	if err != nil {
+		return err
+	}
+	cr, err := car.NewCarReader(r)
+	if err != nil {
+		return fmt.Errorf("reading car header: %w", err)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		blk, err := cr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading car block: %w", err)
+		}
+		if err := bs.Put(blk); err != nil {
+			return err
+		}
+	}
+}
+
+// checkCARRoots checks that the state tree roots of the vector, including the
+// expected state roots of its tipsets, and optionally its receipts roots, are
+// present in the blockstore. It returns an error listing all the missing
+// roots.
+func (tv TestVector) checkCARRoots(bs blockstore.Blockstore, receipts bool) error {
+	type root struct {
+		desc string
+		cid  cid.Cid
+	}
+	var roots []root
+	if tv.Pre != nil && tv.Pre.StateTree != nil {
+		roots = append(roots, root{"precondition state tree root", tv.Pre.StateTree.RootCID})
+	}
+	if tv.Pre != nil {
+		names := make([]string, 0, len(tv.Pre.NamedStateTrees))
+		for name := range tv.Pre.NamedStateTrees {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			roots = append(roots, root{fmt.Sprintf("precondition state tree %q root", name), tv.Pre.NamedStateTrees[name].RootCID})
+		}
+	}
+	if tv.Post != nil && tv.Post.StateTree != nil {
+		roots = append(roots, root{"postcondition state tree root", tv.Post.StateTree.RootCID})
+	}
+	for i, ts := range tv.ApplyTipsets {
+		if ts.ExpectedStateRoot != nil {
+			roots = append(roots, root{fmt.Sprintf("expected state root of tipset at index %d", i), *ts.ExpectedStateRoot})
+		}
+	}
+	if tv.Post != nil && receipts {
+		for i, c := range tv.Post.ReceiptsRoots {
+			roots = append(roots, root{fmt.Sprintf("receipts root at index %d", i), c})
+		}
+		for i, r := range tv.Post.Receipts {
+			if r != nil && r.EventsRoot != nil {
+				roots = append(roots, root{fmt.Sprintf("events root of receipt at index %d", i), *r.EventsRoot})
+			}
+		}
+	}
+
+	var missing []string
+	for _, r := range roots {
+		if !r.cid.Defined() {
+			continue
+		}
+		has, err := bs.Has(r.cid)
+		if err != nil {
+			return err
+		}
+		if !has {
+			missing = append(missing, fmt.Sprintf("%s %s", r.desc, r.cid))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("not present in the car: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// CARRoots returns the roots listed in the header of the CAR embedded in this
+// vector, followed by those of its named CARs, in order of name, without
+// reading their blocks.
+func (tv TestVector) CARRoots() ([]cid.Cid, error) {
+	cars := tv.embeddedCARs()
+	if len(cars) == 0 {
+		return nil, fmt.Errorf("test vector has no car")
+	}
+	var roots []cid.Cid
+	for _, c := range cars {
+		r, err := openCAR(c.data)
+		if err == nil {
+			var h *car.CarHeader
+			if h, _, err = car.ReadHeader(bufio.NewReader(r)); err == nil {
+				roots = append(roots, h.Roots...)
+				continue
+			}
+			err = fmt.Errorf("reading car header: %w", err)
+		}
+		if c.name != "" {
+			err = fmt.Errorf("car %q: %w", c.name, err)
+		}
+		return nil, err
+	}
+	return roots, nil
+}
+
+// validateCARs checks that the named CARs of the vector are named, and
+// non-empty.
+func (tv TestVector) validateCARs() error {
+	for name, c := range tv.CARs {
+		if name == "" {
+			return fmt.Errorf("named car with an empty name")
+		}
+		if len(c) == 0 {
+			return fmt.Errorf("named car %q is empty", name)
+		}
+	}
+	return nil
+}
+
+// checksummedCAR returns the bytes the CARChecksum is computed over: the CAR
+// as stored, followed by the named CARs, in order of name.
+func (tv TestVector) checksummedCAR() []byte {
+	if len(tv.CARs) == 0 {
+		return tv.CAR
+	}
+	var buf bytes.Buffer
+	for _, c := range tv.embeddedCARs() {
+		buf.Write(c.data)
+	}
+	return buf.Bytes()
+}
+
+// SetCARChecksum computes the CARChecksum of the vector over its CARs, as
+// stored, and records it in the metadata, which is created if absent. It must
+// be called whenever a CAR changes, e.g. at the end of generation.
+func (tv *TestVector) SetCARChecksum() error {
+	mh, err := multihash.Sum(tv.checksummedCAR(), cidBuilder.MhType, -1)
+	if err != nil {
+		return fmt.Errorf("hashing car: %w", err)
+	}
+	if tv.Meta == nil {
+		tv.Meta = new(Metadata)
+	}
+	tv.Meta.CARChecksum = mh.B58String()
+	return nil
+}
+
+// VerifyCARChecksum checks the CARs embedded in this vector against the
+// CARChecksum in its metadata, if any. It only hashes the CAR bytes, so it's
+// a cheap way to tell a corrupted CAR from a semantically incorrect one,
+// before decoding it. Vectors whose CAR is detached are checked when it's
+// merged back, by MergeCAR.
+func (tv TestVector) VerifyCARChecksum() error {
+	if tv.Meta == nil || tv.Meta.CARChecksum == "" || tv.HasHint(HintDetachedCAR) {
+		return nil
+	}
+	expected, err := multihash.FromB58String(tv.Meta.CARChecksum)
+	if err != nil {
+		return fmt.Errorf("invalid car checksum %q: %w", tv.Meta.CARChecksum, err)
+	}
+	dec, err := multihash.Decode(expected)
+	if err != nil {
+		return fmt.Errorf("invalid car checksum %q: %w", tv.Meta.CARChecksum, err)
+	}
+	actual, err := multihash.Sum(tv.checksummedCAR(), dec.Code, dec.Length)
+	if err != nil {
+		return fmt.Errorf("hashing car: %w", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		return fmt.Errorf("car checksum mismatch: expected %s, got %s; the car is corrupted", tv.Meta.CARChecksum, actual.B58String())
+	}
+	return nil
+}
+
+// carReader returns a reader over the uncompressed CAR embedded in this
+// vector, excluding its named CARs.
+func (tv TestVector) carReader() (io.Reader, error) {
+	if tv.HasHint(HintDetachedCAR) {
+		return nil, fmt.Errorf("the car of the test vector is detached; see MergeCAR")
+	}
+	if len(tv.CAR) == 0 {
+		return nil, fmt.Errorf("test vector has no car")
+	}
+	return openCAR(tv.CAR)
+}
+
+// openCAR returns a reader over the CAR, decompressing it if it's gzipped.
+func openCAR(data []byte) (io.Reader, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return bytes.NewReader(data), nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing car: %w", err)
+	}
+	return zr, nil
+}
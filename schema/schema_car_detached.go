@@ -0,0 +1,58 @@
+package schema
+
+import "fmt"
+
+// SplitCAR detaches the CAR from the vector, so that large CARs can be stored
+// as separate binary artifacts, rather than base64 in the JSON of the vector.
+// It returns a copy of the vector without its CAR, and marked with
+// HintDetachedCAR, along with the CAR as stored, i.e. usually gzipped. The
+// vector itself is left untouched, and so are its named CARs (see
+// TestVector.CARs), which stay embedded.
+//
+// The returned vector carries a CARChecksum, which is computed if absent, so
+// that MergeCAR can tell whether it's given the CAR it was split from.
+func SplitCAR(tv *TestVector) (meta *TestVector, car []byte, err error) {
+	if tv.HasHint(HintDetachedCAR) {
+		return nil, nil, fmt.Errorf("the car of the test vector is detached already")
+	}
+	if len(tv.CAR) == 0 {
+		return nil, nil, fmt.Errorf("test vector has no car")
+	}
+
+	meta = tv.Clone()
+	if meta.Meta == nil || meta.Meta.CARChecksum == "" {
+		if err := meta.SetCARChecksum(); err != nil {
+			return nil, nil, err
+		}
+	}
+	car, meta.CAR = meta.CAR, nil
+	meta.Hints = append(meta.Hints, HintDetachedCAR)
+	return meta, car, nil
+}
+
+// MergeCAR reattaches a CAR detached by SplitCAR, returning a copy of the
+// vector with the CAR embedded again, and HintDetachedCAR removed. It returns
+// an error if the vector has no detached CAR, or if the CAR doesn't match the
+// CARChecksum of the vector.
+func MergeCAR(meta *TestVector, car []byte) (*TestVector, error) {
+	if !meta.HasHint(HintDetachedCAR) {
+		return nil, fmt.Errorf("the car of the test vector is not detached")
+	}
+
+	tv := meta.Clone()
+	tv.CAR = append([]byte(nil), car...)
+	hints := tv.Hints[:0]
+	for _, h := range tv.Hints {
+		if h != HintDetachedCAR {
+			hints = append(hints, h)
+		}
+	}
+	tv.Hints = hints
+	if len(tv.Hints) == 0 {
+		tv.Hints = nil
+	}
+	if err := tv.VerifyCARChecksum(); err != nil {
+		return nil, fmt.Errorf("merging car: %w", err)
+	}
+	return tv, nil
+}
@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestSplitCAR(t *testing.T) {
+	root := mkCid(t, "a")
+	car, _ := mkCAR(t, []cid.Cid{root}, "a", "b")
+	tv := &TestVector{Class: ClassMessage, CAR: car, Hints: []Hint{HintIncorrect}, Post: &Postconditions{}}
+
+	meta, detached, err := SplitCAR(tv)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(detached, car) || len(meta.CAR) != 0 || !meta.HasHint(HintDetachedCAR) {
+		t.Fatalf("expected the car to be detached, got car %x, hints %v", []byte(meta.CAR), meta.Hints)
+	}
+	if tv.HasHint(HintDetachedCAR) || !bytes.Equal(tv.CAR, car) || tv.Meta != nil {
+		t.Fatal("expected the original vector to be left untouched")
+	}
+	if meta.Meta == nil || meta.Meta.CARChecksum == "" {
+		t.Fatal("expected the detached vector to carry a car checksum")
+	}
+	if err := meta.Validate(); err != nil {
+		t.Fatalf("expected the detached vector to be valid, got: %s", err)
+	}
+	if _, err := meta.LoadCAR(context.Background()); err == nil || !strings.Contains(err.Error(), "detached") {
+		t.Fatalf("expected loading a detached car to fail, got: %v", err)
+	}
+	if _, _, err := SplitCAR(meta); err == nil {
+		t.Fatal("expected splitting a detached vector to fail")
+	}
+
+	// the detached vector round-trips through JSON.
+	b, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded TestVector
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := MergeCAR(&decoded, detached)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(merged.CAR, car) || !reflect.DeepEqual(merged.Hints, []Hint{HintIncorrect}) {
+		t.Fatalf("expected the car to be merged back, got hints %v", merged.Hints)
+	}
+	if _, err := merged.LoadCAR(context.Background()); err != nil {
+		t.Fatalf("unexpected error loading the merged car: %s", err)
+	}
+
+	other, _ := mkCAR(t, []cid.Cid{root}, "a")
+	if _, err := MergeCAR(meta, other); err == nil || !strings.Contains(err.Error(), "car checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got: %v", err)
+	}
+	if _, err := MergeCAR(merged, car); err == nil || !strings.Contains(err.Error(), "not detached") {
+		t.Fatalf("expected merging into a vector with a car to fail, got: %v", err)
+	}
+}
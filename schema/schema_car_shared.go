@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+)
+
+// OptimizeCAR strips the blocks that are present in the shared blockstore
+// from the CAR embedded in the vector, so that suites of vectors built on the
+// same base state can store it once, and embed only their deltas. The roots
+// of the CAR are kept, and so is its compression. Named CARs (see
+// TestVector.CARs) are left untouched.
+//
+// If any block is stripped, the vector is marked with HintExternalBlocks, and
+// it must then be loaded with LoadCARWithShared, against a blockstore holding
+// the stripped blocks. The CARChecksum, if present, is updated too.
+func OptimizeCAR(tv *TestVector, shared blockstore.Blockstore) error {
+	r, err := tv.carReader()
+	if err != nil {
+		return err
+	}
+	cr, err := car.NewCarReader(r)
+	if err != nil {
+		return fmt.Errorf("reading car header: %w", err)
+	}
+
+	var (
+		buf bytes.Buffer
+		w   io.Writer = &buf
+		zw  *gzip.Writer
+	)
+	if bytes.HasPrefix(tv.CAR, gzipMagic) {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	if err := car.WriteHeader(cr.Header, w); err != nil {
+		return fmt.Errorf("writing car header: %w", err)
+	}
+
+	var stripped int
+	for {
+		blk, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading car block: %w", err)
+		}
+		has, err := shared.Has(blk.Cid())
+		if err != nil {
+			return fmt.Errorf("looking up block %s in the shared blockstore: %w", blk.Cid(), err)
+		}
+		if has {
+			stripped++
+			continue
+		}
+		if err := carutil.LdWrite(w, blk.Cid().Bytes(), blk.RawData()); err != nil {
+			return fmt.Errorf("writing car block: %w", err)
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compressing car: %w", err)
+		}
+	}
+
+	if stripped == 0 {
+		return nil
+	}
+	tv.CAR = buf.Bytes()
+	if tv.Meta != nil && tv.Meta.CARChecksum != "" {
+		if err := tv.SetCARChecksum(); err != nil {
+			return err
+		}
+	}
+	if !tv.HasHint(HintExternalBlocks) {
+		tv.Hints = append(tv.Hints, HintExternalBlocks)
+	}
+	return nil
+}
+
+// LoadCARWithShared is like LoadCAR, but the returned blockstore falls back
+// to the shared blockstore for blocks absent from the embedded CAR, which
+// reconstructs the full set of blocks of vectors optimized with OptimizeCAR.
+// Blocks written to the returned blockstore never reach the shared one.
+func (tv TestVector) LoadCARWithShared(ctx context.Context, shared blockstore.Blockstore) (blockstore.Blockstore, error) {
+	local, err := tv.loadCAR(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bs := &layeredBlockstore{Blockstore: local, shared: shared}
+	if err := tv.checkCARRoots(bs, false); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// layeredBlockstore is a blockstore that reads through to a shared
+// blockstore, and writes to its own.
+type layeredBlockstore struct {
+	blockstore.Blockstore
+	shared blockstore.Blockstore
+}
+
+var _ blockstore.Blockstore = (*layeredBlockstore)(nil)
+
+func (bs *layeredBlockstore) Has(c cid.Cid) (bool, error) {
+	if has, err := bs.Blockstore.Has(c); has || err != nil {
+		return has, err
+	}
+	return bs.shared.Has(c)
+}
+
+func (bs *layeredBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	blk, err := bs.Blockstore.Get(c)
+	if err == blockstore.ErrNotFound {
+		return bs.shared.Get(c)
+	}
+	return blk, err
+}
+
+func (bs *layeredBlockstore) GetSize(c cid.Cid) (int, error) {
+	size, err := bs.Blockstore.GetSize(c)
+	if err == blockstore.ErrNotFound {
+		return bs.shared.GetSize(c)
+	}
+	return size, err
+}
+
+// AllKeysChan returns the keys of the blocks in both blockstores; keys of
+// blocks present in both are returned twice.
+func (bs *layeredBlockstore) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	local, err := bs.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := bs.shared.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan cid.Cid)
+	go func() {
+		defer close(ch)
+		for _, keys := range []<-chan cid.Cid{local, shared} {
+			for c := range keys {
+				select {
+				case ch <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
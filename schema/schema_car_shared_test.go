@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestOptimizeCAR(t *testing.T) {
+	pre, post := mkCid(t, "base"), mkCid(t, "delta")
+	data, _ := mkCAR(t, []cid.Cid{pre, post}, "base", "delta")
+	tv := TestVector{
+		CAR:  data,
+		Pre:  &Preconditions{StateTree: &StateTree{RootCID: pre}},
+		Post: &Postconditions{StateTree: &StateTree{RootCID: post}},
+	}
+
+	// nothing to strip.
+	shared := blockstore.NewBlockstore(ds.NewMapDatastore())
+	if err := OptimizeCAR(&tv, shared); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tv.CAR, data) || tv.HasHint(HintExternalBlocks) {
+		t.Fatal("expected the vector to be left untouched")
+	}
+
+	base, err := blocks.NewBlockWithCid([]byte("base"), pre)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shared.Put(base); err != nil {
+		t.Fatal(err)
+	}
+	if err := tv.SetCARChecksum(); err != nil {
+		t.Fatal(err)
+	}
+	if err := OptimizeCAR(&tv, shared); err != nil {
+		t.Fatal(err)
+	}
+	if !tv.HasHint(HintExternalBlocks) || !bytes.HasPrefix(tv.CAR, gzipMagic) {
+		t.Fatal("expected a compressed car, and the vector to be hinted")
+	}
+	if err := tv.VerifyCARChecksum(); err != nil {
+		t.Fatalf("expected the checksum to be updated: %s", err)
+	}
+	if roots, err := tv.CARRoots(); err != nil || len(roots) != 2 {
+		t.Fatalf("expected the car roots to be kept, got: %v, %v", roots, err)
+	}
+
+	if _, err := tv.LoadCAR(context.Background()); err == nil || !strings.Contains(err.Error(), "precondition state tree root") {
+		t.Fatalf("expected the stripped root to be missing, got: %v", err)
+	}
+	bs, err := tv.LoadCARWithShared(context.Background(), shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []cid.Cid{pre, post} {
+		if _, err := bs.Get(c); err != nil {
+			t.Fatalf("getting block %s: %s", c, err)
+		}
+	}
+	if _, err := bs.Get(mkCid(t, "absent")); err != blockstore.ErrNotFound {
+		t.Fatalf("expected a not found error, got: %v", err)
+	}
+
+	var keys int
+	ch, err := bs.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch {
+		keys++
+	}
+	if keys != 2 {
+		t.Fatalf("expected 2 keys, got %d", keys)
+	}
+}
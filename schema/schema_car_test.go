@@ -0,0 +1,255 @@
+package schema
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+)
+
+// mkCAR returns a gzipped CAR with the given roots, holding a block for each
+// of the supplied payloads. It returns the CIDs of the blocks too.
+func mkCAR(t *testing.T, roots []cid.Cid, payloads ...string) ([]byte, []cid.Cid) {
+	var (
+		buf  bytes.Buffer
+		cids []cid.Cid
+		zw   = gzip.NewWriter(&buf)
+	)
+	if err := car.WriteHeader(&car.CarHeader{Roots: roots, Version: 1}, zw); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range payloads {
+		c := mkCid(t, p)
+		if err := util.LdWrite(zw, c.Bytes(), []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+		cids = append(cids, c)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), cids
+}
+
+func TestLoadCAR(t *testing.T) {
+	pre, post := mkCid(t, "pre"), mkCid(t, "post")
+	data, _ := mkCAR(t, []cid.Cid{pre, post}, "pre", "post", "other")
+
+	tv := TestVector{
+		CAR:  data,
+		Pre:  &Preconditions{StateTree: &StateTree{RootCID: pre}},
+		Post: &Postconditions{StateTree: &StateTree{RootCID: post}},
+	}
+	bs, err := tv.LoadCAR(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := bs.Get(mkCid(t, "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blk.RawData()) != "other" {
+		t.Fatalf("unexpected block data %q", blk.RawData())
+	}
+
+	roots, err := tv.CARRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || !roots[0].Equals(pre) || !roots[1].Equals(post) {
+		t.Fatalf("unexpected roots %v", roots)
+	}
+
+	// the postcondition state tree is missing from the car.
+	tv.CAR, _ = mkCAR(t, []cid.Cid{pre}, "pre")
+	if _, err := tv.LoadCAR(context.Background()); err == nil || !strings.Contains(err.Error(), "postcondition state tree root") {
+		t.Fatalf("expected a missing root error, got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tv.LoadCAR(ctx); err != context.Canceled {
+		t.Fatalf("expected a context error, got: %v", err)
+	}
+
+	if _, err := (TestVector{}).LoadCAR(context.Background()); err == nil {
+		t.Fatal("expected an error loading an absent car")
+	}
+}
+
+func TestNamedCARs(t *testing.T) {
+	pre, post := mkCid(t, "pre"), mkCid(t, "post")
+	preCAR, _ := mkCAR(t, []cid.Cid{pre}, "pre")
+	postCAR, _ := mkCAR(t, []cid.Cid{post}, "post")
+
+	tv := TestVector{
+		CAR:  preCAR,
+		CARs: map[string]Base64EncodedBytes{CARPost: postCAR},
+		Pre:  &Preconditions{StateTree: &StateTree{RootCID: pre}},
+		Post: &Postconditions{StateTree: &StateTree{RootCID: post}},
+	}
+	if _, err := tv.LoadCAR(context.Background()); err != nil {
+		t.Fatalf("expected the roots to resolve across the cars, got: %s", err)
+	}
+	if err := tv.ValidateCARReachability(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	roots, err := tv.CARRoots()
+	if err != nil || len(roots) != 2 || !roots[0].Equals(pre) || !roots[1].Equals(post) {
+		t.Fatalf("unexpected roots %v, %v", roots, err)
+	}
+	if s := tv.SizeStats(); s.CAR != len(preCAR)+len(postCAR) {
+		t.Fatalf("expected the named cars to be counted, got %d", s.CAR)
+	}
+
+	// the default car may be left empty.
+	tv.CAR, tv.CARs = nil, map[string]Base64EncodedBytes{CARPre: preCAR, CARPost: postCAR}
+	if _, err := tv.LoadCAR(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := tv.SetCARChecksum(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tv.CARs[CARPost], _ = mkCAR(t, []cid.Cid{post}, "post", "other")
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "car checksum mismatch") {
+		t.Fatalf("expected the checksum to cover the named cars, got: %v", err)
+	}
+	tv.Meta = nil
+
+	tv.CARs[CARPost] = []byte("garbage")
+	if _, err := tv.LoadCAR(context.Background()); err == nil || !strings.Contains(err.Error(), `car "post": reading car header`) {
+		t.Fatalf("expected an error naming the car, got: %v", err)
+	}
+	delete(tv.CARs, CARPost)
+	if _, err := tv.LoadCAR(context.Background()); err == nil || !strings.Contains(err.Error(), "postcondition state tree root") {
+		t.Fatalf("expected a missing root error, got: %v", err)
+	}
+
+	tv.CARs[CARPost] = nil
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), `named car "post" is empty`) {
+		t.Fatalf("expected an empty car error, got: %v", err)
+	}
+	tv.CARs = map[string]Base64EncodedBytes{"": postCAR}
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "named car with an empty name") {
+		t.Fatalf("expected an unnamed car error, got: %v", err)
+	}
+}
+
+func TestValidateCARReachability(t *testing.T) {
+	pre, post, rcpts, events := mkCid(t, "pre"), mkCid(t, "post"), mkCid(t, "receipts"), mkCid(t, "events")
+	data, _ := mkCAR(t, []cid.Cid{pre, post}, "pre", "post", "receipts", "events")
+
+	tv := TestVector{
+		CAR: data,
+		Pre: &Preconditions{StateTree: &StateTree{RootCID: pre}},
+		Post: &Postconditions{
+			StateTree:     &StateTree{RootCID: post},
+			Receipts:      []*Receipt{{Events: []Base64EncodedBytes{{0x80}}, EventsRoot: &events}},
+			ReceiptsRoots: []cid.Cid{rcpts},
+		},
+	}
+	if err := tv.ValidateCARReachability(); err != nil {
+		t.Fatal(err)
+	}
+
+	// all missing roots are reported.
+	tv.CAR, _ = mkCAR(t, []cid.Cid{post}, "post")
+	err := tv.ValidateCARReachability()
+	if err == nil || !strings.Contains(err.Error(), "precondition state tree root "+pre.String()) || !strings.Contains(err.Error(), "receipts root at index 0 "+rcpts.String()) ||
+		!strings.Contains(err.Error(), "events root of receipt at index 0 "+events.String()) {
+		t.Fatalf("expected missing root errors, got: %v", err)
+	}
+
+	// LoadCAR doesn't check receipts roots.
+	tv.CAR, _ = mkCAR(t, []cid.Cid{pre}, "pre", "post")
+	if _, err := tv.LoadCAR(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamedStateTreesReachability(t *testing.T) {
+	pre, snap := mkCid(t, "pre"), mkCid(t, "snapshot")
+	data, _ := mkCAR(t, []cid.Cid{pre, snap}, "pre", "snapshot")
+	tv := TestVector{
+		CAR: data,
+		Pre: &Preconditions{
+			StateTree:       &StateTree{RootCID: pre},
+			NamedStateTrees: map[string]StateTree{"a": {RootCID: snap}},
+		},
+	}
+	if _, err := tv.LoadCAR(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tv.Pre.NamedStateTrees["b"] = StateTree{RootCID: mkCid(t, "missing")}
+	err := tv.ValidateCARReachability()
+	if err == nil || !strings.Contains(err.Error(), `precondition state tree "b" root`) {
+		t.Fatalf("expected a missing named root error, got: %v", err)
+	}
+}
+
+func TestCARChecksum(t *testing.T) {
+	data, _ := mkCAR(t, nil, "block")
+	tv := TestVector{Class: ClassMessage, CAR: data, Post: &Postconditions{}}
+	if err := tv.SetCARChecksum(); err != nil {
+		t.Fatal(err)
+	}
+	if tv.Meta == nil || tv.Meta.CARChecksum == "" {
+		t.Fatal("expected the checksum to be recorded in the metadata")
+	}
+	if err := tv.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tv.CAR = tv.CAR[:len(tv.CAR)-1]
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "car checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got: %v", err)
+	}
+
+	tv.Meta.CARChecksum = "not-a-multihash"
+	if err := tv.VerifyCARChecksum(); err == nil || !strings.Contains(err.Error(), "invalid car checksum") {
+		t.Fatalf("expected an invalid checksum error, got: %v", err)
+	}
+
+	tv.Meta.CARChecksum = ""
+	if err := tv.VerifyCARChecksum(); err != nil {
+		t.Fatalf("expected no verification without a checksum, got: %s", err)
+	}
+}
+
+func TestNewFromCAR(t *testing.T) {
+	pre, post := mkCid(t, "pre"), mkCid(t, "post")
+	data, _ := mkCAR(t, []cid.Cid{pre, post}, "pre", "post")
+
+	tv, err := NewFromCAR(data, pre, post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tv.Class != ClassMessage || !bytes.Equal(tv.CAR, data) || !tv.Pre.StateTree.RootCID.Equals(pre) || !tv.Post.StateTree.RootCID.Equals(post) {
+		t.Fatalf("unexpected vector: %+v", tv)
+	}
+	if err := tv.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tv.ApplyMessages = append(tv.ApplyMessages, Message{Bytes: []byte("msg")})
+	tv.Post.Receipts = append(tv.Post.Receipts, &Receipt{ExitCode: ExitOK})
+	if err := tv.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFromCAR(data, pre, mkCid(t, "other")); err == nil || !strings.Contains(err.Error(), "postcondition state tree root") {
+		t.Fatalf("expected a missing root error, got: %v", err)
+	}
+	if _, err := NewFromCAR(data, pre, cid.Undef); err == nil {
+		t.Fatal("expected an error for an undefined root")
+	}
+}
@@ -0,0 +1,546 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+var (
+	cidType            = reflect.TypeOf(cid.Cid{})
+	addressType        = reflect.TypeOf(address.Address{})
+	tokenAmountType    = reflect.TypeOf(TokenAmount{})
+	offsetMillisType   = reflect.TypeOf(OffsetMillis(0))
+	randomnessRuleType = reflect.TypeOf(RandomnessRule{})
+	messageRepoType    = reflect.TypeOf(MessageRepo(nil))
+)
+
+// EncodeCBOR writes the CBOR encoding of this test vector to the writer.
+//
+// The CBOR form mirrors the JSON form: structs are encoded as maps keyed by
+// their JSON field names (honouring omitempty), and RandomnessRule is encoded
+// as a four-element array. The differences are that Base64EncodedBytes are
+// encoded as raw byte strings, OffsetMillis as unsigned integers of
+// milliseconds, CIDs as dag-cbor links (tag 42), addresses as their byte
+// representation, and TokenAmounts in the Filecoin big integer encoding (a
+// sign byte followed by the big-endian absolute value), or as null if unset. Map entries are
+// emitted in canonical CBOR order (shorter keys first, then bytewise), which
+// makes the encoding of a given vector deterministic.
+func (tv TestVector) EncodeCBOR(w io.Writer) error {
+	return encodeCBOR(w, reflect.ValueOf(tv))
+}
+
+// DecodeCBOR decodes a test vector encoded with TestVector.EncodeCBOR from the
+// reader. The reader is consumed byte by byte when it's not an io.ByteScanner,
+// so callers reading from files or sockets should wrap it in a bufio.Reader.
+func DecodeCBOR(r io.Reader) (*TestVector, error) {
+	var tv TestVector
+	if err := decodeCBOR(cbg.GetPeeker(r), reflect.ValueOf(&tv).Elem()); err != nil {
+		return nil, fmt.Errorf("decoding test vector: %w", err)
+	}
+	return &tv, nil
+}
+
+// cborField is a struct field that is encoded as a CBOR map entry.
+type cborField struct {
+	key       string
+	omitempty bool
+	index     int
+}
+
+// cborFields returns the encodable fields of the struct type, following the
+// naming rules of its JSON tags, in canonical CBOR key order.
+func cborFields(t reflect.Type) []cborField {
+	var ret []cborField
+	for i := 0; i < t.NumField(); i++ {
+		if name, omitempty, ok := jsonFieldName(t.Field(i)); ok {
+			ret = append(ret, cborField{key: name, omitempty: omitempty, index: i})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i].key, ret[j].key
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return ret
+}
+
+// jsonFieldName returns the name under which encoding/json serializes the
+// struct field, and whether it's tagged with omitempty. ok is false if the
+// field is not serialized at all. Embedded structs are treated as regular
+// fields named after their type, unless tagged otherwise.
+func jsonFieldName(f reflect.StructField) (name string, omitempty bool, ok bool) {
+	if f.PkgPath != "" && !f.Anonymous {
+		return "", false, false // unexported.
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts := tag, ""
+	if idx := strings.IndexByte(tag, ','); idx >= 0 {
+		name, opts = tag[:idx], tag[idx+1:]
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, strings.Contains(opts, "omitempty"), true
+}
+
+func encodeCBOR(w io.Writer, v reflect.Value) error {
+	switch v.Type() {
+	case cidType:
+		c := v.Interface().(cid.Cid)
+		if !c.Defined() {
+			_, err := w.Write(cbg.CborNull)
+			return err
+		}
+		return cbg.WriteCid(w, c)
+
+	case addressType:
+		return writeByteString(w, v.Interface().(address.Address).Bytes())
+
+	case tokenAmountType:
+		t := v.Interface().(TokenAmount)
+		if !t.IsSet() {
+			_, err := w.Write(cbg.CborNull)
+			return err
+		}
+		i := t.BigInt()
+		switch i.Sign() {
+		case 0:
+			return writeByteString(w, nil)
+		case 1:
+			return writeByteString(w, append([]byte{0}, i.Bytes()...))
+		default:
+			return writeByteString(w, append([]byte{1}, i.Bytes()...))
+		}
+
+	case offsetMillisType:
+		o := OffsetMillis(v.Int())
+		if err := o.check(); err != nil {
+			return fmt.Errorf("cannot encode offset: %w", err)
+		}
+		return cbg.WriteMajorTypeHeader(w, cbg.MajUnsignedInt, uint64(time.Duration(o).Milliseconds()))
+
+	case randomnessRuleType:
+		if err := cbg.WriteMajorTypeHeader(w, cbg.MajArray, uint64(v.NumField())); err != nil {
+			return err
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if err := encodeCBOR(w, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			_, err := w.Write(cbg.CborNull)
+			return err
+		}
+		return encodeCBOR(w, v.Elem())
+
+	case reflect.Struct:
+		var fields []cborField
+		for _, f := range cborFields(v.Type()) {
+			if f.omitempty && isEmptyValue(v.Field(f.index)) {
+				continue
+			}
+			fields = append(fields, f)
+		}
+		if err := cbg.WriteMajorTypeHeader(w, cbg.MajMap, uint64(len(fields))); err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if err := writeTextString(w, f.key); err != nil {
+				return err
+			}
+			if err := encodeCBOR(w, v.Field(f.index)); err != nil {
+				return fmt.Errorf("%s: %w", f.key, err)
+			}
+		}
+		return nil
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return writeByteString(w, v.Bytes())
+		}
+		if v.IsNil() {
+			_, err := w.Write(cbg.CborNull)
+			return err
+		}
+		if err := cbg.WriteMajorTypeHeader(w, cbg.MajArray, uint64(v.Len())); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeCBOR(w, v.Index(i)); err != nil {
+				return fmt.Errorf("%d: %w", i, err)
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			_, err := w.Write(cbg.CborNull)
+			return err
+		}
+		type entry struct {
+			key []byte
+			val reflect.Value
+		}
+		entries := make([]entry, 0, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			var buf bytes.Buffer
+			if err := encodeCBOR(&buf, iter.Key()); err != nil {
+				return err
+			}
+			entries = append(entries, entry{key: buf.Bytes(), val: iter.Value()})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			a, b := entries[i].key, entries[j].key
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return bytes.Compare(a, b) < 0
+		})
+		if err := cbg.WriteMajorTypeHeader(w, cbg.MajMap, uint64(len(entries))); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if _, err := w.Write(e.key); err != nil {
+				return err
+			}
+			if err := encodeCBOR(w, e.val); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.String:
+		return writeTextString(w, v.String())
+
+	case reflect.Bool:
+		return cbg.WriteBool(w, v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return writeInt64(w, v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cbg.WriteMajorTypeHeader(w, cbg.MajUnsignedInt, v.Uint())
+	}
+
+	return fmt.Errorf("cannot encode value of type %s to cbor", v.Type())
+}
+
+func decodeCBOR(r cbg.BytePeeker, v reflect.Value) error {
+	maj, extra, err := cbg.CborReadHeader(r)
+	if err != nil {
+		return err
+	}
+	return decodeCBORItem(r, maj, extra, v)
+}
+
+// decodeCBORItem decodes the item whose header has already been read into v.
+func decodeCBORItem(r cbg.BytePeeker, maj byte, extra uint64, v reflect.Value) error {
+	null := maj == cbg.MajOther && extra == 22
+
+	switch v.Type() {
+	case cidType:
+		if null {
+			v.Set(reflect.ValueOf(cid.Undef))
+			return nil
+		}
+		if maj != cbg.MajTag || extra != 42 {
+			return fmt.Errorf("expected cbor tag 42 for cid")
+		}
+		maj, extra, err := cbg.CborReadHeader(r)
+		if err != nil {
+			return err
+		}
+		buf, err := readByteString(r, maj, extra)
+		if err != nil {
+			return err
+		}
+		if len(buf) < 2 || buf[0] != 0 {
+			return fmt.Errorf("cbor serialized cids must have binary multibase")
+		}
+		c, err := cid.Cast(buf[1:])
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(c))
+		return nil
+
+	case addressType:
+		buf, err := readByteString(r, maj, extra)
+		if err != nil {
+			return err
+		}
+		addr := address.Undef
+		if len(buf) > 0 {
+			if addr, err = address.NewFromBytes(buf); err != nil {
+				return err
+			}
+		}
+		v.Set(reflect.ValueOf(addr))
+		return nil
+
+	case tokenAmountType:
+		if null {
+			v.Set(reflect.ValueOf(TokenAmount{}))
+			return nil
+		}
+		buf, err := readByteString(r, maj, extra)
+		if err != nil {
+			return err
+		}
+		i := new(big.Int)
+		if len(buf) > 0 {
+			i.SetBytes(buf[1:])
+			switch buf[0] {
+			case 0:
+			case 1:
+				i.Neg(i)
+			default:
+				return fmt.Errorf("invalid token amount sign byte %d", buf[0])
+			}
+		}
+		v.Set(reflect.ValueOf(TokenAmount{i: i}))
+		return nil
+
+	case offsetMillisType:
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("expected cbor unsigned int for offset")
+		}
+		if err := checkMillis(extra); err != nil {
+			return err
+		}
+		v.SetInt(int64(time.Duration(extra) * time.Millisecond))
+		return nil
+
+	case randomnessRuleType:
+		if maj != cbg.MajArray || extra != uint64(v.NumField()) {
+			return fmt.Errorf("expected cbor array of %d elements for randomness rule", v.NumField())
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if err := decodeCBOR(r, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if null {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeCBORItem(r, maj, extra, v.Elem())
+
+	case reflect.Struct:
+		if maj != cbg.MajMap {
+			return fmt.Errorf("expected cbor map for %s", v.Type())
+		}
+		fields := make(map[string]int)
+		for _, f := range cborFields(v.Type()) {
+			fields[f.key] = f.index
+		}
+		for i := uint64(0); i < extra; i++ {
+			key, err := cbg.ReadString(r)
+			if err != nil {
+				return err
+			}
+			idx, ok := fields[key]
+			if !ok {
+				// skip over unknown fields, like encoding/json does.
+				if err := new(cbg.Deferred).UnmarshalCBOR(r); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := decodeCBOR(r, v.Field(idx)); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return nil
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			buf, err := readByteString(r, maj, extra)
+			if err != nil {
+				return err
+			}
+			if len(buf) == 0 {
+				buf = nil
+			}
+			v.SetBytes(buf)
+			return nil
+		}
+		if null {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if maj != cbg.MajArray {
+			return fmt.Errorf("expected cbor array for %s", v.Type())
+		}
+		s := reflect.MakeSlice(v.Type(), 0, 0)
+		for i := uint64(0); i < extra; i++ {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeCBOR(r, elem); err != nil {
+				return fmt.Errorf("%d: %w", i, err)
+			}
+			s = reflect.Append(s, elem)
+		}
+		v.Set(s)
+		return nil
+
+	case reflect.Map:
+		if null {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if maj != cbg.MajMap {
+			return fmt.Errorf("expected cbor map for %s", v.Type())
+		}
+		m := reflect.MakeMap(v.Type())
+		for i := uint64(0); i < extra; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := decodeCBOR(r, key); err != nil {
+				return err
+			}
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeCBOR(r, val); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, val)
+		}
+		v.Set(m)
+		return nil
+
+	case reflect.String:
+		if maj != cbg.MajTextString {
+			return fmt.Errorf("expected cbor text string for %s", v.Type())
+		}
+		buf, err := readBytes(r, extra)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(buf))
+		return nil
+
+	case reflect.Bool:
+		if maj != cbg.MajOther || (extra != 20 && extra != 21) {
+			return fmt.Errorf("expected cbor bool")
+		}
+		v.SetBool(extra == 21)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if (maj != cbg.MajUnsignedInt && maj != cbg.MajNegativeInt) || extra > math.MaxInt64 {
+			return fmt.Errorf("expected cbor int64 for %s", v.Type())
+		}
+		i := int64(extra)
+		if maj == cbg.MajNegativeInt {
+			i = -1 - i
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", i, v.Type())
+		}
+		v.SetInt(i)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("expected cbor unsigned int for %s", v.Type())
+		}
+		if v.OverflowUint(extra) {
+			return fmt.Errorf("value %d overflows %s", extra, v.Type())
+		}
+		v.SetUint(extra)
+		return nil
+	}
+
+	return fmt.Errorf("cannot decode cbor into value of type %s", v.Type())
+}
+
+// isEmptyValue reports whether v is empty according to the omitempty rules of
+// encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func writeByteString(w io.Writer, b []byte) error {
+	if err := cbg.WriteMajorTypeHeader(w, cbg.MajByteString, uint64(len(b))); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func writeInt64(w io.Writer, i int64) error {
+	if i < 0 {
+		return cbg.WriteMajorTypeHeader(w, cbg.MajNegativeInt, uint64(-i-1))
+	}
+	return cbg.WriteMajorTypeHeader(w, cbg.MajUnsignedInt, uint64(i))
+}
+
+func writeTextString(w io.Writer, s string) error {
+	if err := cbg.WriteMajorTypeHeader(w, cbg.MajTextString, uint64(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// readByteString reads the body of the byte string whose header has already
+// been read.
+func readByteString(r io.Reader, maj byte, extra uint64) ([]byte, error) {
+	if maj != cbg.MajByteString {
+		return nil, fmt.Errorf("expected cbor byte string")
+	}
+	return readBytes(r, extra)
+}
+
+// readBytes reads exactly n bytes from the reader. The buffer grows as data
+// arrives, so a bogus length prefix can't trigger a huge allocation upfront.
+func readBytes(r io.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("length %d beyond maximum allowed", n)
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
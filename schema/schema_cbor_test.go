@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+)
+
+// fullTestVector returns a test vector with every field populated.
+func fullTestVector(t *testing.T) *TestVector {
+	var (
+		root        = mkCid(t, "root")
+		epochOffset = int64(-2)
+		miner, _    = address.NewIDAddress(1000)
+	)
+	return &TestVector{
+		Class:    ClassTipset,
+		Selector: Selector{SelectorChaosActor: "true"},
+		Hints:    []Hint{HintIncorrect, HintNegate},
+		Meta: &Metadata{
+			ID:      "full-vector",
+			Version: "v1",
+			Desc:    "a vector with every field populated",
+			Comment: "used in tests",
+			Gen:     []GenerationData{{Source: "test", Version: "v0"}},
+			Tags:    []string{"a", "b"},
+		},
+		CAR: []byte("car bytes"),
+		Randomness: Randomness{{
+			On:     RandomnessRule{Kind: RandomnessChain, DomainSeparationTag: 7, Epoch: 100, Entropy: []byte("entropy")},
+			Return: []byte("random"),
+		}},
+		Pre: &Preconditions{
+			Variants:              []Variant{{ID: "genesis", Epoch: 1, NetworkVersion: 2}},
+			StateTree:             &StateTree{RootCID: root},
+			NamedStateTrees:       map[string]StateTree{"snapshot": {RootCID: root}},
+			BaseFee:               &TokenAmount{i: big.NewInt(100)},
+			CircSupply:            &TokenAmount{i: new(big.Int).Exp(big.NewInt(10), big.NewInt(27), nil)},
+			PreconditionsBlockSeq: &PreconditionsBlockSeq{GenesisTs: 1598306400},
+		},
+		ApplyMessages: []Message{{Bytes: []byte("msg"), EpochOffset: &epochOffset}, {Bytes: []byte("msg2")}},
+		ApplyTipsets: []Tipset{{
+			EpochOffset: 3,
+			BaseFee:     NewTokenAmount(-5),
+			Blocks:      []Block{{MinerAddr: miner, WinCount: 1, Messages: []Base64EncodedBytes{[]byte("msg")}}},
+		}},
+		ApplyBlockseq: &BlockSeq{
+			Blocks:      []TimestampedRawBlock{{OffsetMs: OffsetMillis(1500 * time.Millisecond), Bytes: []byte("block")}},
+			MessageRepo: map[cid.Cid]Base64EncodedBytes{mkCid(t, "msg"): []byte("msg")},
+		},
+		Post: &Postconditions{
+			ApplyMessageFailures: []int{1},
+			StateTree:            &StateTree{RootCID: root},
+			Receipts:             []*Receipt{{ExitCode: 16, ReturnValue: []byte("ret"), GasUsed: 1234, GasBreakdown: map[string]int64{"compute": 1000, "storage": 234}}, nil},
+			ReceiptsRoots:        []cid.Cid{root},
+			ChainHead:            []cid.Cid{root},
+		},
+		Diagnostics: &Diagnostics{Format: "format", Data: []byte("data")},
+	}
+}
+
+func TestCBORCircularSerde(t *testing.T) {
+	tv1 := fullTestVector(t)
+
+	var buf bytes.Buffer
+	if err := tv1.EncodeCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	encoded := append([]byte(nil), buf.Bytes()...)
+
+	tv2, err := DecodeCBOR(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tv1, tv2) {
+		t.Fatalf("values not equal:\n%+v\n%+v", tv1, tv2)
+	}
+
+	// the encoding must be stable.
+	buf.Reset()
+	if err := tv2.EncodeCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, buf.Bytes()) {
+		t.Fatal("re-encoding yielded different bytes")
+	}
+
+	// binary blobs are not base64-encoded, so the cbor form is smaller.
+	if json := tv1.MustMarshalJSON(); len(encoded) >= len(json) {
+		t.Fatalf("expected cbor encoding (%d bytes) to be smaller than json (%d bytes)", len(encoded), len(json))
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	tv1 := fullTestVector(t)
+	fp1, err := tv1.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generation metadata and descriptions don't affect the fingerprint.
+	tv2 := fullTestVector(t)
+	tv2.Meta.ID = "another-id"
+	tv2.Meta.Desc = "another description"
+	tv2.Meta.Comment = ""
+	tv2.Meta.Gen = []GenerationData{{Source: "test", Version: "v1"}}
+	fp2, err := tv2.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fp1.Equals(fp2) {
+		t.Fatalf("expected equal fingerprints, got %s and %s", fp1, fp2)
+	}
+	if tv2.Meta.ID != "another-id" {
+		t.Fatal("fingerprinting mutated the vector")
+	}
+
+	// but the content does.
+	tv2.Post.Receipts[0].GasUsed++
+	fp3, err := tv2.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp1.Equals(fp3) {
+		t.Fatal("expected fingerprints to differ")
+	}
+}
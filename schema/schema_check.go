@@ -0,0 +1,181 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// ExecutionResult is the outcome of executing a vector on an implementation,
+// as checked against the postconditions of the vector by
+// Postconditions.Check.
+type ExecutionResult struct {
+	// StateRoot is the root of the resulting state tree.
+	StateRoot cid.Cid
+
+	// Receipts are the receipts of the applied messages, in the order of
+	// Postconditions.Receipts, with nil for messages that failed to be
+	// applied. ErrorMessage carries the error the implementation reported,
+	// if any, and GasBreakdown the gas components it reported, if it can.
+	Receipts []*Receipt
+
+	// State optionally holds the resulting state, for checks that inspect it.
+	State blockstore.Blockstore
+}
+
+// PostconditionChecker is an implementation-specific postcondition check,
+// registered with RegisterPostconditionChecker, and run by
+// Postconditions.Check for the vectors that name it in CustomChecks.
+type PostconditionChecker interface {
+	// CheckPostconditions returns an error if the result of executing the
+	// vector fails the check.
+	CheckPostconditions(tv *TestVector, res *ExecutionResult) error
+}
+
+// PostconditionCheckerFunc adapts a function to a PostconditionChecker.
+type PostconditionCheckerFunc func(tv *TestVector, res *ExecutionResult) error
+
+// CheckPostconditions calls f(tv, res).
+func (f PostconditionCheckerFunc) CheckPostconditions(tv *TestVector, res *ExecutionResult) error {
+	return f(tv, res)
+}
+
+var postconditionCheckers = struct {
+	sync.RWMutex
+	m map[string]PostconditionChecker
+}{m: make(map[string]PostconditionChecker)}
+
+// RegisterPostconditionChecker registers the checker Postconditions.Check runs
+// for vectors naming it in CustomChecks. It panics if the checker is nil, or
+// if a checker is already registered under the name. Drivers should register
+// their checkers from init functions; registration is nonetheless safe for
+// concurrent use, including with Check.
+func RegisterPostconditionChecker(name string, c PostconditionChecker) {
+	postconditionCheckers.Lock()
+	defer postconditionCheckers.Unlock()
+
+	if c == nil {
+		panic("schema: nil postcondition checker " + name)
+	}
+	if name == "" {
+		panic("schema: postcondition checker registered without a name")
+	}
+	if _, dup := postconditionCheckers.m[name]; dup {
+		panic("schema: postcondition checker " + name + " registered twice")
+	}
+	postconditionCheckers.m[name] = c
+}
+
+// Check checks the result of executing the vector against its
+// postconditions. The built-in checks always run: the state root must match
+// the postcondition state tree root, unless the vector is hinted with
+// HintPostStateUnknown, and the receipts must match the expected ones (see
+// GasMatches and ErrorMatches), component by component for receipts with a
+// GasBreakdown, when the implementation reports one too. The custom checks
+// named by CustomChecks then run in order. It returns the first failure, or an
+// error if a custom check isn't registered.
+//
+// Check reports whether the postconditions are met; for vectors hinted with
+// HintNegate, drivers expect it to fail.
+func (p Postconditions) Check(tv *TestVector, res *ExecutionResult) error {
+	if p.StateTree != nil && p.StateTree.RootCID.Defined() && !tv.HasHint(HintPostStateUnknown) {
+		if !res.StateRoot.Equals(p.StateTree.RootCID) {
+			return fmt.Errorf("state root mismatch: expected %s, got %s", p.StateTree.RootCID, res.StateRoot)
+		}
+	}
+	if err := p.checkReceipts(res.Receipts); err != nil {
+		return err
+	}
+
+	for _, name := range p.CustomChecks {
+		postconditionCheckers.RLock()
+		c, ok := postconditionCheckers.m[name]
+		postconditionCheckers.RUnlock()
+
+		if !ok {
+			return fmt.Errorf("no postcondition checker registered as %q", name)
+		}
+		if err := c.CheckPostconditions(tv, res); err != nil {
+			return fmt.Errorf("custom check %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkReceipts checks the actual receipts against the expected ones.
+func (p Postconditions) checkReceipts(actual []*Receipt) error {
+	if len(actual) != len(p.Receipts) {
+		return fmt.Errorf("expected %d receipts, got %d", len(p.Receipts), len(actual))
+	}
+	for i, expected := range p.Receipts {
+		a := actual[i]
+		switch {
+		case expected == nil && a == nil:
+			continue
+		case expected == nil:
+			return fmt.Errorf("receipt at index %d: expected the message to fail to be applied, got a receipt", i)
+		case a == nil:
+			return fmt.Errorf("receipt at index %d: expected a receipt, got none", i)
+		}
+		if a.ExitCode != expected.ExitCode {
+			return fmt.Errorf("receipt at index %d: exit code mismatch: expected %d, got %d", i, expected.ExitCode, a.ExitCode)
+		}
+		if !bytes.Equal(a.ReturnValue, expected.ReturnValue) {
+			return fmt.Errorf("receipt at index %d: return value mismatch: expected %x, got %x", i, []byte(expected.ReturnValue), []byte(a.ReturnValue))
+		}
+		if !expected.GasMatches(a.GasUsed) {
+			return fmt.Errorf("receipt at index %d: gas used mismatch: expected %d (±%d), got %d", i, expected.GasUsed, expected.GasUsedTolerance, a.GasUsed)
+		}
+		if len(expected.GasBreakdown) > 0 && len(a.GasBreakdown) > 0 {
+			if err := checkGasBreakdown(expected.GasBreakdown, a.GasBreakdown); err != nil {
+				return fmt.Errorf("receipt at index %d: %w", i, err)
+			}
+		}
+		if !expected.ErrorMatches(a.ErrorMessage) {
+			return fmt.Errorf("receipt at index %d: expected error containing %q, got %q", i, expected.ErrorMessage, a.ErrorMessage)
+		}
+	}
+	return nil
+}
+
+// checkGasBreakdown checks that the actual gas breakdown has exactly the
+// expected components.
+func checkGasBreakdown(expected, actual map[string]int64) error {
+	for name, g := range expected {
+		a, ok := actual[name]
+		if !ok {
+			return fmt.Errorf("gas breakdown mismatch: expected %d %s gas, got no %s gas component", g, name, name)
+		}
+		if a != g {
+			return fmt.Errorf("gas breakdown mismatch: expected %d %s gas, got %d", g, name, a)
+		}
+	}
+	for name, a := range actual {
+		if _, ok := expected[name]; !ok {
+			return fmt.Errorf("gas breakdown mismatch: unexpected %s gas component, of %d", name, a)
+		}
+	}
+	return nil
+}
+
+// validateCustomChecks checks that the custom checks of the vector are named,
+// and distinct. Whether they're registered is up to the driver.
+func (tv TestVector) validateCustomChecks() error {
+	if tv.Post == nil {
+		return nil
+	}
+	seen := make(map[string]int, len(tv.Post.CustomChecks))
+	for i, name := range tv.Post.CustomChecks {
+		if name == "" {
+			return fmt.Errorf("custom check at index %d has no name", i)
+		}
+		if j, ok := seen[name]; ok {
+			return fmt.Errorf("custom checks at indices %d and %d are both %q", j, i, name)
+		}
+		seen[name] = i
+	}
+	return nil
+}
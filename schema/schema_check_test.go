@@ -0,0 +1,135 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPostconditionsCheck(t *testing.T) {
+	root := mkCid(t, "post")
+	tv := &TestVector{Post: &Postconditions{
+		StateTree: &StateTree{RootCID: root},
+		Receipts:  []*Receipt{{ExitCode: 16, ReturnValue: []byte{1}, GasUsed: 100, GasUsedTolerance: 5, ErrorMessage: "insufficient funds"}, nil},
+	}}
+	res := func() *ExecutionResult {
+		return &ExecutionResult{
+			StateRoot: root,
+			Receipts:  []*Receipt{{ExitCode: 16, ReturnValue: []byte{1}, GasUsed: 104, ErrorMessage: "send: insufficient funds"}, nil},
+		}
+	}
+	if err := tv.Post.Check(tv, res()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		mutate func(*ExecutionResult)
+		err    string
+	}{
+		{func(r *ExecutionResult) { r.StateRoot = mkCid(t, "other") }, "state root mismatch"},
+		{func(r *ExecutionResult) { r.Receipts = r.Receipts[:1] }, "expected 2 receipts, got 1"},
+		{func(r *ExecutionResult) { r.Receipts[0] = nil }, "receipt at index 0: expected a receipt, got none"},
+		{func(r *ExecutionResult) { r.Receipts[1] = &Receipt{} }, "receipt at index 1: expected the message to fail to be applied"},
+		{func(r *ExecutionResult) { r.Receipts[0].ExitCode = 0 }, "exit code mismatch: expected 16, got 0"},
+		{func(r *ExecutionResult) { r.Receipts[0].ReturnValue = nil }, "return value mismatch"},
+		{func(r *ExecutionResult) { r.Receipts[0].GasUsed = 106 }, "gas used mismatch: expected 100 (±5), got 106"},
+		{func(r *ExecutionResult) { r.Receipts[0].ErrorMessage = "out of gas" }, "expected error containing \"insufficient funds\""},
+	}
+	for _, c := range cases {
+		r := res()
+		c.mutate(r)
+		if err := tv.Post.Check(tv, r); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got: %v", c.err, err)
+		}
+	}
+
+	// gas breakdowns are only checked when the implementation reports one.
+	tv.Post.Receipts[0].GasBreakdown = map[string]int64{"compute": 80, "storage": 20}
+	if err := tv.Post.Check(tv, res()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r := res()
+	r.Receipts[0].GasBreakdown = map[string]int64{"compute": 84, "storage": 20}
+	if err := tv.Post.Check(tv, r); err == nil || !strings.Contains(err.Error(), "receipt at index 0: gas breakdown mismatch: expected 80 compute gas, got 84") {
+		t.Fatalf("expected a gas breakdown mismatch, got: %v", err)
+	}
+	r.Receipts[0].GasBreakdown = map[string]int64{"compute": 80}
+	if err := tv.Post.Check(tv, r); err == nil || !strings.Contains(err.Error(), "expected 20 storage gas, got no storage gas component") {
+		t.Fatalf("expected a missing gas component, got: %v", err)
+	}
+	r.Receipts[0].GasBreakdown = map[string]int64{"compute": 80, "storage": 20, "other": 4}
+	if err := tv.Post.Check(tv, r); err == nil || !strings.Contains(err.Error(), "unexpected other gas component, of 4") {
+		t.Fatalf("expected a gas breakdown mismatch, got: %v", err)
+	}
+	tv.Post.Receipts[0].GasBreakdown = nil
+
+	// the state root isn't checked when it's unknown.
+	tv.Hints = []Hint{HintPostStateUnknown}
+	r = res()
+	r.StateRoot = mkCid(t, "other")
+	if err := tv.Post.Check(tv, r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestPostconditionsCheckCustom(t *testing.T) {
+	var calls []string
+	errBalance := errors.New("balance too low")
+	registerTestChecker(t, "test-ok", PostconditionCheckerFunc(func(tv *TestVector, res *ExecutionResult) error {
+		calls = append(calls, "test-ok")
+		return nil
+	}))
+	registerTestChecker(t, "test-fail", PostconditionCheckerFunc(func(tv *TestVector, res *ExecutionResult) error {
+		calls = append(calls, "test-fail")
+		return errBalance
+	}))
+
+	tv := &TestVector{Post: &Postconditions{CustomChecks: []string{"test-ok", "test-fail"}}}
+	err := tv.Post.Check(tv, &ExecutionResult{})
+	if !errors.Is(err, errBalance) || !strings.Contains(err.Error(), "custom check test-fail") {
+		t.Fatalf("expected the custom check to fail, got: %v", err)
+	}
+	if strings.Join(calls, ",") != "test-ok,test-fail" {
+		t.Fatalf("expected the checks to run in order, got %v", calls)
+	}
+
+	tv.Post.CustomChecks = []string{"test-unregistered"}
+	if err := tv.Post.Check(tv, &ExecutionResult{}); err == nil || !strings.Contains(err.Error(), `no postcondition checker registered as "test-unregistered"`) {
+		t.Fatalf("expected an unregistered check error, got: %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected registering a checker twice to panic")
+			}
+		}()
+		RegisterPostconditionChecker("test-ok", PostconditionCheckerFunc(nil))
+	}()
+}
+
+// registerTestChecker registers a postcondition checker for the duration of
+// the test, so that the test can run repeatedly, e.g. with -count.
+func registerTestChecker(t *testing.T, name string, c PostconditionChecker) {
+	RegisterPostconditionChecker(name, c)
+	t.Cleanup(func() {
+		postconditionCheckers.Lock()
+		defer postconditionCheckers.Unlock()
+		delete(postconditionCheckers.m, name)
+	})
+}
+
+func TestValidateCustomChecks(t *testing.T) {
+	tv := TestVector{Post: &Postconditions{CustomChecks: []string{"a", "b"}}}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tv.Post.CustomChecks = []string{"a", ""}
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "custom check at index 1 has no name") {
+		t.Fatalf("expected an unnamed check error, got: %v", err)
+	}
+	tv.Post.CustomChecks = []string{"a", "b", "a"}
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), `custom checks at indices 0 and 2 are both "a"`) {
+		t.Fatalf("expected a duplicate check error, got: %v", err)
+	}
+}
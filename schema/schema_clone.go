@@ -0,0 +1,67 @@
+package schema
+
+import "reflect"
+
+// Clone returns a deep copy of this test vector. The copy shares no memory
+// with the original, so either can be mutated freely; this includes slices,
+// maps, pointed-to structs, token amounts and binary blobs.
+func (tv TestVector) Clone() *TestVector {
+	ret := new(TestVector)
+	deepCopy(reflect.ValueOf(ret).Elem(), reflect.ValueOf(tv))
+	return ret
+}
+
+// deepCopy copies src into dst, which must be settable and of the same type.
+func deepCopy(dst, src reflect.Value) {
+	switch src.Type() {
+	case cidType, addressType:
+		// immutable.
+		dst.Set(src)
+		return
+	case tokenAmountType:
+		if t := src.Interface().(TokenAmount); t.IsSet() {
+			dst.Set(reflect.ValueOf(NewTokenAmountFromBig(t.i)))
+		}
+		return
+	}
+
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		deepCopy(dst.Elem(), src.Elem())
+
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if f := src.Type().Field(i); f.PkgPath != "" && !f.Anonymous {
+				continue // unexported.
+			}
+			deepCopy(dst.Field(i), src.Field(i))
+		}
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		for iter := src.MapRange(); iter.Next(); {
+			v := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(v, iter.Value())
+			dst.SetMapIndex(iter.Key(), v)
+		}
+
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,29 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	orig := fullTestVector(t)
+	clone := orig.Clone()
+	if !reflect.DeepEqual(orig, clone) {
+		t.Fatalf("clone differs from the original:\n%+v\n%+v", orig, clone)
+	}
+
+	clone.ApplyMessages[0].Bytes[0] ^= 0xff
+	*clone.ApplyMessages[0].EpochOffset = 100
+	clone.Pre.CircSupply.BigInt().SetInt64(1)
+	clone.ApplyTipsets[0].BaseFee.BigInt().SetInt64(1)
+	clone.Post.Receipts[0].GasUsed++
+	clone.Selector["another"] = "true"
+	for c := range clone.ApplyBlockseq.MessageRepo {
+		clone.ApplyBlockseq.MessageRepo[c][0] ^= 0xff
+	}
+	clone.Meta.Gen[0].Version = "v2"
+
+	if !reflect.DeepEqual(orig, fullTestVector(t)) {
+		t.Fatal("mutating the clone affected the original")
+	}
+}
@@ -0,0 +1,166 @@
+package schema
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// DupKind is the kind of duplication a DupGroup reports.
+type DupKind string
+
+const (
+	// DupExact groups vectors with the same Fingerprint: they test the very
+	// same thing, and differ in provenance metadata at most.
+	DupExact DupKind = "exact"
+
+	// DupStructural groups vectors with the same CARs, once decompressed, and
+	// the same messages, which are likely redundant, even though they differ
+	// elsewhere, e.g. in their epochs, selectors or expected receipts.
+	DupStructural DupKind = "structural"
+)
+
+// DupGroup is a group of duplicate vectors found by DedupeSuite.
+type DupGroup struct {
+	Kind DupKind
+
+	// Fingerprint is the key the members share: their Fingerprint for exact
+	// duplicates, or the digest of their CARs and messages for structural
+	// ones.
+	Fingerprint cid.Cid
+
+	// Indices are the indices of the members within the suite, in order, and
+	// IDs their metadata IDs, which are empty for members without one.
+	Indices []int
+	IDs     []string
+}
+
+// DedupeSuite finds the duplicate vectors in the suite, to help prune a
+// corpus of redundant vectors. It returns the groups of exact duplicates,
+// followed by the groups of structural duplicates (see DupKind), each in order
+// of their first member. Structural groups whose members are all exact
+// duplicates of each other are left out, as they'd report the same thing
+// twice. Vectors without duplicates are in no group.
+//
+// Structural duplicates are found by hashing every message and CAR of the
+// vectors, so it's costly on suites with large CARs.
+func DedupeSuite(vs []*TestVector) ([]DupGroup, error) {
+	exact := make([]cid.Cid, len(vs))
+	structural := make([]cid.Cid, len(vs))
+	for i, tv := range vs {
+		if tv == nil {
+			return nil, fmt.Errorf("vector at index %d is nil", i)
+		}
+		var err error
+		if exact[i], err = tv.Fingerprint(); err != nil {
+			return nil, fmt.Errorf("fingerprinting vector at index %d: %w", i, err)
+		}
+		if structural[i], err = tv.structuralFingerprint(); err != nil {
+			return nil, fmt.Errorf("fingerprinting vector at index %d: %w", i, err)
+		}
+	}
+
+	groups := dupGroups(vs, DupExact, exact)
+	for _, g := range dupGroups(vs, DupStructural, structural) {
+		first := exact[g.Indices[0]]
+		for _, i := range g.Indices[1:] {
+			if !exact[i].Equals(first) {
+				groups = append(groups, g)
+				break
+			}
+		}
+	}
+	return groups, nil
+}
+
+// dupGroups groups the vectors by key, returning the groups of more than one
+// vector, in order of their first member.
+func dupGroups(vs []*TestVector, kind DupKind, keys []cid.Cid) []DupGroup {
+	byKey := make(map[cid.Cid]*DupGroup)
+	var order []cid.Cid
+	for i, k := range keys {
+		g, ok := byKey[k]
+		if !ok {
+			g = &DupGroup{Kind: kind, Fingerprint: k}
+			byKey[k] = g
+			order = append(order, k)
+		}
+		g.Indices = append(g.Indices, i)
+		var id string
+		if vs[i].Meta != nil {
+			id = vs[i].Meta.ID
+		}
+		g.IDs = append(g.IDs, id)
+	}
+
+	var ret []DupGroup
+	for _, k := range order {
+		if g := byKey[k]; len(g.Indices) > 1 {
+			ret = append(ret, *g)
+		}
+	}
+	return ret
+}
+
+// structuralFingerprint returns the digest of the decompressed CARs and the
+// serialized messages of the vector, grouped by kind, in order; messages in
+// the repo of a block sequence are ordered by CID.
+func (tv TestVector) structuralFingerprint() (cid.Cid, error) {
+	// writes to the buffer can't fail.
+	var buf bytes.Buffer
+	section := func(name string, n int) {
+		_ = writeTextString(&buf, name)
+		_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajUnsignedInt, uint64(n))
+	}
+
+	cars := tv.embeddedCARs()
+	section("cars", len(cars))
+	for _, c := range cars {
+		r, err := openCAR(c.data)
+		if err != nil {
+			return cid.Undef, err
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return cid.Undef, fmt.Errorf("reading car: %w", err)
+		}
+		_ = writeTextString(&buf, c.name)
+		_ = writeByteString(&buf, h.Sum(nil))
+	}
+
+	section("messages", len(tv.ApplyMessages))
+	for _, m := range tv.ApplyMessages {
+		_ = writeByteString(&buf, m.Bytes)
+	}
+	section("tipsets", len(tv.ApplyTipsets))
+	for _, ts := range tv.ApplyTipsets {
+		section("blocks", len(ts.Blocks))
+		for _, b := range ts.Blocks {
+			section("block messages", len(b.Messages))
+			for _, m := range b.Messages {
+				_ = writeByteString(&buf, m)
+			}
+		}
+	}
+	if bs := tv.ApplyBlockseq; bs != nil {
+		section("blockseq", len(bs.Blocks))
+		for _, b := range bs.Blocks {
+			_ = writeByteString(&buf, b.Bytes)
+		}
+		repo := make([]cid.Cid, 0, len(bs.MessageRepo))
+		for c := range bs.MessageRepo {
+			repo = append(repo, c)
+		}
+		sort.Slice(repo, func(i, j int) bool { return bytes.Compare(repo[i].Bytes(), repo[j].Bytes()) < 0 })
+		section("message repo", len(repo))
+		for _, c := range repo {
+			_ = writeByteString(&buf, bs.MessageRepo[c])
+		}
+	}
+	return cidBuilder.Sum(buf.Bytes())
+}
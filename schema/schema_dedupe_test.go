@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestDedupeSuite(t *testing.T) {
+	root := mkCid(t, "root")
+	car, _ := mkCAR(t, []cid.Cid{root}, "root")
+	mk := func(id string, epoch int64, msgs ...string) *TestVector {
+		tv := &TestVector{Class: ClassMessage, CAR: car, Meta: &Metadata{ID: id}}
+		for _, m := range msgs {
+			tv.ApplyMessages = append(tv.ApplyMessages, Message{Bytes: []byte(m), EpochOffset: &epoch})
+		}
+		return tv
+	}
+
+	vs := []*TestVector{
+		mk("a", 1, "m1", "m2"),
+		mk("b", 1, "m1"),
+		mk("a-copy", 1, "m1", "m2"),
+		mk("a-later", 2, "m1", "m2"),
+		mk("b-copy", 1, "m1"),
+		mk("c", 1, "m2", "m1"),
+	}
+	// the same CAR, uncompressed, is a structural duplicate.
+	recompressed := mk("b-recompressed", 5, "m1")
+	r, err := openCAR(car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recompressed.CAR, err = ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	vs = append(vs, recompressed)
+
+	groups, err := DedupeSuite(vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type group struct {
+		kind DupKind
+		ids  []string
+	}
+	var got []group
+	for _, g := range groups {
+		got = append(got, group{g.Kind, g.IDs})
+	}
+	expected := []group{
+		{DupExact, []string{"a", "a-copy"}},
+		{DupExact, []string{"b", "b-copy"}},
+		{DupStructural, []string{"a", "a-copy", "a-later"}},
+		{DupStructural, []string{"b", "b-copy", "b-recompressed"}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected groups %v, got %v", expected, got)
+	}
+	if !reflect.DeepEqual(groups[2].Indices, []int{0, 2, 3}) {
+		t.Fatalf("unexpected indices %v", groups[2].Indices)
+	}
+
+	// structural groups of exact duplicates only are left out.
+	groups, err = DedupeSuite(vs[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Kind != DupExact {
+		t.Fatalf("expected a single exact group, got %+v", groups)
+	}
+
+	if _, err := DedupeSuite([]*TestVector{vs[0], nil}); err == nil {
+		t.Fatal("expected a nil vector to fail")
+	}
+}
@@ -0,0 +1,146 @@
+package schema
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+const (
+	// DiagnosticsFormatGasTrace is the format of diagnostics holding the gas
+	// charges incurred by each message, as a JSON array of MessageGasTrace.
+	DiagnosticsFormatGasTrace = "gas_trace"
+
+	// DiagnosticsFormatLotusExecutionTraceV1 is the format of diagnostics
+	// holding Lotus execution traces, as gzipped base64-encoded JSON. They
+	// decode into MessageGasTraces, so only the gas charges are retained.
+	DiagnosticsFormatLotusExecutionTraceV1 = "Lotus-ExecutionTrace-V1"
+)
+
+// MessageGasTrace lists the gas charges incurred while applying a message,
+// including those of its subcalls, in order.
+type MessageGasTrace struct {
+	Message cid.Cid     `json:"message,omitempty"`
+	GasUsed int64       `json:"gas_used"`
+	Charges []GasCharge `json:"charges"`
+}
+
+// GasCharge is a single gas charge.
+type GasCharge struct {
+	Name       string `json:"name"`
+	TotalGas   int64  `json:"total_gas"`
+	ComputeGas int64  `json:"compute_gas"`
+	StorageGas int64  `json:"storage_gas"`
+}
+
+var diagnosticsFormats = struct {
+	sync.RWMutex
+	m map[string]func([]byte) (interface{}, error)
+}{m: make(map[string]func([]byte) (interface{}, error))}
+
+func init() {
+	RegisterDiagnosticsFormat(DiagnosticsFormatGasTrace, decodeGasTrace)
+	RegisterDiagnosticsFormat(DiagnosticsFormatLotusExecutionTraceV1, decodeLotusExecutionTrace)
+}
+
+// RegisterDiagnosticsFormat registers the decoder Diagnostics.Decode uses for
+// diagnostics of the named format. It panics if the decoder is nil, or if a
+// decoder is already registered for the format. Formats are meant to be
+// registered from init functions, though registering them concurrently with
+// Decode is safe too.
+func RegisterDiagnosticsFormat(name string, fn func([]byte) (interface{}, error)) {
+	diagnosticsFormats.Lock()
+	defer diagnosticsFormats.Unlock()
+
+	if fn == nil {
+		panic("schema: nil decoder for diagnostics format " + name)
+	}
+	if _, dup := diagnosticsFormats.m[name]; dup {
+		panic("schema: diagnostics format " + name + " registered twice")
+	}
+	diagnosticsFormats.m[name] = fn
+}
+
+// Decode decodes the diagnostics data with the decoder registered for its
+// format.
+func (d Diagnostics) Decode() (interface{}, error) {
+	diagnosticsFormats.RLock()
+	fn, ok := diagnosticsFormats.m[d.Format]
+	diagnosticsFormats.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown diagnostics format %q", d.Format)
+	}
+	ret, err := fn(d.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s diagnostics: %w", d.Format, err)
+	}
+	return ret, nil
+}
+
+// decodeGasTrace decodes DiagnosticsFormatGasTrace diagnostics into a
+// []MessageGasTrace.
+func decodeGasTrace(data []byte) (interface{}, error) {
+	var ret []MessageGasTrace
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// lotusExecutionTrace is the subset of the Lotus ExecutionTrace type that
+// decodeLotusExecutionTrace retains.
+type lotusExecutionTrace struct {
+	Msg struct {
+		CID cid.Cid
+	}
+	MsgRct struct {
+		GasUsed int64
+	}
+	GasCharges []struct {
+		Name       string
+		TotalGas   int64 `json:"tg"`
+		ComputeGas int64 `json:"cg"`
+		StorageGas int64 `json:"sg"`
+	}
+	Subcalls []lotusExecutionTrace
+}
+
+// decodeLotusExecutionTrace decodes DiagnosticsFormatLotusExecutionTraceV1
+// diagnostics into a []MessageGasTrace, one per top-level trace.
+func decodeLotusExecutionTrace(data []byte) (interface{}, error) {
+	zr, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	var traces []lotusExecutionTrace
+	if err := json.NewDecoder(zr).Decode(&traces); err != nil {
+		return nil, err
+	}
+
+	var flatten func(t lotusExecutionTrace, charges []GasCharge) []GasCharge
+	flatten = func(t lotusExecutionTrace, charges []GasCharge) []GasCharge {
+		for _, gc := range t.GasCharges {
+			charges = append(charges, GasCharge(gc))
+		}
+		for _, sub := range t.Subcalls {
+			charges = flatten(sub, charges)
+		}
+		return charges
+	}
+
+	ret := make([]MessageGasTrace, 0, len(traces))
+	for _, t := range traces {
+		ret = append(ret, MessageGasTrace{
+			Message: t.Msg.CID,
+			GasUsed: t.MsgRct.GasUsed,
+			Charges: flatten(t, nil),
+		})
+	}
+	return ret, nil
+}
@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticsDecode(t *testing.T) {
+	// gas traces.
+	d := Diagnostics{
+		Format: DiagnosticsFormatGasTrace,
+		Data:   []byte(`[{"gas_used":10,"charges":[{"name":"OnChainMessage","total_gas":10,"compute_gas":8,"storage_gas":2}]}]`),
+	}
+	v, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []MessageGasTrace{{
+		GasUsed: 10,
+		Charges: []GasCharge{{Name: "OnChainMessage", TotalGas: 10, ComputeGas: 8, StorageGas: 2}},
+	}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, v)
+	}
+
+	// lotus execution traces, encoded like the generator does.
+	var buf bytes.Buffer
+	b64 := base64.NewEncoder(base64.StdEncoding, &buf)
+	zw := gzip.NewWriter(b64)
+	_, _ = zw.Write([]byte(`[{"Msg":{"CID":{"/":"` + mkCid(t, "msg").String() + `"}},"MsgRct":{"GasUsed":30},
+		"GasCharges":[{"Name":"OnChainMessage","tg":10,"cg":10,"sg":0}],
+		"Subcalls":[{"GasCharges":[{"Name":"OnIpldGet","tg":20,"cg":20,"sg":0,"ex":1}],"Subcalls":null}]}]`))
+	_ = zw.Close()
+	_ = b64.Close()
+
+	d = Diagnostics{Format: DiagnosticsFormatLotusExecutionTraceV1, Data: buf.Bytes()}
+	v, err = d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []MessageGasTrace{{
+		Message: mkCid(t, "msg"),
+		GasUsed: 30,
+		Charges: []GasCharge{{Name: "OnChainMessage", TotalGas: 10, ComputeGas: 10}, {Name: "OnIpldGet", TotalGas: 20, ComputeGas: 20}},
+	}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, v)
+	}
+
+	// custom formats.
+	registerTestDiagnosticsFormat(t, "x-test-len", func(b []byte) (interface{}, error) { return len(b), nil })
+	if v, err := (Diagnostics{Format: "x-test-len", Data: []byte("abc")}).Decode(); err != nil || v != 3 {
+		t.Fatalf("unexpected result from custom decoder: %v, %v", v, err)
+	}
+
+	if _, err := (Diagnostics{Format: "unknown"}).Decode(); err == nil || !strings.Contains(err.Error(), `unknown diagnostics format "unknown"`) {
+		t.Fatalf("expected an unknown format error, got: %v", err)
+	}
+	if _, err := (Diagnostics{Format: DiagnosticsFormatGasTrace, Data: []byte("{")}).Decode(); err == nil {
+		t.Fatal("expected a decoding error")
+	}
+}
+
+func TestRegisterDiagnosticsFormatTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a format twice to panic")
+		}
+	}()
+	RegisterDiagnosticsFormat(DiagnosticsFormatGasTrace, decodeGasTrace)
+}
+
+// registerTestDiagnosticsFormat registers a diagnostics format for the
+// duration of the test, so that the test can run repeatedly, e.g. with -count.
+func registerTestDiagnosticsFormat(t *testing.T, name string, fn func([]byte) (interface{}, error)) {
+	RegisterDiagnosticsFormat(name, fn)
+	t.Cleanup(func() {
+		diagnosticsFormats.Lock()
+		defer diagnosticsFormats.Unlock()
+		delete(diagnosticsFormats.m, name)
+	})
+}
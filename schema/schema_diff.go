@@ -0,0 +1,240 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+)
+
+// absent is the value reported by Diff for elements that only exist on one
+// side of the comparison.
+const absent = "<absent>"
+
+// FieldDiff is a difference between two test vectors, found at the field
+// identified by the Path (e.g. "postconditions.receipts[0].gas_used"). Old
+// and New are human-readable renderings of the values on each side.
+type FieldDiff struct {
+	Path string
+	Old  string
+	New  string
+}
+
+// String formats the difference as "path: old -> new".
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Path, d.Old, d.New)
+}
+
+// Diff compares two test vectors field by field, returning their differences
+// in field order. Paths are built from JSON field names. Metadata (_meta) is
+// not compared.
+//
+// The comparison follows the rules of TestVector.Equal, so Diff returns no
+// differences iff the vectors are equal. Binary blobs are summarized by their
+// length and a hash prefix, rather than dumped. Slice elements and map entries
+// present on only one side are reported as a single difference against
+// "<absent>".
+func Diff(before, after *TestVector) ([]FieldDiff, error) {
+	if before == nil || after == nil {
+		return nil, fmt.Errorf("cannot diff a nil test vector")
+	}
+	a, b := *before, *after
+	a.Meta, b.Meta = nil, nil
+
+	var diffs []FieldDiff
+	if err := diffValues(&diffs, "", reflect.ValueOf(a), reflect.ValueOf(b)); err != nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+func diffValues(diffs *[]FieldDiff, path string, a, b reflect.Value) error {
+	leaf := func() error {
+		*diffs = append(*diffs, FieldDiff{Path: path, Old: renderValue(a), New: renderValue(b)})
+		return nil
+	}
+
+	switch a.Type() {
+	case cidType, addressType, tokenAmountType:
+		if !equalValues(a, b) {
+			return leaf()
+		}
+		return nil
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() && b.IsNil() {
+			return nil
+		}
+		if a.IsNil() {
+			a = reflect.New(a.Type().Elem())
+		}
+		if b.IsNil() {
+			b = reflect.New(b.Type().Elem())
+		}
+		return diffValues(diffs, path, a.Elem(), b.Elem())
+
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			name, _, ok := jsonFieldName(a.Type().Field(i))
+			if !ok {
+				continue
+			}
+			p := name
+			if path != "" {
+				p = path + "." + name
+			}
+			if err := diffValues(diffs, p, a.Field(i), b.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			if !equalValues(a, b) {
+				return leaf()
+			}
+			return nil
+		}
+		for i := 0; i < a.Len() || i < b.Len(); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= a.Len():
+				*diffs = append(*diffs, FieldDiff{Path: p, Old: absent, New: renderValue(b.Index(i))})
+			case i >= b.Len():
+				*diffs = append(*diffs, FieldDiff{Path: p, Old: renderValue(a.Index(i)), New: absent})
+			default:
+				if err := diffValues(diffs, p, a.Index(i), b.Index(i)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		seen := make(map[string]reflect.Value)
+		for _, k := range append(a.MapKeys(), b.MapKeys()...) {
+			seen[renderMapKey(k)] = k
+		}
+		keys := make([]string, 0, len(seen))
+		for k := range seen {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			var (
+				p      = fmt.Sprintf("%s[%s]", path, k)
+				av, bv = a.MapIndex(seen[k]), b.MapIndex(seen[k])
+			)
+			switch {
+			case !av.IsValid():
+				*diffs = append(*diffs, FieldDiff{Path: p, Old: absent, New: renderValue(bv)})
+			case !bv.IsValid():
+				*diffs = append(*diffs, FieldDiff{Path: p, Old: renderValue(av), New: absent})
+			default:
+				if err := diffValues(diffs, p, av, bv); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if a.Interface() != b.Interface() {
+			return leaf()
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: cannot diff values of type %s", path, a.Type())
+}
+
+// renderValue formats the value for display in a FieldDiff. Structs are
+// rendered with their JSON field names, and binary blobs are summarized.
+func renderValue(v reflect.Value) string {
+	switch v.Type() {
+	case cidType:
+		c := v.Interface().(cid.Cid)
+		if !c.Defined() {
+			return "null"
+		}
+		return c.String()
+	case addressType:
+		a := v.Interface().(address.Address)
+		if a == address.Undef {
+			return `""`
+		}
+		return a.String()
+	case tokenAmountType:
+		if t := v.Interface().(TokenAmount); !t.IsSet() {
+			return "null"
+		}
+		return v.Interface().(TokenAmount).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "null"
+		}
+		return renderValue(v.Elem())
+
+	case reflect.Struct:
+		var fields []string
+		for i := 0; i < v.NumField(); i++ {
+			if name, omitempty, ok := jsonFieldName(v.Type().Field(i)); ok && !(omitempty && isEmptyValue(v.Field(i))) {
+				fields = append(fields, name+": "+renderValue(v.Field(i)))
+			}
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return summarizeBytes(v.Bytes())
+		}
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = renderValue(v.Index(i))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+
+	case reflect.Map:
+		entries := make([]string, 0, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			entries = append(entries, renderValue(iter.Key())+": "+renderValue(iter.Value()))
+		}
+		sort.Strings(entries)
+		return "{" + strings.Join(entries, ", ") + "}"
+
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// renderMapKey formats the map key for use in a path. Unlike renderValue,
+// string keys are not quoted.
+func renderMapKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	return renderValue(k)
+}
+
+// summarizeBytes describes a binary blob by its length and the prefix of its
+// sha256 hash.
+func summarizeBytes(b []byte) string {
+	if len(b) == 0 {
+		return "<0 bytes>"
+	}
+	h := sha256.Sum256(b)
+	return fmt.Sprintf("<%d bytes, sha256:%x>", len(b), h[:4])
+}
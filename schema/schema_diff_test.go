@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tv1, tv2 := fullTestVector(t), fullTestVector(t)
+	diffs, err := Diff(tv1, tv2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("expected no differences, got %v", diffs)
+	}
+
+	tv2.Meta.ID = "ignored"
+	tv2.CAR = []byte("other car bytes")
+	tv2.Post.Receipts[0].GasUsed = 1350
+	tv2.Post.Receipts[0].ExitCode = 0
+	tv2.Post.Receipts = append(tv2.Post.Receipts, &Receipt{GasUsed: 1})
+	tv2.Post.StateTree.RootCID = mkCid(t, "other root")
+	tv2.Post.ApplyMessageFailures = nil
+	tv2.Selector = nil
+
+	diffs, err = Diff(tv1, tv2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diffs {
+		got = append(got, d.String())
+	}
+	expected := []string{
+		`selector[chaos_actor]: "true" -> <absent>`,
+		`car: <9 bytes, sha256:d047eb3b> -> <15 bytes, sha256:cde4d149>`,
+		`postconditions.apply_message_failures[0]: 1 -> <absent>`,
+		`postconditions.state_tree.root_cid: ` + tv1.Post.StateTree.RootCID.String() + ` -> ` + tv2.Post.StateTree.RootCID.String(),
+		`postconditions.receipts[0].exit_code: ExitCode(16) -> Ok`,
+		`postconditions.receipts[0].gas_used: 1234 -> 1350`,
+		`postconditions.receipts[2]: <absent> -> {exit_code: Ok, return: <0 bytes>, gas_used: 1}`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected differences:\n%q\nexpected:\n%q", got, expected)
+	}
+
+	if _, err := Diff(tv1, nil); err == nil {
+		t.Fatal("expected an error when diffing against nil")
+	}
+}
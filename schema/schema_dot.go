@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// RenderDOT renders the structure of the vector as a Graphviz DOT graph, for
+// reviewers and documentation. For tipset vectors, the graph chains the
+// tipsets in order of application, each labelled with its epoch offset and
+// base fee, and pointing at its blocks, labelled with their miner, win count
+// and message count. For blockseq vectors, it's a timeline of the blocks in
+// order of arrival, labelled with their arrival offset and message count.
+//
+// It returns an error for vectors of other classes, which have no structure to
+// render, and for blockseq vectors carrying malformed blocks. Nothing is
+// written to w in either case.
+func (tv TestVector) RenderDOT(w io.Writer) error {
+	var buf bytes.Buffer
+	switch tv.Class {
+	case ClassTipset:
+		tv.renderTipsetsDOT(&buf)
+	case ClassBlockSeq:
+		if err := tv.renderBlockSeqDOT(&buf); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("rendering %s vectors as dot graphs is not supported", tv.Class)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// renderTipsetsDOT renders the tipsets of a tipset vector.
+func (tv TestVector) renderTipsetsDOT(buf *bytes.Buffer) {
+	buf.WriteString("digraph tipsets {\n")
+	buf.WriteString("\trankdir=LR;\n")
+	for i, ts := range tv.ApplyTipsets {
+		fmt.Fprintf(buf, "\tts%d [shape=box, label=%q];\n", i, fmt.Sprintf("tipset %d\nepoch offset %d\nbase fee %s", i, ts.EpochOffset, ts.BaseFee))
+		if i > 0 {
+			fmt.Fprintf(buf, "\tts%d -> ts%d [style=bold];\n", i-1, i)
+		}
+		for j, b := range ts.Blocks {
+			fmt.Fprintf(buf, "\tts%d_b%d [label=%q];\n", i, j, fmt.Sprintf("miner %s\nwin count %d\n%s", b.MinerAddr, b.WinCount, pluralMessages(len(b.Messages))))
+			fmt.Fprintf(buf, "\tts%d -> ts%d_b%d [style=dashed];\n", i, i, j)
+		}
+	}
+	buf.WriteString("}\n")
+}
+
+// renderBlockSeqDOT renders the block arrivals of a blockseq vector.
+func (tv TestVector) renderBlockSeqDOT(buf *bytes.Buffer) error {
+	buf.WriteString("digraph blockseq {\n")
+	buf.WriteString("\trankdir=LR;\n")
+	buf.WriteString("\tgenesis [shape=box, label=\"genesis\"];\n")
+	if tv.ApplyBlockseq != nil {
+		prev := "genesis"
+		for i, b := range tv.ApplyBlockseq.Blocks {
+			cids, err := blockMessageCIDs(b.Bytes)
+			if err != nil {
+				return fmt.Errorf("decoding block at index %d: %w", i, err)
+			}
+			fmt.Fprintf(buf, "\tb%d [label=%q];\n", i, fmt.Sprintf("block %d\n+%s\n%s", i, time.Duration(b.OffsetMs), pluralMessages(len(cids))))
+			fmt.Fprintf(buf, "\t%s -> b%d;\n", prev, i)
+			prev = fmt.Sprintf("b%d", i)
+		}
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+// pluralMessages returns the count of messages, worded for a label.
+func pluralMessages(n int) string {
+	if n == 1 {
+		return "1 message"
+	}
+	return fmt.Sprintf("%d messages", n)
+}
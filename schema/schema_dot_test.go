@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestRenderDOT(t *testing.T) {
+	miner := mustIDAddress(t, 1000)
+	tv := TestVector{
+		Class: ClassTipset,
+		ApplyTipsets: []Tipset{
+			{EpochOffset: 1, BaseFee: NewTokenAmount(100), Blocks: []Block{
+				{MinerAddr: miner, WinCount: 1, Messages: []Base64EncodedBytes{{1}}},
+				{MinerAddr: miner, WinCount: 2},
+			}},
+			{EpochOffset: 2, BaseFee: NewTokenAmount(110), Blocks: []Block{
+				{MinerAddr: miner, WinCount: 1, Messages: []Base64EncodedBytes{{1}, {2}}},
+			}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := tv.RenderDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph tipsets {
+	rankdir=LR;
+	ts0 [shape=box, label="tipset 0\nepoch offset 1\nbase fee 100"];
+	ts0_b0 [label="miner t01000\nwin count 1\n1 message"];
+	ts0 -> ts0_b0 [style=dashed];
+	ts0_b1 [label="miner t01000\nwin count 2\n0 messages"];
+	ts0 -> ts0_b1 [style=dashed];
+	ts1 [shape=box, label="tipset 1\nepoch offset 2\nbase fee 110"];
+	ts0 -> ts1 [style=bold];
+	ts1_b0 [label="miner t01000\nwin count 1\n2 messages"];
+	ts1 -> ts1_b0 [style=dashed];
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	a, b := mkCid(t, "a"), mkCid(t, "b")
+	tv = TestVector{
+		Class: ClassBlockSeq,
+		ApplyBlockseq: &BlockSeq{Blocks: []TimestampedRawBlock{
+			{OffsetMs: OffsetMillis(0), Bytes: mkBlockMsg(t, []cid.Cid{a}, []cid.Cid{b})},
+			{OffsetMs: OffsetMillis(1500 * time.Millisecond), Bytes: mkBlockMsg(t, nil, nil)},
+		}},
+	}
+	buf.Reset()
+	if err := tv.RenderDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected = `digraph blockseq {
+	rankdir=LR;
+	genesis [shape=box, label="genesis"];
+	b0 [label="block 0\n+0s\n2 messages"];
+	genesis -> b0;
+	b1 [label="block 1\n+1.5s\n0 messages"];
+	b0 -> b1;
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	tv.ApplyBlockseq.Blocks[1].Bytes = []byte{0}
+	buf.Reset()
+	if err := tv.RenderDOT(&buf); err == nil || !strings.Contains(err.Error(), "decoding block at index 1") || buf.Len() != 0 {
+		t.Fatalf("expected a decoding error, and no output, got: %v", err)
+	}
+	if err := (TestVector{Class: ClassMessage}).RenderDOT(&buf); err == nil {
+		t.Fatal("expected rendering a message vector to fail")
+	}
+}
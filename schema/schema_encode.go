@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EncodeJSON writes the test vector to w as JSON, producing the same bytes as
+// MarshalJSONDeterministic, but without holding the encoded CAR in memory:
+// the CAR, which accounts for the bulk of most vectors, is base64-encoded
+// straight into w. The remaining fields are encoded in memory first.
+func EncodeJSON(w io.Writer, tv *TestVector) error {
+	rest := *tv
+	rest.CAR = nil
+	b, err := rest.MarshalJSONDeterministic()
+	if err != nil {
+		return fmt.Errorf("encoding test vector: %w", err)
+	}
+
+	start, end, err := topLevelValueOffsets(b, "car")
+	if err != nil {
+		return fmt.Errorf("encoding test vector: %w", err)
+	}
+	if _, err := w.Write(b[:start]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `"`); err != nil {
+		return err
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := enc.Write(tv.CAR); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `"`); err != nil {
+		return err
+	}
+	_, err = w.Write(b[end:])
+	return err
+}
+
+// topLevelValueOffsets returns the offsets within the JSON object b at which
+// the value of the top-level key starts and ends.
+func topLevelValueOffsets(b []byte, key string) (start, end int, err error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, 0, fmt.Errorf("expected a json object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, err
+		}
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return 0, 0, err
+		}
+		if tok == key {
+			end := int(dec.InputOffset())
+			return end - len(v), end, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no %q key in json object", key)
+}
@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeJSON(t *testing.T) {
+	tv := fullTestVector(t)
+	// a selector entry mimicking the car field mustn't confuse the encoder.
+	tv.Selector["car"] = ""
+
+	for _, car := range [][]byte{nil, []byte("c"), tv.CAR, bytes.Repeat([]byte{0xff}, 1<<16)} {
+		tv.CAR = car
+		var buf bytes.Buffer
+		if err := EncodeJSON(&buf, tv); err != nil {
+			t.Fatal(err)
+		}
+		if expected := tv.MustMarshalJSON(); !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.Bytes())
+		}
+	}
+}
+
+// largeVector returns a vector embedding a CAR of the given size.
+func largeVector(b *testing.B, size int) *TestVector {
+	car := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(car)
+	tv, err := LoadTestVector(bytes.NewReader([]byte(testMessageVector)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	tv.CAR = car
+	return tv
+}
+
+func BenchmarkEncodeJSON(b *testing.B) {
+	tv := largeVector(b, 64<<20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := EncodeJSON(ioutil.Discard, tv); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalJSONDeterministic(b *testing.B) {
+	tv := largeVector(b, 64<<20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out, err := tv.MarshalJSONDeterministic()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := ioutil.Discard.Write(out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
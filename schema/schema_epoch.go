@@ -0,0 +1,115 @@
+package schema
+
+import "fmt"
+
+// MessageEpoch returns the epoch at which the i-th message to apply must be
+// applied when running the variant with the given ID: the variant epoch plus
+// the epoch offset of the message, which defaults to 0 when absent.
+//
+// It returns an error if the message doesn't exist, or if the vector has no
+// such variant.
+func (tv TestVector) MessageEpoch(i int, variantID string) (int64, error) {
+	if i < 0 || i >= len(tv.ApplyMessages) {
+		return 0, fmt.Errorf("message index %d out of range; vector has %d messages", i, len(tv.ApplyMessages))
+	}
+	if tv.Pre == nil {
+		return 0, fmt.Errorf("vector has no preconditions, hence no variants")
+	}
+	for _, v := range tv.Pre.Variants {
+		if v.ID != variantID {
+			continue
+		}
+		return v.Epoch + tv.ApplyMessages[i].epochOffset(), nil
+	}
+	return 0, fmt.Errorf("vector has no variant %q", variantID)
+}
+
+// epochOffset returns the epoch offset of the message, which defaults to 0.
+func (m Message) epochOffset() int64 {
+	if m.EpochOffset == nil {
+		return 0
+	}
+	return *m.EpochOffset
+}
+
+// IsNullRound reports whether the epoch offset is one of the NullRounds of
+// this vector.
+func (tv TestVector) IsNullRound(offset int64) bool {
+	for _, r := range tv.NullRounds {
+		if r == offset {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGenesis reports whether this is a genesis vector, i.e. one that builds the
+// initial state of a chain rather than applying on top of an existing one.
+// That's the case when the vector has no precondition state tree root (either
+// because it has no preconditions, no state tree, or an undefined root), and
+// all its variants, if any, are at epoch 0.
+//
+// The genesis state such vectors produce is their postcondition state tree,
+// which ValidateCARReachability requires to be present in the CAR.
+func (tv TestVector) IsGenesis() bool {
+	if tv.Pre == nil {
+		return true
+	}
+	if tv.Pre.StateTree != nil && tv.Pre.StateTree.RootCID.Defined() {
+		return false
+	}
+	for _, v := range tv.Pre.Variants {
+		if v.Epoch != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// EpochRange returns the lowest and highest epochs at which this vector
+// applies messages or tipsets, across all its variants: messages and tipsets
+// apply at the epoch of the variant, plus their epoch offset. Null rounds
+// count as epochs the vector covers, and vectors with nothing to apply cover
+// the epochs of their variants.
+//
+// The range bounds the epochs of every variant together, so for vectors with
+// distant variants, it spans epochs none of them touch. It's only defined for
+// vectors with variants, and never for blockseq vectors, whose blocks arrive
+// at wall-clock offsets rather than epochs; ok is false otherwise.
+func (tv TestVector) EpochRange() (min, max int64, ok bool) {
+	if tv.Class == ClassBlockSeq || tv.Pre == nil || len(tv.Pre.Variants) == 0 {
+		return 0, 0, false
+	}
+
+	var offsets []int64
+	for _, m := range tv.ApplyMessages {
+		offsets = append(offsets, m.epochOffset())
+	}
+	for _, ts := range tv.ApplyTipsets {
+		offsets = append(offsets, ts.EpochOffset)
+	}
+	offsets = append(offsets, tv.NullRounds...)
+	if len(offsets) == 0 {
+		offsets = []int64{0}
+	}
+
+	lo, hi := offsets[0], offsets[0]
+	for _, o := range offsets[1:] {
+		if o < lo {
+			lo = o
+		}
+		if o > hi {
+			hi = o
+		}
+	}
+	min, max = tv.Pre.Variants[0].Epoch+lo, tv.Pre.Variants[0].Epoch+hi
+	for _, v := range tv.Pre.Variants[1:] {
+		if e := v.Epoch + lo; e < min {
+			min = e
+		}
+		if e := v.Epoch + hi; e > max {
+			max = e
+		}
+	}
+	return min, max, true
+}
@@ -0,0 +1,185 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestMessageEpoch(t *testing.T) {
+	offset := int64(5)
+	tv := TestVector{
+		Pre:           &Preconditions{Variants: []Variant{{ID: "genesis", Epoch: 0}, {ID: "actorsv2", Epoch: 170000}}},
+		ApplyMessages: []Message{{}, {EpochOffset: &offset}},
+	}
+
+	for _, c := range []struct {
+		i        int
+		variant  string
+		expected int64
+	}{
+		{0, "genesis", 0},
+		{1, "genesis", 5},
+		{0, "actorsv2", 170000},
+		{1, "actorsv2", 170005},
+	} {
+		epoch, err := tv.MessageEpoch(c.i, c.variant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if epoch != c.expected {
+			t.Errorf("expected message %d of variant %s to apply at epoch %d, got %d", c.i, c.variant, c.expected, epoch)
+		}
+	}
+
+	if _, err := tv.MessageEpoch(2, "genesis"); err == nil {
+		t.Error("expected an error for an out of range message")
+	}
+	if _, err := tv.MessageEpoch(0, "breeze"); err == nil {
+		t.Error("expected an error for an unknown variant")
+	}
+	if _, err := (TestVector{ApplyMessages: []Message{{}}}).MessageEpoch(0, "genesis"); err == nil {
+		t.Error("expected an error without preconditions")
+	}
+}
+
+func TestValidateNullRounds(t *testing.T) {
+	mk := func(offsets ...int64) TestVector {
+		msgs := make([]Message, len(offsets))
+		for i := range offsets {
+			msgs[i] = Message{EpochOffset: &offsets[i]}
+		}
+		return TestVector{
+			Class:         ClassMessage,
+			ApplyMessages: msgs,
+			NullRounds:    []int64{1, 3, 4},
+			Post:          &Postconditions{Receipts: make([]*Receipt, len(msgs))},
+		}
+	}
+	tv := mk(0, 2, 2, 5)
+	if err := tv.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !tv.IsNullRound(3) || tv.IsNullRound(2) {
+		t.Fatal("unexpected null rounds")
+	}
+
+	for _, c := range []struct {
+		name       string
+		tv         TestVector
+		nullRounds []int64
+		err        string
+	}{
+		{"unordered messages", mk(0, 2, 0), nil, "message at index 2 has epoch offset 0, which precedes the epoch offset 2"},
+		{"unordered null rounds", mk(0), []int64{1, 4, 3}, "null round at index 2 has epoch offset 3"},
+		{"repeated null rounds", mk(0), []int64{1, 1}, "null round at index 1 has epoch offset 1"},
+		{"message in a null round", mk(0, 2), []int64{1, 2}, "message at index 1 is applied at epoch offset 2, which is a null round"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if c.nullRounds != nil {
+				c.tv.NullRounds = c.nullRounds
+			}
+			if err := c.tv.Validate(); err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got: %v", c.err, err)
+			}
+		})
+	}
+
+	tv.Class, tv.ApplyMessages = ClassTipset, nil
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "only message vectors can have null rounds") {
+		t.Fatalf("expected a class error, got: %v", err)
+	}
+}
+
+func TestIsGenesis(t *testing.T) {
+	root := mkCid(t, "root")
+	for _, c := range []struct {
+		name    string
+		pre     *Preconditions
+		genesis bool
+	}{
+		{"no preconditions", nil, true},
+		{"no state tree", &Preconditions{Variants: []Variant{{ID: "genesis"}}}, true},
+		{"undefined root", &Preconditions{StateTree: &StateTree{}}, true},
+		{"pre state", &Preconditions{StateTree: &StateTree{RootCID: root}}, false},
+		{"later variant", &Preconditions{Variants: []Variant{{ID: "genesis"}, {ID: "later", Epoch: 100}}}, false},
+	} {
+		if got := (TestVector{Pre: c.pre}).IsGenesis(); got != c.genesis {
+			t.Errorf("%s: expected IsGenesis to be %t", c.name, c.genesis)
+		}
+	}
+
+	genesis, _ := mkCAR(t, []cid.Cid{root}, "root")
+	tv := TestVector{CAR: genesis, Post: &Postconditions{}}
+	if err := tv.ValidateCARReachability(); err == nil || !strings.Contains(err.Error(), "genesis vector has no postcondition state tree root") {
+		t.Fatalf("expected a genesis state error, got: %v", err)
+	}
+	tv.Post.StateTree = &StateTree{RootCID: root}
+	if err := tv.ValidateCARReachability(); err != nil {
+		t.Fatal(err)
+	}
+	tv.Post.StateTree.RootCID = mkCid(t, "other")
+	if err := tv.ValidateCARReachability(); err == nil || !strings.Contains(err.Error(), "postcondition state tree root") {
+		t.Fatalf("expected a missing root error, got: %v", err)
+	}
+}
+
+func TestEpochRange(t *testing.T) {
+	offset := func(o int64) *int64 { return &o }
+	variants := []Variant{{ID: "upgrade", Epoch: 2870272}, {ID: "genesis", Epoch: 0}}
+	cases := []struct {
+		name     string
+		tv       TestVector
+		min, max int64
+		ok       bool
+	}{
+		{
+			name: "messages",
+			tv: TestVector{
+				Class:         ClassMessage,
+				Pre:           &Preconditions{Variants: variants[:1]},
+				ApplyMessages: []Message{{EpochOffset: offset(-1)}, {}, {EpochOffset: offset(3)}},
+			},
+			min: 2870271, max: 2870275, ok: true,
+		},
+		{
+			name: "messages and null rounds across variants",
+			tv: TestVector{
+				Class:         ClassMessage,
+				Pre:           &Preconditions{Variants: variants},
+				ApplyMessages: []Message{{EpochOffset: offset(1)}},
+				NullRounds:    []int64{5},
+			},
+			min: 1, max: 2870277, ok: true,
+		},
+		{
+			name: "tipsets",
+			tv: TestVector{
+				Class:        ClassTipset,
+				Pre:          &Preconditions{Variants: variants[:1]},
+				ApplyTipsets: []Tipset{{EpochOffset: 2}, {EpochOffset: 4}},
+			},
+			min: 2870274, max: 2870276, ok: true,
+		},
+		{
+			name: "nothing to apply",
+			tv:   TestVector{Class: ClassMessage, Pre: &Preconditions{Variants: variants}},
+			min:  0, max: 2870272, ok: true,
+		},
+		{
+			name: "no variants",
+			tv:   TestVector{Class: ClassMessage, ApplyMessages: []Message{{}}},
+		},
+		{
+			name: "blockseq",
+			tv:   TestVector{Class: ClassBlockSeq, Pre: &Preconditions{Variants: variants}},
+		},
+	}
+	for _, c := range cases {
+		min, max, ok := c.tv.EpochRange()
+		if min != c.min || max != c.max || ok != c.ok {
+			t.Errorf("%s: expected (%d, %d, %t), got (%d, %d, %t)", c.name, c.min, c.max, c.ok, min, max, ok)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+)
+
+// Equal reports whether this test vector is semantically identical to the
+// other one. Metadata (_meta) is excluded from the comparison.
+//
+// Binary blobs are compared bytewise, CIDs and addresses by value, and token
+// amounts numerically, though an unset amount differs from an explicit zero
+// (see TokenAmount.IsSet). Absent values are considered equal to empty ones: a
+// nil pointer equals a pointer to a zero value (e.g. a nil Pre and an empty
+// Preconditions), and a nil slice or map equals an empty one.
+func (tv TestVector) Equal(other *TestVector) bool {
+	if other == nil {
+		return false
+	}
+	a, b := tv, *other
+	a.Meta, b.Meta = nil, nil
+	return equalValues(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func equalValues(a, b reflect.Value) bool {
+	switch a.Type() {
+	case cidType:
+		return a.Interface().(cid.Cid).Equals(b.Interface().(cid.Cid))
+	case addressType:
+		return a.Interface().(address.Address) == b.Interface().(address.Address)
+	case tokenAmountType:
+		x, y := a.Interface().(TokenAmount), b.Interface().(TokenAmount)
+		return x.IsSet() == y.IsSet() && x.Cmp(y) == 0
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr:
+		switch {
+		case a.IsNil() && b.IsNil():
+			return true
+		case a.IsNil():
+			return equalValues(reflect.Zero(a.Type().Elem()), b.Elem())
+		case b.IsNil():
+			return equalValues(a.Elem(), reflect.Zero(b.Type().Elem()))
+		}
+		return equalValues(a.Elem(), b.Elem())
+
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if f := a.Type().Field(i); f.PkgPath != "" && !f.Anonymous {
+				continue // unexported.
+			}
+			if !equalValues(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Slice:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			return bytes.Equal(a.Bytes(), b.Bytes())
+		}
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalValues(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for iter := a.MapRange(); iter.Next(); {
+			bv := b.MapIndex(iter.Key())
+			if !bv.IsValid() || !equalValues(iter.Value(), bv) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a.Interface() == b.Interface()
+}
@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	tv1, tv2 := fullTestVector(t), fullTestVector(t)
+	if !tv1.Equal(tv2) {
+		t.Fatal("expected identical vectors to be equal")
+	}
+
+	// metadata is ignored.
+	tv2.Meta.ID = "another-id"
+	if !tv1.Equal(tv2) {
+		t.Fatal("expected vectors differing only in metadata to be equal")
+	}
+
+	// big ints are compared numerically, regardless of their representation.
+	tv2.ApplyTipsets[0].BaseFee = TokenAmount{i: new(big.Int).Add(big.NewInt(-10), big.NewInt(5))}
+	if !tv1.Equal(tv2) {
+		t.Fatal("expected equal base fees to compare equal")
+	}
+	tv1.Pre.BaseFee, tv2.Pre.BaseFee = &TokenAmount{i: big.NewInt(0)}, &TokenAmount{i: new(big.Int)}
+	if !tv1.Equal(tv2) {
+		t.Fatal("expected zero base fees to compare equal")
+	}
+	tv1.Pre.BaseFee = &TokenAmount{}
+	if tv1.Equal(tv2) {
+		t.Fatal("expected an unset base fee to differ from a zero one")
+	}
+	tv1.Pre.BaseFee = tv2.Pre.BaseFee
+
+	tv2.ApplyMessages[0].Bytes[0] ^= 0xff
+	if tv1.Equal(tv2) {
+		t.Fatal("expected vectors with different message bytes to differ")
+	}
+
+	// nil and empty sub-structs are equivalent.
+	var (
+		empty = TestVector{Class: ClassMessage, Pre: &Preconditions{}, Post: &Postconditions{Receipts: []*Receipt{}}}
+		nils  = TestVector{Class: ClassMessage}
+	)
+	if !empty.Equal(&nils) || !nils.Equal(&empty) {
+		t.Fatal("expected nil and empty sub-structs to be equal")
+	}
+
+	if tv1.Equal(nil) {
+		t.Fatal("expected vector not to equal nil")
+	}
+}
@@ -0,0 +1,78 @@
+package schema
+
+import "strconv"
+
+// ExitCode is the exit code of a message execution. It is serialized as a
+// plain integer.
+type ExitCode int64
+
+// System exit codes, as defined by the Filecoin VM. Vector authors should use
+// these names instead of bare numbers when asserting receipts.
+const (
+	ExitOK                       ExitCode = 0
+	ExitSysErrSenderInvalid      ExitCode = 1
+	ExitSysErrSenderStateInvalid ExitCode = 2
+	ExitSysErrInvalidMethod      ExitCode = 3
+	ExitSysErrReserved1          ExitCode = 4
+	ExitSysErrInvalidReceiver    ExitCode = 5
+	ExitSysErrInsufficientFunds  ExitCode = 6
+	ExitSysErrOutOfGas           ExitCode = 7
+	ExitSysErrForbidden          ExitCode = 8
+	ExitSysErrorIllegalActor     ExitCode = 9
+	ExitSysErrorIllegalArgument  ExitCode = 10
+	ExitSysErrReserved2          ExitCode = 11
+	ExitSysErrReserved3          ExitCode = 12
+	ExitSysErrReserved4          ExitCode = 13
+	ExitSysErrReserved5          ExitCode = 14
+	ExitSysErrReserved6          ExitCode = 15
+)
+
+// Common exit codes that may be shared by different actors. Actors may also
+// define their own codes, including redefining these values.
+const (
+	ExitErrIllegalArgument   ExitCode = 16
+	ExitErrNotFound          ExitCode = 17
+	ExitErrForbidden         ExitCode = 18
+	ExitErrInsufficientFunds ExitCode = 19
+	ExitErrIllegalState      ExitCode = 20
+	ExitErrSerialization     ExitCode = 21
+)
+
+const (
+	// ExitFirstActorErrorCode is the first exit code available to actors;
+	// lower codes are reserved for the system.
+	ExitFirstActorErrorCode = ExitErrIllegalArgument
+
+	// ExitFirstActorSpecificExitCode is the first exit code that actors may
+	// define freely, without clashing with the common codes above.
+	ExitFirstActorSpecificExitCode ExitCode = 32
+)
+
+var exitCodeNames = map[ExitCode]string{
+	ExitOK:                       "Ok",
+	ExitSysErrSenderInvalid:      "SysErrSenderInvalid",
+	ExitSysErrSenderStateInvalid: "SysErrSenderStateInvalid",
+	ExitSysErrInvalidMethod:      "SysErrInvalidMethod",
+	ExitSysErrReserved1:          "SysErrReserved1",
+	ExitSysErrInvalidReceiver:    "SysErrInvalidReceiver",
+	ExitSysErrInsufficientFunds:  "SysErrInsufficientFunds",
+	ExitSysErrOutOfGas:           "SysErrOutOfGas",
+	ExitSysErrForbidden:          "SysErrForbidden",
+	ExitSysErrorIllegalActor:     "SysErrorIllegalActor",
+	ExitSysErrorIllegalArgument:  "SysErrorIllegalArgument",
+	ExitSysErrReserved2:          "SysErrReserved2",
+	ExitSysErrReserved3:          "SysErrReserved3",
+	ExitSysErrReserved4:          "SysErrReserved4",
+	ExitSysErrReserved5:          "SysErrReserved5",
+	ExitSysErrReserved6:          "SysErrReserved6",
+}
+
+// String returns the symbolic name of system exit codes (e.g.
+// "SysErrOutOfGas"), and "ExitCode(N)" for any other value. Actor exit codes
+// are not named, as their meaning depends on the actor.
+func (x ExitCode) String() string {
+	if name, ok := exitCodeNames[x]; ok {
+		return name
+	}
+	return "ExitCode(" + strconv.FormatInt(int64(x), 10) + ")"
+}
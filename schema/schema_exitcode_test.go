@@ -0,0 +1,29 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExitCodeString(t *testing.T) {
+	for x, expected := range map[ExitCode]string{
+		ExitOK:                         "Ok",
+		ExitSysErrOutOfGas:             "SysErrOutOfGas",
+		ExitErrIllegalArgument:         "ExitCode(16)",
+		ExitFirstActorSpecificExitCode: "ExitCode(32)",
+		-1:                             "ExitCode(-1)",
+	} {
+		if s := x.String(); s != expected {
+			t.Errorf("expected %d to be named %q, got %q", int64(x), expected, s)
+		}
+	}
+
+	// exit codes are serialized as plain integers.
+	b, err := json.Marshal(Receipt{ExitCode: ExitSysErrOutOfGas})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"exit_code":7,"return":"","gas_used":0}`; string(b) != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+// cidBuilder is the CID builder used for fingerprints and message CIDs:
+// CIDv1, dag-cbor, blake2b-256; the same prefix Filecoin uses for its own
+// objects.
+var cidBuilder = cid.V1Builder{Codec: cid.DagCBOR, MhType: multihash.BLAKE2B_MIN + 31}
+
+// Fingerprint computes a stable content identifier for this test vector. It
+// is the CID of the canonical CBOR encoding of the vector (see EncodeCBOR),
+// so it's independent of JSON whitespace and map ordering.
+//
+// The following metadata fields are excluded, as they describe where the
+// vector came from rather than what it tests:
+//
+//   - _meta.id
+//   - _meta.description
+//   - _meta.comment
+//   - _meta.skip_reason
+//   - _meta.car_checksum, which is derived from the car
+//   - _meta.gen
+//   - _meta.schema_version
+//   - _meta.related
+//
+// All other fields, including _meta.version and _meta.tags, are included.
+func (tv TestVector) Fingerprint() (cid.Cid, error) {
+	if tv.Meta != nil {
+		meta := *tv.Meta
+		meta.ID, meta.Desc, meta.Comment, meta.SkipReason, meta.CARChecksum = "", "", "", "", ""
+		meta.Gen, meta.SchemaVersion, meta.Related = nil, "", nil
+		tv.Meta = &meta
+	}
+
+	var buf bytes.Buffer
+	if err := tv.EncodeCBOR(&buf); err != nil {
+		return cid.Undef, fmt.Errorf("encoding test vector: %w", err)
+	}
+	return cidBuilder.Sum(buf.Bytes())
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// frameMagic opens every frame written by EncodeFrame.
+var frameMagic = [4]byte{'T', 'V', 'E', 'C'}
+
+// FrameVersion is the version of the frame format written by EncodeFrame.
+const FrameVersion = 1
+
+// EncodeFrame writes the test vector to w as a self-delimiting frame, so that
+// vectors can be streamed one after another, e.g. over a network connection,
+// and read back with DecodeFrame. A frame consists of:
+//
+//   - the 4-byte magic "TVEC";
+//   - a version byte, FrameVersion;
+//   - the length of the body, as an 8-byte big-endian integer;
+//   - the body: the vector, encoded with TestVector.EncodeCBOR;
+//   - the CRC-32 (IEEE) of all the above, as a 4-byte big-endian integer.
+func EncodeFrame(w io.Writer, tv *TestVector) error {
+	var body bytes.Buffer
+	if err := tv.EncodeCBOR(&body); err != nil {
+		return fmt.Errorf("encoding frame: %w", err)
+	}
+
+	var header [4 + 1 + 8]byte
+	copy(header[:], frameMagic[:])
+	header[4] = FrameVersion
+	binary.BigEndian.PutUint64(header[5:], uint64(body.Len()))
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(header[:])
+	_, _ = crc.Write(body.Bytes())
+	var trailer [4]byte
+	binary.BigEndian.PutUint32(trailer[:], crc.Sum32())
+
+	for _, b := range [][]byte{header[:], body.Bytes(), trailer[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeFrame reads a frame written by EncodeFrame from r, and decodes the
+// test vector it holds. It consumes exactly one frame, leaving r positioned at
+// the next one. It returns io.EOF if r holds no more frames, and an error if
+// the frame is truncated, of an unknown version, or fails its checksum.
+func DecodeFrame(r io.Reader) (*TestVector, error) {
+	var header [4 + 1 + 8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("reading frame header: %w", err)
+	}
+	if !bytes.Equal(header[:4], frameMagic[:]) {
+		return nil, fmt.Errorf("not a test vector frame: bad magic %q", header[:4])
+	}
+	if v := header[4]; v != FrameVersion {
+		return nil, fmt.Errorf("unsupported frame version %d; expected %d", v, FrameVersion)
+	}
+
+	// the body is read as it arrives, rather than allocated upfront, so that
+	// a corrupted length can't exhaust memory.
+	n := binary.BigEndian.Uint64(header[5:])
+	body, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, fmt.Errorf("reading frame body: %w", err)
+	}
+	if uint64(len(body)) != n {
+		return nil, fmt.Errorf("reading frame body: %w", io.ErrUnexpectedEOF)
+	}
+	var trailer [4]byte
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("reading frame checksum: %w", err)
+	}
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(header[:])
+	_, _ = crc.Write(body)
+	if expected, actual := binary.BigEndian.Uint32(trailer[:]), crc.Sum32(); expected != actual {
+		return nil, fmt.Errorf("frame checksum mismatch: expected %08x, got %08x", expected, actual)
+	}
+
+	br := bytes.NewReader(body)
+	tv, err := DecodeCBOR(br)
+	if err != nil {
+		return nil, fmt.Errorf("decoding frame: %w", err)
+	}
+	if br.Len() > 0 {
+		return nil, fmt.Errorf("decoding frame: %d trailing bytes after the test vector", br.Len())
+	}
+	return tv, nil
+}
@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+	tv1, tv2 := fullTestVector(t), fullTestVector(t)
+	tv2.Meta.ID = "another-vector"
+
+	var buf bytes.Buffer
+	for _, tv := range []*TestVector{tv1, tv2} {
+		if err := EncodeFrame(&buf, tv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := append([]byte(nil), buf.Bytes()...)
+
+	for _, expected := range []*TestVector{tv1, tv2} {
+		tv, err := DecodeFrame(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tv, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, tv)
+		}
+	}
+	if _, err := DecodeFrame(&buf); err != io.EOF {
+		t.Fatalf("expected io.EOF after the last frame, got: %v", err)
+	}
+
+	first := len(stream) / 2 // both frames are the same size.
+	corrupt := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), stream[:first]...))
+	}
+	cases := []struct {
+		name  string
+		frame []byte
+		err   string
+	}{
+		{"bad magic", corrupt(func(b []byte) []byte { b[0] = 'X'; return b }), "bad magic"},
+		{"bad version", corrupt(func(b []byte) []byte { b[4] = 2; return b }), "unsupported frame version 2"},
+		{"bad checksum", corrupt(func(b []byte) []byte { b[20] ^= 0xff; return b }), "frame checksum mismatch"},
+		{"truncated header", stream[:6], "reading frame header"},
+		{"truncated body", stream[:100], "reading frame body"},
+		{"truncated checksum", stream[:first-2], "reading frame checksum"},
+	}
+	for _, c := range cases {
+		_, err := DecodeFrame(bytes.NewReader(c.frame))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error containing %q, got: %v", c.name, c.err, err)
+		}
+		if strings.HasPrefix(c.name, "truncated") && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: expected an unexpected EOF error, got: %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+)
+
+// TotalGasUsed sums the gas used by all receipts. It saturates at the bounds
+// of int64 rather than overflowing; use CheckedTotalGasUsed to detect that.
+func (p Postconditions) TotalGasUsed() int64 {
+	total, err := p.CheckedTotalGasUsed()
+	if err != nil {
+		if total < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return total
+}
+
+// CheckedTotalGasUsed sums the gas used by all receipts, returning an error
+// if the sum overflows int64. In that case, the returned value is the partial
+// sum accumulated before the overflow, which indicates its direction.
+// Missing receipts are skipped.
+func (p Postconditions) CheckedTotalGasUsed() (int64, error) {
+	var total int64
+	for i, r := range p.Receipts {
+		if r == nil {
+			continue
+		}
+		if (r.GasUsed > 0 && total > math.MaxInt64-r.GasUsed) || (r.GasUsed < 0 && total < math.MinInt64-r.GasUsed) {
+			return total, fmt.Errorf("total gas used overflows int64 at receipt at index %d", i)
+		}
+		total += r.GasUsed
+	}
+	return total, nil
+}
+
+// TotalGasUsed sums the gas used by all postcondition receipts, or returns 0
+// if the vector has no postconditions. See Postconditions.TotalGasUsed.
+func (tv TestVector) TotalGasUsed() int64 {
+	if tv.Post == nil {
+		return 0
+	}
+	return tv.Post.TotalGasUsed()
+}
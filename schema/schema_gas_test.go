@@ -0,0 +1,30 @@
+package schema
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTotalGasUsed(t *testing.T) {
+	if (TestVector{}).TotalGasUsed() != 0 {
+		t.Fatal("expected no gas used without postconditions")
+	}
+
+	tv := TestVector{Post: &Postconditions{Receipts: []*Receipt{{GasUsed: 100}, nil, {GasUsed: 250}}}}
+	if total := tv.TotalGasUsed(); total != 350 {
+		t.Fatalf("expected 350 gas used, got %d", total)
+	}
+
+	tv.Post.Receipts = append(tv.Post.Receipts, &Receipt{GasUsed: math.MaxInt64})
+	if _, err := tv.Post.CheckedTotalGasUsed(); err == nil {
+		t.Fatal("expected an overflow error")
+	}
+	if total := tv.TotalGasUsed(); total != math.MaxInt64 {
+		t.Fatalf("expected the total to saturate, got %d", total)
+	}
+
+	tv.Post.Receipts = []*Receipt{{GasUsed: -1}, {GasUsed: math.MinInt64}}
+	if total := tv.TotalGasUsed(); total != math.MinInt64 {
+		t.Fatalf("expected the total to saturate, got %d", total)
+	}
+}
@@ -0,0 +1,50 @@
+package schema
+
+import "strings"
+
+// knownHints are the standard hints.
+var knownHints = map[Hint]struct{}{
+	HintIncorrect:         {},
+	HintNegate:            {},
+	HintDuplicateMessages: {},
+	HintPostStateUnknown:  {},
+	HintExternalBlocks:    {},
+	HintDetachedCAR:       {},
+}
+
+// IsKnown reports whether the hint is either a standard hint, or a vendor
+// hint carrying the HintVendorPrefix.
+func (h Hint) IsKnown() bool {
+	if _, ok := knownHints[h]; ok {
+		return true
+	}
+	return strings.HasPrefix(string(h), HintVendorPrefix)
+}
+
+// HasHint reports whether the vector carries the hint.
+func (tv TestVector) HasHint(h Hint) bool {
+	for _, hint := range tv.Hints {
+		if hint == h {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultSkipReason is the reason ShouldSkip returns for vectors that carry
+// no SkipReason.
+const defaultSkipReason = "vector is knowingly incorrect"
+
+// ShouldSkip reports whether drivers should skip this vector, along with the
+// reason to log. That's the case for vectors hinted as incorrect, unless they
+// are also hinted to be negated, in which case they can be run. The reason is
+// the SkipReason in the metadata, if any.
+func (tv TestVector) ShouldSkip() (bool, string) {
+	if !tv.HasHint(HintIncorrect) || tv.HasHint(HintNegate) {
+		return false, ""
+	}
+	if tv.Meta != nil && tv.Meta.SkipReason != "" {
+		return true, tv.Meta.SkipReason
+	}
+	return true, defaultSkipReason
+}
@@ -0,0 +1,29 @@
+package schema
+
+import "testing"
+
+func TestShouldSkip(t *testing.T) {
+	cases := []struct {
+		name   string
+		tv     TestVector
+		skip   bool
+		reason string
+	}{
+		{name: "correct", tv: TestVector{}},
+		{name: "negated", tv: TestVector{Hints: []Hint{HintIncorrect, HintNegate}, Meta: &Metadata{SkipReason: "broken"}}},
+		{name: "incorrect", tv: TestVector{Hints: []Hint{HintIncorrect}}, skip: true, reason: defaultSkipReason},
+		{
+			name: "incorrect with reason",
+			tv:   TestVector{Hints: []Hint{HintIncorrect}, Meta: &Metadata{SkipReason: "reference implementation charges gas twice"}},
+			skip: true, reason: "reference implementation charges gas twice",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			skip, reason := c.tv.ShouldSkip()
+			if skip != c.skip || reason != c.reason {
+				t.Fatalf("expected (%t, %q), got (%t, %q)", c.skip, c.reason, skip, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package schema
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// jsonSchema is the canonical JSON Schema document for test vectors.
+//
+//go:embed schema.json
+var jsonSchema []byte
+
+var (
+	compiledSchema     *gojsonschema.Schema
+	compiledSchemaErr  error
+	compiledSchemaOnce sync.Once
+)
+
+// JSONSchema returns a copy of the canonical JSON Schema document that test
+// vectors conform to.
+func JSONSchema() []byte {
+	return append([]byte(nil), jsonSchema...)
+}
+
+// SchemaValidate validates the supplied JSON bytes against the canonical JSON
+// Schema. Unlike unmarshalling into a TestVector, which ignores unknown fields
+// and coerces missing ones to their zero values, this check reports
+// structural errors (wrong types, missing required fields, etc.) along with
+// the path of the offending field.
+func SchemaValidate(raw []byte) error {
+	compiledSchemaOnce.Do(func() {
+		compiledSchema, compiledSchemaErr = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(jsonSchema))
+	})
+	if compiledSchemaErr != nil {
+		return fmt.Errorf("loading json schema: %w", compiledSchemaErr)
+	}
+
+	result, err := compiledSchema.Validate(gojsonschema.NewBytesLoader(raw))
+	if err != nil {
+		return fmt.Errorf("validating against json schema: %w", err)
+	}
+	if result.Valid() {
+		return nil
+	}
+	var errs []string
+	for _, desc := range result.Errors() {
+		errs = append(errs, desc.String())
+	}
+	return fmt.Errorf("test vector does not conform to the json schema: %s", strings.Join(errs, "; "))
+}
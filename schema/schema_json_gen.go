@@ -0,0 +1,186 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect emitted by GenerateJSONSchema.
+const JSONSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var (
+	base64BytesType = reflect.TypeOf(Base64EncodedBytes(nil))
+	classType       = reflect.TypeOf(Class(""))
+	sigType         = reflect.TypeOf(SignatureType(""))
+)
+
+// GenerateJSONSchema reflects over the TestVector type and produces a JSON
+// Schema (draft-07) document describing its JSON form.
+//
+// Field names follow the json struct tags. Fields without omitempty are always
+// emitted by the encoder, so they're marked as required. Pointers, slices and
+// maps may be null. Custom JSON representations are honoured:
+// Base64EncodedBytes are base64 strings, OffsetMillis are integer numbers of
+// milliseconds, TokenAmounts are decimal strings (or legacy integers, or null
+// if unset), CIDs are dag-json links, and randomness rules are four-element
+// arrays. Named struct types are emitted as definitions entries.
+//
+// Unlike the canonical schema returned by JSONSchema, the generated document
+// carries no titles or descriptions, and it cannot express conditional rules
+// (e.g. which apply_* field each class requires).
+func GenerateJSONSchema() ([]byte, error) {
+	g := &schemaGenerator{defs: make(map[string]interface{})}
+	root, err := g.structSchema(reflect.TypeOf(TestVector{}))
+	if err != nil {
+		return nil, err
+	}
+	root["$schema"] = JSONSchemaDraft
+	root["title"] = "a filecoin VM test vector"
+	root["definitions"] = g.defs
+	return json.MarshalIndent(root, "", "  ")
+}
+
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) (map[string]interface{}, error) {
+	switch t {
+	case base64BytesType:
+		return map[string]interface{}{
+			"type":            "string",
+			"contentEncoding": "base64",
+			"pattern":         "^[0-9a-zA-Z+/=]*$",
+		}, nil
+	case offsetMillisType:
+		return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": time.Duration(MaxOffset).Milliseconds()}, nil
+	case cidType:
+		return map[string]interface{}{
+			"type":                 "object",
+			"required":             []string{"/"},
+			"properties":           map[string]interface{}{"/": map[string]interface{}{"type": "string"}},
+			"additionalProperties": false,
+		}, nil
+	case addressType:
+		return map[string]interface{}{"type": "string"}, nil
+	case tokenAmountType:
+		return map[string]interface{}{
+			"type":    []string{"string", "integer", "null"},
+			"pattern": "^-?[0-9]+$",
+		}, nil
+	case classType:
+		return map[string]interface{}{
+			"type": "string",
+			"enum": []Class{ClassMessage, ClassTipset, ClassBlockSeq},
+		}, nil
+	case sigType:
+		return map[string]interface{}{
+			"type": "string",
+			"enum": []SignatureType{SignatureSecp256k1, SignatureBLS},
+		}, nil
+	case randomnessRuleType:
+		var items []interface{}
+		for i := 0; i < t.NumField(); i++ {
+			s, err := g.typeSchema(t.Field(i).Type)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, s)
+		}
+		return map[string]interface{}{
+			"type":            "array",
+			"items":           items,
+			"additionalItems": false,
+			"minItems":        len(items),
+		}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		s, err := g.nonNullSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "null"}, s}}, nil
+	}
+	return g.nonNullSchema(t)
+}
+
+// nonNullSchema returns the schema for non-null values of the type.
+func (g *schemaGenerator) nonNullSchema(t reflect.Type) (map[string]interface{}, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // reserve the name, in case the type is recursive.
+			s, err := g.structSchema(t)
+			if err != nil {
+				return nil, err
+			}
+			g.defs[name] = s
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + name}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	}
+	return nil, fmt.Errorf("cannot generate json schema for type %s", t)
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) (map[string]interface{}, error) {
+	var (
+		props    = make(map[string]interface{})
+		required []string
+	)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, omitempty, ok := jsonFieldName(f)
+		if !ok {
+			continue
+		}
+
+		s, err := g.typeSchema(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		if omitempty {
+			props[name] = s
+			continue
+		}
+		required = append(required, name)
+		props[name] = s
+	}
+
+	ret := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		ret["required"] = required
+	}
+	return ret, nil
+}
@@ -0,0 +1,219 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	if err := SchemaValidate([]byte(testMessageVector)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	invalid := strings.Replace(testMessageVector, `"gas_used": 0`, `"gas_used": "zero"`, 1)
+	if err := SchemaValidate([]byte(invalid)); err == nil || !strings.Contains(err.Error(), "gas_used") {
+		t.Fatalf("expected schema error referencing gas_used, got: %v", err)
+	}
+
+	if err := SchemaValidate([]byte(`{"_meta": {}}`)); err == nil || !strings.Contains(err.Error(), "class") {
+		t.Fatalf("expected schema error for missing class, got: %v", err)
+	}
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	generated, err := GenerateJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// validate the schema against the draft-07 meta-schema, which gojsonschema
+	// implements.
+	loader := gojsonschema.NewSchemaLoader()
+	loader.Draft = gojsonschema.Draft7
+	loader.Validate = true
+	schema, err := loader.Compile(gojsonschema.NewBytesLoader(generated))
+	if err != nil {
+		t.Fatalf("generated schema is not valid: %s", err)
+	}
+
+	// only offsets are bounded, by MaxOffset.
+	late := fullTestVector(t)
+	late.Pre.PreconditionsBlockSeq.GenesisTs = 1 << 40
+	for name, doc := range map[string][]byte{
+		"message vector": []byte(testMessageVector),
+		"full vector":    fullTestVector(t).MustMarshalJSON(),
+		"late genesis":   late.MustMarshalJSON(),
+	} {
+		result, err := schema.Validate(gojsonschema.NewBytesLoader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Valid() {
+			t.Errorf("%s does not conform to the generated schema: %v", name, result.Errors())
+		}
+	}
+
+	invalid := strings.Replace(testMessageVector, `"epoch_offset": 0`, `"epoch_offset": "zero"`, 1)
+	result, err := schema.Validate(gojsonschema.NewBytesLoader([]byte(invalid)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid() {
+		t.Fatal("expected invalid vector to be rejected by the generated schema")
+	}
+
+	// randomness rules are exactly four elements long.
+	invalid = string(fullTestVector(t).MustMarshalJSON())
+	invalid = strings.Replace(invalid, `"ZW50cm9weQ=="]`, `"ZW50cm9weQ==",1]`, 1)
+	if result, err = schema.Validate(gojsonschema.NewBytesLoader([]byte(invalid))); err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid() {
+		t.Fatal("expected a five-element randomness rule to be rejected by the generated schema")
+	}
+}
+
+// TestJSONSchemaInSync checks that every property of the schema generated
+// from the Go types is described by the canonical schema, with a compatible
+// type, so that new fields can't be left out of schema.json.
+func TestJSONSchemaInSync(t *testing.T) {
+	generated, err := GenerateJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gen, canon map[string]interface{}
+	if err := json.Unmarshal(generated, &gen); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(JSONSchema(), &canon); err != nil {
+		t.Fatal(err)
+	}
+	d := schemaDrift{gen: gen, canon: canon}
+	d.compare("$", []interface{}{gen}, []interface{}{canon})
+	for _, e := range d.errs {
+		t.Error(e)
+	}
+}
+
+// schemaDrift compares a generated schema with the canonical one.
+type schemaDrift struct {
+	gen, canon map[string]interface{}
+	errs       []string
+}
+
+// compare checks that the generated schemas at the path are covered by the
+// canonical ones.
+func (d *schemaDrift) compare(path string, gen, canon []interface{}) {
+	gen, canon = flattenSchemas(d.gen, gen), flattenSchemas(d.canon, canon)
+
+	canonTypes := schemaTypes(canon)
+	for typ := range schemaTypes(gen) {
+		if len(canonTypes) > 0 && !canonTypes[typ] && !(typ == "integer" && canonTypes["number"]) {
+			d.errs = append(d.errs, fmt.Sprintf("%s: type %s is missing from schema.json", path, typ))
+		}
+	}
+
+	genProps, canonProps := schemaKeyword(gen, "properties"), schemaKeyword(canon, "properties")
+	for _, props := range genProps {
+		for name, sub := range props.(map[string]interface{}) {
+			var subs []interface{}
+			for _, cp := range canonProps {
+				if s, ok := cp.(map[string]interface{})[name]; ok {
+					subs = append(subs, s)
+				}
+			}
+			if len(subs) == 0 {
+				d.errs = append(d.errs, fmt.Sprintf("%s: property %s is missing from schema.json", path, name))
+				continue
+			}
+			d.compare(path+"."+name, []interface{}{sub}, subs)
+		}
+	}
+
+	for _, items := range schemaKeyword(gen, "items") {
+		if tuple, ok := items.([]interface{}); ok {
+			for _, citems := range schemaKeyword(canon, "items") {
+				if ctuple, ok := citems.([]interface{}); ok && len(ctuple) == len(tuple) {
+					for i := range tuple {
+						d.compare(fmt.Sprintf("%s[%d]", path, i), tuple[i:i+1], ctuple[i:i+1])
+					}
+				}
+			}
+			continue
+		}
+		if citems := schemaKeyword(canon, "items"); len(citems) > 0 {
+			d.compare(path+"[]", []interface{}{items}, citems)
+		}
+	}
+	for _, values := range schemaKeyword(gen, "additionalProperties") {
+		if _, ok := values.(map[string]interface{}); !ok {
+			continue
+		}
+		var cvalues []interface{}
+		for _, v := range schemaKeyword(canon, "additionalProperties") {
+			if _, ok := v.(map[string]interface{}); ok {
+				cvalues = append(cvalues, v)
+			}
+		}
+		if len(cvalues) > 0 {
+			d.compare(path+"{}", []interface{}{values}, cvalues)
+		}
+	}
+}
+
+// flattenSchemas resolves the references of the schemas, and expands their
+// alternatives and conditional branches, as any of them may describe a value.
+func flattenSchemas(doc map[string]interface{}, schemas []interface{}) []interface{} {
+	var ret []interface{}
+	for _, s := range schemas {
+		m, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if ref, ok := m["$ref"].(string); ok {
+			def := doc["definitions"].(map[string]interface{})[strings.TrimPrefix(ref, "#/definitions/")]
+			ret = append(ret, flattenSchemas(doc, []interface{}{def})...)
+		}
+		ret = append(ret, m)
+		for _, kw := range []string{"anyOf", "oneOf", "allOf"} {
+			if alts, ok := m[kw].([]interface{}); ok {
+				ret = append(ret, flattenSchemas(doc, alts)...)
+			}
+		}
+		if then, ok := m["then"]; ok {
+			ret = append(ret, flattenSchemas(doc, []interface{}{then})...)
+		}
+	}
+	return ret
+}
+
+// schemaTypes returns the non-null types the schemas allow.
+func schemaTypes(schemas []interface{}) map[string]bool {
+	ret := make(map[string]bool)
+	for _, typ := range schemaKeyword(schemas, "type") {
+		switch typ := typ.(type) {
+		case string:
+			ret[typ] = true
+		case []interface{}:
+			for _, t := range typ {
+				ret[t.(string)] = true
+			}
+		}
+	}
+	delete(ret, "null")
+	return ret
+}
+
+// schemaKeyword returns the values of the keyword in the schemas.
+func schemaKeyword(schemas []interface{}, kw string) []interface{} {
+	var ret []interface{}
+	for _, s := range schemas {
+		if v, ok := s.(map[string]interface{})[kw]; ok {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
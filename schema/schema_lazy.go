@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// CARReader returns a reader over the CAR embedded in this vector, as stored
+// (i.e. still compressed, when it's gzipped). It reads from the CAR in place,
+// without copying it.
+func (tv TestVector) CARReader() io.Reader {
+	return bytes.NewReader(tv.CAR)
+}
+
+// LazyTestVector is a test vector whose CAR is kept in its base64 form, as
+// read from the JSON, and only decoded on demand. It has a smaller memory
+// footprint than a TestVector when the CAR isn't needed, or when it can be
+// streamed. Obtain one through LoadTestVectorLazy.
+type LazyTestVector struct {
+	// TestVector holds every field of the vector but the CAR, which is empty.
+	TestVector
+
+	rawCAR []byte
+}
+
+// LoadTestVectorLazy decodes a JSON test vector from the supplied reader, like
+// LoadTestVector, but leaves its CAR undecoded. The vector is validated,
+// except for its CARChecksum, which requires the CAR; use Decode for that.
+// Likewise, malformed base64 in the CAR only surfaces once it's read.
+func LoadTestVectorLazy(r io.Reader) (*LazyTestVector, error) {
+	in, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	// the car field shadows the one of the embedded vector.
+	var v struct {
+		TestVector
+		CAR json.RawMessage `json:"car"`
+	}
+	if err := json.NewDecoder(in).Decode(&v); err != nil {
+		return nil, fmt.Errorf("decoding test vector: %w", err)
+	}
+	raw, err := rawBase64(v.CAR)
+	if err != nil {
+		return nil, fmt.Errorf("decoding test vector: car: %w", err)
+	}
+
+	opts := DefaultValidateOptions()
+	opts.SkipCARChecksum = true
+	if err := v.TestVector.ValidateWithOptions(opts); err != nil {
+		return nil, fmt.Errorf("validating test vector: %w", err)
+	}
+	return &LazyTestVector{TestVector: v.TestVector, rawCAR: raw}, nil
+}
+
+// CARReader returns a reader that decodes the CAR of this vector as it's
+// read. Like TestVector.CARReader, it yields the CAR as stored.
+func (lv *LazyTestVector) CARReader() io.Reader {
+	return base64.NewDecoder(base64.StdEncoding, bytes.NewReader(lv.rawCAR))
+}
+
+// Decode returns the full test vector, with its CAR decoded, and checked
+// against its CARChecksum, if any.
+func (lv *LazyTestVector) Decode() (*TestVector, error) {
+	car, err := ioutil.ReadAll(lv.CARReader())
+	if err != nil {
+		return nil, fmt.Errorf("decoding car: %w", err)
+	}
+	tv := lv.TestVector
+	tv.CAR = car
+	if err := tv.VerifyCARChecksum(); err != nil {
+		return nil, err
+	}
+	return &tv, nil
+}
+
+// rawBase64 returns the contents of a JSON string holding base64 data, which
+// needs no unescaping unless it was written by an encoder escaping slashes.
+func rawBase64(msg json.RawMessage) ([]byte, error) {
+	if len(msg) == 0 || string(msg) == "null" {
+		return nil, nil
+	}
+	if bytes.IndexByte(msg, '\\') < 0 && len(msg) >= 2 && msg[0] == '"' && msg[len(msg)-1] == '"' {
+		return msg[1 : len(msg)-1], nil
+	}
+	var s string
+	if err := json.Unmarshal(msg, &s); err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCARReader(t *testing.T) {
+	tv := TestVector{CAR: []byte("car bytes")}
+	b, err := ioutil.ReadAll(tv.CARReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "car bytes" {
+		t.Fatalf("unexpected car %q", b)
+	}
+}
+
+func TestLoadTestVectorLazy(t *testing.T) {
+	data, _ := mkCAR(t, nil, "block")
+	tv, err := LoadTestVector(strings.NewReader(testMessageVector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tv.CAR = data
+	if err := tv.SetCARChecksum(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := EncodeJSON(zw, tv); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lv, err := LoadTestVectorLazy(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lv.CAR != nil || lv.Meta.ID != "test-vector" {
+		t.Fatalf("expected every field but the car to be decoded, got: %+v", lv.TestVector)
+	}
+
+	car, err := ioutil.ReadAll(lv.CARReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(car, data) {
+		t.Fatal("unexpected car")
+	}
+	decoded, err := lv.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.MustMarshalJSON(), tv.MustMarshalJSON()) {
+		t.Fatalf("expected the decoded vector to match the original:\n%s\n%s", decoded.MustMarshalJSON(), tv.MustMarshalJSON())
+	}
+
+	// the checksum is only verified once the car is decoded.
+	tv.Meta.CARChecksum = "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	if lv, err = LoadTestVectorLazy(bytes.NewReader(tv.MustMarshalJSON())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lv.Decode(); err == nil || !strings.Contains(err.Error(), "car checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got: %v", err)
+	}
+
+	// some encoders escape slashes.
+	escaped := strings.Replace(testMessageVector, `"car": ""`, `"car": "Y2Fy\/w=="`, 1)
+	if lv, err = LoadTestVectorLazy(strings.NewReader(escaped)); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = lv.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded.CAR) != "car\xff" {
+		t.Fatalf("unexpected car %q", decoded.CAR)
+	}
+
+	malformed := strings.Replace(testMessageVector, `"car": ""`, `"car": "Y2Fy!"`, 1)
+	if lv, err = LoadTestVectorLazy(strings.NewReader(malformed)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lv.Decode(); err == nil || !strings.Contains(err.Error(), "decoding car") {
+		t.Fatalf("expected malformed base64 to fail decoding, got: %v", err)
+	}
+}
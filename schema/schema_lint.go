@@ -0,0 +1,43 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Lint returns warnings about constructs that are valid, but usually
+// unintended, such as a message included in more than one block of a tipset,
+// or a selector key drivers don't know about. Unlike Validate, Lint never
+// rejects a vector; an empty result means there's nothing to warn about.
+func (tv TestVector) Lint() []string {
+	warnings := tv.Selector.lintKeys()
+	warnings = append(warnings, tv.lintNetworkVersion()...)
+	if tv.Class == ClassTipset && !tv.HasHint(HintDuplicateMessages) {
+		warnings = append(warnings, tv.lintDuplicateMessages()...)
+	}
+	return warnings
+}
+
+// lintDuplicateMessages warns about messages included in more than one block
+// of the same tipset, which are executed only once.
+func (tv TestVector) lintDuplicateMessages() []string {
+	var warnings []string
+	for i, ts := range tv.ApplyTipsets {
+		first := make(map[cid.Cid]int) // message CID => index of the first block including it.
+		for j, b := range ts.Blocks {
+			cids, err := b.MessageCIDs()
+			if err != nil {
+				continue
+			}
+			for _, c := range cids {
+				if prev, ok := first[c]; ok && prev != j {
+					warnings = append(warnings, fmt.Sprintf("message %s in block at index %d of tipset at index %d is also included in block at index %d, and will be executed only once; hint %q if intended", c, j, i, prev, HintDuplicateMessages))
+					continue
+				}
+				first[c] = j
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+)
+
+func TestLintDuplicateMessages(t *testing.T) {
+	miner, _ := address.NewIDAddress(1000)
+	tv := TestVector{
+		Class: ClassTipset,
+		ApplyTipsets: []Tipset{
+			{Blocks: []Block{{MinerAddr: miner, WinCount: 1, Messages: []Base64EncodedBytes{[]byte("a")}}, {MinerAddr: miner, WinCount: 1, Messages: []Base64EncodedBytes{[]byte("b")}}}},
+			{Blocks: []Block{{MinerAddr: miner, WinCount: 1, Messages: []Base64EncodedBytes{[]byte("a")}}, {MinerAddr: miner, WinCount: 1, Messages: []Base64EncodedBytes{[]byte("b"), []byte("a")}}}},
+		},
+	}
+
+	warnings := tv.Lint()
+	if len(warnings) != 1 {
+		t.Fatalf("expected a single warning, got: %v", warnings)
+	}
+	if expected := "message " + mkCid(t, "a").String() + " in block at index 1 of tipset at index 1 is also included in block at index 0"; !strings.HasPrefix(warnings[0], expected) {
+		t.Fatalf("expected warning starting with %q, got: %s", expected, warnings[0])
+	}
+
+	tv.Hints = []Hint{HintDuplicateMessages}
+	if warnings := tv.Lint(); len(warnings) != 0 {
+		t.Fatalf("expected hinted duplicates not to be warned about, got: %v", warnings)
+	}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestLintSelectorKeys(t *testing.T) {
+	tv := TestVector{Selector: Selector{
+		SelectorChaosActor: "true",
+		"x-lotus-fast":     "true",
+		"chain":            "mainnet",
+		SelectorOr:         `[{"nv":">=16"},{"networks":"calibnet"}]`,
+	}}
+	warnings := tv.Lint()
+	if len(warnings) != 2 ||
+		!strings.HasPrefix(warnings[0], `selector key "chain" is an alias of "network"`) ||
+		!strings.HasPrefix(warnings[1], `selector key "networks" is not a well-known key`) {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+}
@@ -0,0 +1,241 @@
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// gzipMagic is the magic number every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// LoadTestVector decodes a JSON test vector from the supplied reader, and
+// validates it. The input is streamed through the decoder, rather than being
+// read into memory upfront.
+//
+// Gzip-compressed input is detected by its magic number, and is decompressed
+// transparently.
+func LoadTestVector(r io.Reader) (*TestVector, error) {
+	return loadTestVector(r, false)
+}
+
+// LoadTestVectorStrict is like LoadTestVector, but it rejects input carrying
+// fields that are unknown to the schema, e.g. a misspelt "preconditons",
+// which LoadTestVector silently ignores.
+func LoadTestVectorStrict(r io.Reader) (*TestVector, error) {
+	return loadTestVector(r, true)
+}
+
+func loadTestVector(r io.Reader, strict bool) (*TestVector, error) {
+	in, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var tv TestVector
+	dec := json.NewDecoder(in)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&tv); err != nil {
+		return nil, fmt.Errorf("decoding test vector: %w", err)
+	}
+	if err := tv.Validate(); err != nil {
+		return nil, fmt.Errorf("validating test vector: %w", err)
+	}
+	return &tv, nil
+}
+
+// maybeGunzip returns a reader over the input, which decompresses it if it's
+// gzipped, as detected by its magic number.
+func maybeGunzip(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return ioutil.NopCloser(br), nil
+	}
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip stream: %w", err)
+	}
+	return gr, nil
+}
+
+// LoadTestVectorFile loads and validates the JSON test vector stored at the
+// given file path. The file may be gzip-compressed, conventionally signalled
+// by a .json.gz extension.
+func LoadTestVectorFile(path string) (*TestVector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening test vector file: %w", err)
+	}
+	defer f.Close()
+
+	tv, err := LoadTestVector(f)
+	if err != nil {
+		return nil, fmt.Errorf("loading test vector file %s: %w", path, err)
+	}
+	return tv, nil
+}
+
+// WriteTestVectorFile writes the test vector to the file at the given path,
+// as WriteIndented does, creating or truncating it. The output is
+// gzip-compressed if the path ends in .gz.
+func WriteTestVectorFile(path string, tv *TestVector) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating test vector file: %w", err)
+	}
+
+	var (
+		w  io.Writer = f
+		gw *gzip.Writer
+	)
+	if strings.HasSuffix(path, ".gz") {
+		gw = gzip.NewWriter(f)
+		w = gw
+	}
+
+	if err := WriteIndented(w, tv); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("compressing test vector: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// WriteIndented writes the test vector to the writer as JSON indented with
+// two spaces, followed by a newline. The output is deterministic (see
+// TestVector.MarshalJSONDeterministic), which makes it suitable for files
+// checked into version control. Base64 blobs are kept on a single line.
+func WriteIndented(w io.Writer, tv *TestVector) error {
+	b, err := tv.MarshalJSONDeterministic()
+	if err != nil {
+		return fmt.Errorf("encoding test vector: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return fmt.Errorf("indenting test vector: %w", err)
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// LoadedVector is the result of loading a single test vector file from a
+// directory. Exactly one of Vector or Err is set.
+type LoadedVector struct {
+	// Path is the path of the file the vector was loaded from.
+	Path   string
+	Vector *TestVector
+	Err    error
+}
+
+// LoadTestVectorDir walks the directory tree rooted at root, and loads every
+// .json and .json.gz file within it as a test vector. Files are decoded
+// concurrently by a bounded pool of workers, and results are emitted on the
+// returned channel as they become available, in no particular order. Errors
+// encountered while walking the tree or loading an individual file are
+// emitted as results too. The channel is closed once all files have been
+// processed.
+//
+// An error is returned immediately if root is not a directory.
+func LoadTestVectorDir(root string) (<-chan LoadedVector, error) {
+	return LoadTestVectorDirContext(context.Background(), root)
+}
+
+// LoadTestVectorDirContext is like LoadTestVectorDir, but stops walking the
+// tree and loading files once the context is done. Pending results are then
+// dropped, and the channel is closed without waiting for the caller to drain
+// it, so no goroutines are left behind; callers can tell an interrupted load
+// from a complete one by checking ctx.Err() once the channel is closed.
+func LoadTestVectorDirContext(ctx context.Context, root string) (<-chan LoadedVector, error) {
+	switch stat, err := os.Stat(root); {
+	case err != nil:
+		return nil, fmt.Errorf("failed to stat directory %s: %w", root, err)
+	case !stat.IsDir():
+		return nil, fmt.Errorf("path %s exists, but it's not a directory", root)
+	}
+
+	var (
+		paths   = make(chan string)
+		results = make(chan LoadedVector)
+		wg      sync.WaitGroup
+	)
+
+	// emit sends a result, unless the context is done first.
+	emit := func(res LoadedVector) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		select {
+		case results <- res:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(paths)
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if !emit(LoadedVector{Path: path, Err: err}) {
+					return ctx.Err()
+				}
+				return nil
+			}
+			if info.IsDir() || !(strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".json.gz")) {
+				return nil
+			}
+			select {
+			case paths <- path:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			emit(LoadedVector{Path: root, Err: err})
+		}
+	}()
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if ctx.Err() != nil {
+					return
+				}
+				tv, err := LoadTestVectorFile(path)
+				emit(LoadedVector{Path: path, Vector: tv, Err: err})
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results, nil
+}
@@ -0,0 +1,226 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMessageVector = `{
+  "class": "message",
+  "_meta": {"id": "test-vector", "gen": [{"source": "test"}]},
+  "car": "",
+  "preconditions": {
+    "variants": [{"id": "genesis", "epoch": 0, "nv": 0}],
+    "state_tree": {"root_cid": {"/": "bafy2bzacect5q7wezd7b4kgztxijz6kyupmsolvvpkc2lxphpzhx6zgvdanfe"}}
+  },
+  "apply_messages": [{"bytes": "igBCAGRCAGQAQgAKCEIAyEIAAQBA", "epoch_offset": 0}],
+  "postconditions": {
+    "state_tree": {"root_cid": {"/": "bafy2bzacect5q7wezd7b4kgztxijz6kyupmsolvvpkc2lxphpzhx6zgvdanfe"}},
+    "receipts": [{"exit_code": 7, "return": "", "gas_used": 0}]
+  }
+}`
+
+func TestLoadTestVector(t *testing.T) {
+	tv, err := LoadTestVector(strings.NewReader(testMessageVector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tv.Meta.ID != "test-vector" || len(tv.ApplyMessages) != 1 {
+		t.Fatalf("unexpected vector: %+v", tv)
+	}
+
+	invalid := strings.Replace(testMessageVector, `"receipts": [{"exit_code": 7, "return": "", "gas_used": 0}]`, `"receipts": []`, 1)
+	if _, err := LoadTestVector(strings.NewReader(invalid)); err == nil || !strings.Contains(err.Error(), "validating test vector") {
+		t.Fatalf("expected validation error, got: %v", err)
+	}
+
+	if _, err := LoadTestVector(strings.NewReader("{")); err == nil || !strings.Contains(err.Error(), "decoding test vector") {
+		t.Fatalf("expected decoding error, got: %v", err)
+	}
+}
+
+func TestLoadTestVectorStrict(t *testing.T) {
+	if _, err := LoadTestVectorStrict(strings.NewReader(testMessageVector)); err != nil {
+		t.Fatal(err)
+	}
+
+	typo := strings.Replace(testMessageVector, `"preconditions"`, `"preconditons"`, 1)
+	if _, err := LoadTestVector(strings.NewReader(typo)); err != nil {
+		t.Fatalf("expected the lenient loader to ignore the unknown field, got: %s", err)
+	}
+	if _, err := LoadTestVectorStrict(strings.NewReader(typo)); err == nil || !strings.Contains(err.Error(), `unknown field "preconditons"`) {
+		t.Fatalf("expected an unknown field error, got: %v", err)
+	}
+
+	nested := strings.Replace(testMessageVector, `"gas_used": 0`, `"gas_used": 0, "gas_limit": 0`, 1)
+	if _, err := LoadTestVectorStrict(strings.NewReader(nested)); err == nil || !strings.Contains(err.Error(), `unknown field "gas_limit"`) {
+		t.Fatalf("expected an unknown field error, got: %v", err)
+	}
+}
+
+func TestLoadTestVectorDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.json":          testMessageVector,
+		"nested/b.json":   testMessageVector,
+		"nested/bad.json": "{",
+		"ignored.txt":     "not a vector",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ch, err := LoadTestVectorDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ok, failed int
+	for res := range ch {
+		switch {
+		case res.Err != nil:
+			if filepath.Base(res.Path) != "bad.json" {
+				t.Errorf("unexpected failure loading %s: %s", res.Path, res.Err)
+			}
+			failed++
+		case res.Vector != nil:
+			ok++
+		}
+	}
+	if ok != 2 || failed != 1 {
+		t.Fatalf("expected 2 loaded and 1 failed vector; got %d and %d", ok, failed)
+	}
+
+	if _, err := LoadTestVectorDir(filepath.Join(dir, "a.json")); err == nil {
+		t.Fatal("expected error when loading from a file")
+	}
+}
+
+func TestLoadTestVectorDirContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 32; i++ {
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.json", i)), []byte(testMessageVector), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := LoadTestVectorDirContext(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := <-ch; res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	cancel()
+	for range ch {
+		// the channel must be closed once the loaders bail out.
+	}
+
+	ch, err = LoadTestVectorDirContext(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for res := range ch {
+		t.Fatalf("expected no results once the context is done, got: %+v", res)
+	}
+}
+
+func TestWriteTestVectorFileGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tv, err := LoadTestVector(strings.NewReader(testMessageVector))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"plain.json", "compressed.json.gz"} {
+		p := filepath.Join(dir, name)
+		if err := WriteTestVectorFile(p, tv); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed := bytes.HasPrefix(raw, gzipMagic); compressed != strings.HasSuffix(name, ".gz") {
+			t.Fatalf("%s: unexpected compression state: %t", name, compressed)
+		}
+		if !strings.HasSuffix(name, ".gz") {
+			var expected bytes.Buffer
+			if err := WriteIndented(&expected, tv); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(raw, expected.Bytes()) {
+				t.Fatalf("%s: expected the layout of WriteIndented, got:\n%s", name, raw)
+			}
+		}
+
+		loaded, err := LoadTestVectorFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tv, loaded) {
+			t.Fatalf("%s: loaded vector differs from written vector", name)
+		}
+	}
+}
+
+func TestWriteIndented(t *testing.T) {
+	tv := fullTestVector(t)
+
+	var a, b bytes.Buffer
+	if err := WriteIndented(&a, tv); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndented(&b, tv.Clone()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Fatal("expected identical output for identical vectors")
+	}
+
+	out := a.String()
+	if !strings.HasPrefix(out, "{\n  \"class\": \"tipset\",\n") || !strings.HasSuffix(out, "}\n") {
+		t.Fatalf("unexpected formatting:\n%s", out)
+	}
+	if !strings.Contains(out, "\n  \"car\": \"Y2FyIGJ5dGVz\",\n") {
+		t.Fatalf("expected the car blob on a single line:\n%s", out)
+	}
+
+	var decoded TestVector
+	if err := json.Unmarshal(a.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(tv) {
+		t.Fatal("round trip yielded a different vector")
+	}
+}
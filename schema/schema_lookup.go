@@ -0,0 +1,117 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Lookup resolves an RFC 6901 JSON Pointer (e.g. "/postconditions/receipts/0/gas_used")
+// against the JSON form of the vector, and returns the Go value found there,
+// e.g. an int64 for a gas_used, a cid.Cid for a root_cid, or a *Receipt for a
+// receipt. The empty pointer refers to the whole vector.
+//
+// The values are not re-encoded: CIDs, addresses, token amounts and binary
+// blobs are returned as they're held, and can't be descended into. Randomness
+// rules are indexed positionally, like the arrays they're encoded as. Message
+// repo entries are keyed by CID string. An error is returned if the pointer is
+// malformed, or doesn't resolve to a value.
+func (tv *TestVector) Lookup(pointer string) (interface{}, error) {
+	if pointer == "" {
+		return tv, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q: must be empty or start with /", pointer)
+	}
+
+	v := reflect.ValueOf(tv)
+	for _, tok := range strings.Split(pointer[1:], "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, fmt.Errorf("resolving %q: cannot look up %q in a null value", pointer, tok)
+			}
+			v = v.Elem()
+		}
+
+		next, err := lookupToken(v, tok)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %q: %w", pointer, err)
+		}
+		v = next
+	}
+	return v.Interface(), nil
+}
+
+// lookupToken returns the member of v referenced by a JSON Pointer token.
+func lookupToken(v reflect.Value, tok string) (reflect.Value, error) {
+	switch t := v.Type(); {
+	case t == cidType, t == addressType, t == tokenAmountType, t == base64BytesType:
+		return reflect.Value{}, fmt.Errorf("cannot look up %q in a %s", tok, t)
+
+	case t == randomnessRuleType:
+		i, err := lookupIndex(tok, t.NumField())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return v.Field(i), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if name, _, ok := jsonFieldName(v.Type().Field(i)); ok && name == tok {
+				return v.Field(i), nil
+			}
+		}
+		return reflect.Value{}, fmt.Errorf("no field %q in %s", tok, v.Type())
+
+	case reflect.Slice, reflect.Array:
+		i, err := lookupIndex(tok, v.Len())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return v.Index(i), nil
+
+	case reflect.Map:
+		var key reflect.Value
+		switch kt := v.Type().Key(); {
+		case kt == cidType:
+			c, err := cid.Decode(tok)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid cid key %q: %w", tok, err)
+			}
+			key = reflect.ValueOf(c)
+		case kt.Kind() == reflect.String:
+			key = reflect.ValueOf(tok).Convert(kt)
+		default:
+			return reflect.Value{}, fmt.Errorf("cannot look up %q in a map keyed by %s", tok, kt)
+		}
+		elem := v.MapIndex(key)
+		if !elem.IsValid() {
+			return reflect.Value{}, fmt.Errorf("no key %q", tok)
+		}
+		return elem, nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot look up %q in a %s", tok, v.Type())
+}
+
+// lookupIndex parses an array index token, as defined by RFC 6901, and checks
+// it against the length of the array.
+func lookupIndex(tok string, length int) (int, error) {
+	if tok == "-" {
+		return 0, fmt.Errorf("index %q refers to a nonexistent element", tok)
+	}
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') || strings.TrimLeft(tok, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i >= length {
+		return 0, fmt.Errorf("index %s out of range; array has %d elements", tok, length)
+	}
+	return i, nil
+}
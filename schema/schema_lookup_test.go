@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tv := fullTestVector(t)
+	tv.Pre.NamedStateTrees["a/b~c"] = StateTree{RootCID: mkCid(t, "escaped")}
+
+	cases := []struct {
+		pointer string
+		want    interface{}
+	}{
+		{"", tv},
+		{"/class", ClassTipset},
+		{"/selector/chaos_actor", "true"},
+		{"/_meta/id", "full-vector"},
+		{"/postconditions/receipts/0/gas_used", int64(1234)},
+		{"/postconditions/receipts/0", tv.Post.Receipts[0]},
+		{"/postconditions/receipts/1", (*Receipt)(nil)},
+		{"/postconditions/state_tree/root_cid", mkCid(t, "root")},
+		{"/preconditions/named_state_trees/snapshot/root_cid", mkCid(t, "root")},
+		{"/preconditions/named_state_trees/a~1b~0c/root_cid", mkCid(t, "escaped")},
+		{"/preconditions/blockseq/genesis_ts", tv.Pre.GenesisTs},
+		{"/randomness/0/on/2", int64(100)},
+		{"/randomness/0/ret", Base64EncodedBytes("random")},
+		{"/apply_tipsets/0/blocks/0/messages/0", Base64EncodedBytes("msg")},
+		{"/apply_blockseq/message_repo/" + mkCid(t, "msg").String(), Base64EncodedBytes("msg")},
+		{"/hints/1", HintNegate},
+	}
+	for _, c := range cases {
+		got, err := tv.Lookup(c.pointer)
+		if err != nil {
+			t.Errorf("%q: %s", c.pointer, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: expected %v, got %v", c.pointer, c.want, got)
+		}
+	}
+
+	errs := []struct {
+		pointer string
+		err     string
+	}{
+		{"class", "must be empty or start with /"},
+		{"/nope", `no field "nope"`},
+		{"/hints/01", `invalid array index "01"`},
+		{"/hints/-", "nonexistent element"},
+		{"/hints/2", "index 2 out of range"},
+		{"/postconditions/receipts/1/gas_used", "null value"},
+		{"/postconditions/state_tree/root_cid/x", "cannot look up"},
+		{"/preconditions/named_state_trees/other", `no key "other"`},
+		{"/apply_blockseq/message_repo/bogus", "invalid cid key"},
+	}
+	for _, c := range errs {
+		if _, err := tv.Lookup(c.pointer); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: expected error containing %q, got %v", c.pointer, c.err, err)
+		}
+	}
+}
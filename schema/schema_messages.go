@@ -0,0 +1,54 @@
+package schema
+
+import "fmt"
+
+// MessageDecoder decodes the serialized form of a message, usually into the
+// message type of the implementation (e.g. types.DecodeMessage in Lotus).
+type MessageDecoder func(b []byte) (interface{}, error)
+
+// MessageIter iterates over the messages to apply of a test vector, decoding
+// each one only when it's reached. Obtain one through TestVector.Messages.
+//
+//	it := tv.Messages(decode)
+//	for it.Next() {
+//		i, msg, err := it.Message()
+//		...
+//	}
+type MessageIter struct {
+	msgs   []Message
+	decode MessageDecoder
+
+	idx int
+	msg interface{}
+	err error
+}
+
+// Messages returns an iterator over the messages to apply of this vector,
+// which decodes them with the supplied function, one by one, as the iteration
+// advances. Only the current message is retained, which keeps memory low for
+// vectors with many messages when the caller doesn't need them all at once.
+func (tv TestVector) Messages(decode MessageDecoder) *MessageIter {
+	return &MessageIter{msgs: tv.ApplyMessages, decode: decode, idx: -1}
+}
+
+// Next advances the iterator to the next message, decoding it, and reports
+// whether there was one. A message that fails to decode doesn't stop the
+// iteration; its error is returned by Message.
+func (it *MessageIter) Next() bool {
+	if it.idx+1 >= len(it.msgs) {
+		it.idx, it.msg, it.err = len(it.msgs), nil, nil
+		return false
+	}
+	it.idx++
+	it.msg, it.err = it.decode(it.msgs[it.idx].Bytes)
+	if it.err != nil {
+		it.msg, it.err = nil, fmt.Errorf("decoding message at index %d: %w", it.idx, it.err)
+	}
+	return true
+}
+
+// Message returns the index of the current message within ApplyMessages,
+// along with the message as decoded, or the error decoding it.
+func (it *MessageIter) Message() (int, interface{}, error) {
+	return it.idx, it.msg, it.err
+}
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMessageIter(t *testing.T) {
+	tv := TestVector{ApplyMessages: []Message{{Bytes: []byte("a")}, {Bytes: []byte("bad")}, {Bytes: []byte("c")}}}
+
+	var decoded int
+	it := tv.Messages(func(b []byte) (interface{}, error) {
+		decoded++
+		if string(b) == "bad" {
+			return nil, errors.New("malformed")
+		}
+		return strings.ToUpper(string(b)), nil
+	})
+
+	if !it.Next() {
+		t.Fatal("expected a message")
+	}
+	if i, msg, err := it.Message(); i != 0 || msg != "A" || err != nil {
+		t.Fatalf("unexpected message: %d, %v, %v", i, msg, err)
+	}
+	if decoded != 1 {
+		t.Fatalf("expected messages to be decoded lazily; decoded %d", decoded)
+	}
+
+	if !it.Next() {
+		t.Fatal("expected a message")
+	}
+	if i, msg, err := it.Message(); i != 1 || msg != nil || err == nil || !strings.Contains(err.Error(), "decoding message at index 1: malformed") {
+		t.Fatalf("unexpected message: %d, %v, %v", i, msg, err)
+	}
+
+	if !it.Next() {
+		t.Fatal("expected a message")
+	}
+	if i, msg, err := it.Message(); i != 2 || msg != "C" || err != nil {
+		t.Fatalf("unexpected message: %d, %v, %v", i, msg, err)
+	}
+	if it.Next() || it.Next() {
+		t.Fatal("expected the iteration to be over")
+	}
+}
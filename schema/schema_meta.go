@@ -0,0 +1,206 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxIDLength is the maximum length of a metadata ID.
+const MaxIDLength = 128
+
+// GenerateID derives a stable, human-readable ID for the vector from its
+// class, its tags and its fingerprint, e.g. "message-transfer-5qczsbcwxyzq".
+// Tags are lowercased, and characters other than letters, digits and dashes
+// are replaced with dashes. The ID is truncated to MaxIDLength, keeping the
+// fingerprint.
+//
+// As the fingerprint excludes the ID, generating it doesn't change it. It
+// returns an error if the vector cannot be fingerprinted, e.g. as it holds a
+// negative offset.
+func GenerateID(tv *TestVector) (string, error) {
+	fp, err := tv.Fingerprint()
+	if err != nil {
+		return "", fmt.Errorf("fingerprinting test vector: %w", err)
+	}
+	s := fp.String()
+	short := s[len(s)-12:]
+
+	parts := []string{string(tv.Class)}
+	if tv.Meta != nil {
+		for _, tag := range tv.Meta.Tags {
+			if tag = sanitizeIDPart(tag); tag != "" {
+				parts = append(parts, tag)
+			}
+		}
+	}
+	prefix := strings.Join(parts, "-")
+	if max := MaxIDLength - len(short) - 1; len(prefix) > max {
+		prefix = strings.TrimRight(prefix[:max], "-")
+	}
+	return prefix + "-" + short, nil
+}
+
+func sanitizeIDPart(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, s)
+	return strings.Trim(s, "-")
+}
+
+// validateID checks that the ID, if set, looks like an identifier: it's no
+// longer than MaxIDLength, and it has no whitespace or control characters.
+func (m *Metadata) validateID() error {
+	if len(m.ID) > MaxIDLength {
+		return fmt.Errorf("metadata id is %d characters long; the maximum is %d", len(m.ID), MaxIDLength)
+	}
+	if strings.IndexFunc(m.ID, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("metadata id %q has whitespace or control characters", m.ID)
+	}
+	return nil
+}
+
+// AddTag adds the tag to the metadata, unless it's already present. Tags are
+// kept sorted, so that the serialized form is deterministic.
+func (m *Metadata) AddTag(t string) {
+	if m.HasTag(t) {
+		return
+	}
+	m.Tags = append(m.Tags, t)
+	sort.Strings(m.Tags)
+}
+
+// HasTag reports whether the metadata carries the tag.
+func (m *Metadata) HasTag(t string) bool {
+	for _, tag := range m.Tags {
+		if tag == t {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveTag removes the tag from the metadata, if present.
+func (m *Metadata) RemoveTag(t string) {
+	tags := m.Tags[:0]
+	for _, tag := range m.Tags {
+		if tag != t {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	m.Tags = tags
+}
+
+// Relation is the kind of relationship between two related vectors.
+type Relation string
+
+const (
+	// RelationDerivedFrom indicates that the vector was derived from the
+	// target, e.g. by adapting it to another network version.
+	RelationDerivedFrom Relation = "derived-from"
+
+	// RelationNegates indicates that the vector is the negative counterpart
+	// of the target; see NegateVariant.
+	RelationNegates Relation = "negates"
+
+	// RelationSameScenario indicates that the vector exercises the same
+	// scenario as the target, under different preconditions.
+	RelationSameScenario Relation = "same-scenario"
+)
+
+var knownRelations = map[Relation]struct{}{
+	RelationDerivedFrom:  {},
+	RelationNegates:      {},
+	RelationSameScenario: {},
+}
+
+// RelatedVector links a vector to another one.
+type RelatedVector struct {
+	Relation Relation `json:"relation"`
+
+	// Target identifies the related vector, either by its metadata ID or by
+	// its fingerprint (see TestVector.Fingerprint).
+	Target string `json:"target"`
+}
+
+// AddRelation links the metadata to the target vector with the given
+// relation, unless that link is already present. Links are kept sorted, so
+// that the serialized form is deterministic.
+func (m *Metadata) AddRelation(relation Relation, target string) {
+	r := RelatedVector{Relation: relation, Target: target}
+	for _, existing := range m.Related {
+		if existing == r {
+			return
+		}
+	}
+	m.Related = append(m.Related, r)
+	sort.Slice(m.Related, func(i, j int) bool {
+		a, b := m.Related[i], m.Related[j]
+		if a.Relation != b.Relation {
+			return a.Relation < b.Relation
+		}
+		return a.Target < b.Target
+	})
+}
+
+// validateRelated checks that related vectors are linked through known
+// relations, and that their targets are set.
+func (m *Metadata) validateRelated() error {
+	for i, r := range m.Related {
+		if _, ok := knownRelations[r.Relation]; !ok {
+			return fmt.Errorf("related vector at index %d has unknown relation %q", i, r.Relation)
+		}
+		if r.Target == "" {
+			return fmt.Errorf("related vector at index %d has no target", i)
+		}
+	}
+	return nil
+}
+
+// GenStrictness determines how strictly the generation metadata of a vector
+// is checked by Metadata.ValidateGen.
+type GenStrictness int
+
+const (
+	// GenOptional doesn't check generation metadata at all. This is
+	// appropriate for older vectors, which may carry no provenance.
+	GenOptional GenStrictness = iota
+
+	// GenRequireSource requires at least one generation entry, and every
+	// entry to name its source.
+	GenRequireSource
+
+	// GenRequireVersion additionally requires every entry to carry the
+	// version of its source.
+	GenRequireVersion
+)
+
+// ValidateGen checks that the generation metadata records the provenance of
+// the vector, to the extent required by the strictness level.
+func (m *Metadata) ValidateGen(level GenStrictness) error {
+	if level == GenOptional {
+		return nil
+	}
+	if m == nil || len(m.Gen) == 0 {
+		return fmt.Errorf("metadata must have at least one generation entry")
+	}
+	for i, g := range m.Gen {
+		if g.Source == "" {
+			return fmt.Errorf("generation entry at index %d has no source", i)
+		}
+		if level >= GenRequireVersion && g.Version == "" {
+			return fmt.Errorf("generation entry at index %d (%s) has no version", i, g.Source)
+		}
+	}
+	return nil
+}
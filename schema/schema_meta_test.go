@@ -0,0 +1,138 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMetadataTags(t *testing.T) {
+	var m Metadata
+	for _, tag := range []string{"vm", "actors", "vm", "gas"} {
+		m.AddTag(tag)
+	}
+	if expected := []string{"actors", "gas", "vm"}; !reflect.DeepEqual(m.Tags, expected) {
+		t.Fatalf("expected tags %v, got %v", expected, m.Tags)
+	}
+	if !m.HasTag("gas") || m.HasTag("chaos") {
+		t.Fatal("unexpected HasTag results")
+	}
+
+	m.RemoveTag("gas")
+	m.RemoveTag("chaos")
+	if expected := []string{"actors", "vm"}; !reflect.DeepEqual(m.Tags, expected) {
+		t.Fatalf("expected tags %v, got %v", expected, m.Tags)
+	}
+
+	// removing the last tag leaves no tags, so that they're omitted from
+	// the json form.
+	m.RemoveTag("actors")
+	m.RemoveTag("vm")
+	if m.Tags != nil {
+		t.Fatalf("expected no tags, got %v", m.Tags)
+	}
+}
+
+func TestMetadataValidateGen(t *testing.T) {
+	var (
+		full    = &Metadata{Gen: []GenerationData{{Source: "genscript", Version: "v1"}}}
+		noVer   = &Metadata{Gen: []GenerationData{{Source: "genscript", Version: "v1"}, {Source: "lotus"}}}
+		noSrc   = &Metadata{Gen: []GenerationData{{Version: "v1"}}}
+		noGen   = &Metadata{}
+		noMeta  *Metadata
+		cases   = []*Metadata{full, noVer, noSrc, noGen, noMeta}
+		results = map[GenStrictness][]bool{
+			GenOptional:       {true, true, true, true, true},
+			GenRequireSource:  {true, true, false, false, false},
+			GenRequireVersion: {true, false, false, false, false},
+		}
+	)
+	for level, valid := range results {
+		for i, m := range cases {
+			if err := m.ValidateGen(level); (err == nil) != valid[i] {
+				t.Errorf("strictness %d, case %d: expected valid: %t, got error: %v", level, i, valid[i], err)
+			}
+		}
+	}
+}
+
+func TestMetadataRelations(t *testing.T) {
+	var m Metadata
+	m.AddRelation(RelationSameScenario, "b")
+	m.AddRelation(RelationDerivedFrom, "c")
+	m.AddRelation(RelationSameScenario, "a")
+	m.AddRelation(RelationDerivedFrom, "c")
+	expected := []RelatedVector{
+		{Relation: RelationDerivedFrom, Target: "c"},
+		{Relation: RelationSameScenario, Target: "a"},
+		{Relation: RelationSameScenario, Target: "b"},
+	}
+	if !reflect.DeepEqual(m.Related, expected) {
+		t.Fatalf("expected related vectors %v, got %v", expected, m.Related)
+	}
+
+	tv := TestVector{Meta: &m}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m.AddRelation("clone-of", "a")
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), `unknown relation "clone-of"`) {
+		t.Fatalf("expected an unknown relation error, got: %v", err)
+	}
+}
+
+func TestGenerateID(t *testing.T) {
+	tv := fullTestVector(t)
+	tv.Meta.Tags = []string{"Transfer", "gas_v2", "--"}
+
+	generate := func() string {
+		id, err := GenerateID(tv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	id := generate()
+	if !strings.HasPrefix(id, "tipset-transfer-gas-v2-") || len(id) != len("tipset-transfer-gas-v2-")+12 {
+		t.Fatalf("unexpected id %q", id)
+	}
+	tv.Meta.ID = id
+	if again := generate(); again != id {
+		t.Fatalf("expected the id to be stable, got %q and %q", id, again)
+	}
+	tv.Meta.Desc = "a different description"
+	if again := generate(); again != id {
+		t.Fatalf("expected the id not to depend on the description, got %q and %q", id, again)
+	}
+
+	tv.Meta.Tags = []string{strings.Repeat("a", 2*MaxIDLength)}
+	if long := generate(); len(long) != MaxIDLength || long[len(long)-13] != '-' {
+		t.Fatalf("expected a truncated id keeping the fingerprint, got %q", long)
+	}
+	// truncation doesn't leave a dash behind.
+	tv.Meta.Tags = []string{strings.Repeat("a", 107) + "-" + strings.Repeat("b", 20)}
+	if long := generate(); strings.Contains(long, "--") || len(long) != MaxIDLength-1 {
+		t.Fatalf("expected a truncated id without a trailing dash, got %q", long)
+	}
+
+	tv.ApplyBlockseq.Blocks[0].OffsetMs = -1
+	if _, err := GenerateID(tv); err == nil {
+		t.Fatal("expected an error for a vector that cannot be fingerprinted")
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	for id, valid := range map[string]bool{
+		"":                                 true,
+		"msg_apply--fail-receipt-gas":      true,
+		"has space":                        false,
+		"new\nline":                        false,
+		strings.Repeat("a", MaxIDLength):   true,
+		strings.Repeat("a", MaxIDLength+1): false,
+	} {
+		tv := TestVector{Meta: &Metadata{ID: id}}
+		if err := tv.Validate(); (err == nil) != valid {
+			t.Errorf("id %q: expected valid=%t, got: %v", id, valid, err)
+		}
+	}
+}
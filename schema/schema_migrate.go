@@ -0,0 +1,83 @@
+package schema
+
+import "fmt"
+
+const (
+	// CurrentVersion is the version of the schema implemented by this
+	// package. Vectors produced with it should carry it in
+	// Metadata.SchemaVersion.
+	CurrentVersion = "2"
+
+	// legacyVersion is the version assumed for vectors that carry no schema
+	// version, as they predate the field.
+	legacyVersion = "1"
+)
+
+// migration upgrades a vector from one schema version to the next.
+type migration struct {
+	to string
+	fn func(tv *TestVector) error
+}
+
+// migrations are the upgrade steps, keyed by the schema version they upgrade
+// from.
+var migrations = map[string]migration{
+	legacyVersion: {to: "2", fn: migrateV1},
+}
+
+// Migrate upgrades a vector produced with an older version of the schema to
+// CurrentVersion, applying every intermediate migration in turn. The input is
+// left untouched; the upgraded vector is a copy. Vectors that are already at
+// CurrentVersion are returned as-is.
+//
+// Changes in the JSON representation that are handled when unmarshalling
+// (e.g. numeric token amounts) need no migration: re-encoding a migrated
+// vector emits the current representation.
+func Migrate(tv *TestVector) (*TestVector, error) {
+	version := legacyVersion
+	if tv.Meta != nil && tv.Meta.SchemaVersion != "" {
+		version = tv.Meta.SchemaVersion
+	}
+	if version == CurrentVersion {
+		return tv, nil
+	}
+
+	ret := tv.Clone()
+	if ret.Meta == nil {
+		ret.Meta = new(Metadata)
+	}
+	for version != CurrentVersion {
+		m, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("cannot migrate test vector from unknown schema version %q", version)
+		}
+		if err := m.fn(ret); err != nil {
+			return nil, fmt.Errorf("migrating test vector from schema version %s to %s: %w", version, m.to, err)
+		}
+		version = m.to
+		ret.Meta.SchemaVersion = version
+	}
+	return ret, nil
+}
+
+// migrateV1 upgrades a legacy vector to version 2, which introduced hint
+// validation and sorted tags:
+//
+//   - unknown hints are rejected, as they're usually misspelled standard
+//     hints, which drivers would otherwise silently ignore; vendor-specific
+//     hints must be given the HintVendorPrefix by hand.
+//   - tags are sorted and deduplicated, as Metadata.AddTag keeps them.
+func migrateV1(tv *TestVector) error {
+	for i, h := range tv.Hints {
+		if !h.IsKnown() {
+			return fmt.Errorf("unknown hint %q at index %d", h, i)
+		}
+	}
+
+	tags := tv.Meta.Tags
+	tv.Meta.Tags = nil
+	for _, t := range tags {
+		tv.Meta.AddTag(t)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	legacy := &TestVector{
+		Class: ClassMessage,
+		Post:  &Postconditions{},
+		Hints: []Hint{HintIncorrect, "x-slow"},
+		Meta:  &Metadata{ID: "legacy", Version: "v1", Tags: []string{"vm", "actors", "vm"}},
+	}
+
+	migrated, err := Migrate(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.Meta.SchemaVersion != CurrentVersion {
+		t.Fatalf("expected schema version %s, got %q", CurrentVersion, migrated.Meta.SchemaVersion)
+	}
+	if migrated.Meta.Version != "v1" {
+		t.Fatalf("expected the vector version to be preserved, got %q", migrated.Meta.Version)
+	}
+	if expected := []string{"actors", "vm"}; !reflect.DeepEqual(migrated.Meta.Tags, expected) {
+		t.Fatalf("expected tags %v, got %v", expected, migrated.Meta.Tags)
+	}
+	if err := migrated.Validate(); err != nil {
+		t.Fatalf("migrated vector is invalid: %s", err)
+	}
+	if !reflect.DeepEqual(legacy.Meta.Tags, []string{"vm", "actors", "vm"}) || legacy.Meta.SchemaVersion != "" {
+		t.Fatal("migrating mutated the input")
+	}
+
+	// migrating a current vector is a no-op.
+	again, err := Migrate(migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != migrated {
+		t.Fatal("expected a current vector to be returned as-is")
+	}
+
+	// vectors without metadata are migrated too.
+	if migrated, err := Migrate(&TestVector{}); err != nil || migrated.Meta.SchemaVersion != CurrentVersion {
+		t.Fatalf("unexpected result migrating a vector without metadata: %+v, %v", migrated, err)
+	}
+
+	// unknown hints are likely misspelled, so they're rejected rather than
+	// silently ignored.
+	legacy.Hints = []Hint{HintIncorrect, "negated"}
+	if _, err := Migrate(legacy); err == nil || !strings.Contains(err.Error(), `unknown hint "negated" at index 1`) {
+		t.Fatalf("expected an unknown hint error, got: %v", err)
+	}
+
+	if _, err := Migrate(&TestVector{Meta: &Metadata{SchemaVersion: "99"}}); err == nil {
+		t.Fatal("expected an error migrating from an unknown version")
+	}
+}
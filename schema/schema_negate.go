@@ -0,0 +1,38 @@
+package schema
+
+import "fmt"
+
+// NegatedIDSuffix is appended to the metadata ID of vectors produced by
+// NegateVariant.
+const NegatedIDSuffix = "-negated"
+
+// NegateVariant derives a negative counterpart of this vector: a copy with the
+// same postconditions, hinted with HintIncorrect and HintNegate, instructing
+// drivers to check that the postconditions are NOT met. A correct
+// implementation meets them, so the negated vector fails against it, proving
+// that drivers do check postconditions. Its metadata ID is suffixed with
+// NegatedIDSuffix, and it's linked to this vector with RelationNegates.
+//
+// It returns an error if the vector has no postconditions, or if it's already
+// negated.
+func (tv TestVector) NegateVariant() (*TestVector, error) {
+	if tv.HasHint(HintNegate) {
+		return nil, fmt.Errorf("vector is already negated")
+	}
+	if tv.Post == nil {
+		return nil, fmt.Errorf("vector has no postconditions to negate")
+	}
+
+	ret := tv.Clone()
+	if !ret.HasHint(HintIncorrect) {
+		ret.Hints = append(ret.Hints, HintIncorrect)
+	}
+	ret.Hints = append(ret.Hints, HintNegate)
+	if ret.Meta != nil {
+		if tv.Meta.ID != "" {
+			ret.Meta.AddRelation(RelationNegates, tv.Meta.ID)
+		}
+		ret.Meta.ID += NegatedIDSuffix
+	}
+	return ret, nil
+}
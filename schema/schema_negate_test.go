@@ -0,0 +1,49 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNegateVariant(t *testing.T) {
+	orig := fullTestVector(t)
+	orig.Hints = nil
+
+	neg, err := orig.NegateVariant()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !neg.HasHint(HintNegate) || !neg.HasHint(HintIncorrect) {
+		t.Fatalf("expected negated vector to carry the incorrect and negate hints, got %v", neg.Hints)
+	}
+	if neg.Meta.ID != "full-vector-negated" {
+		t.Fatalf("unexpected metadata id %q", neg.Meta.ID)
+	}
+	if expected := []RelatedVector{{Relation: RelationNegates, Target: "full-vector"}}; !reflect.DeepEqual(neg.Meta.Related, expected) {
+		t.Fatalf("expected related vectors %v, got %v", expected, neg.Meta.Related)
+	}
+	if !reflect.DeepEqual(neg.Post, orig.Post) {
+		t.Fatal("expected the postconditions to be preserved")
+	}
+
+	// a correct result meets the postconditions, so it fails the negated
+	// vector, while an incorrect one passes it.
+	correct := &ExecutionResult{StateRoot: orig.Post.StateTree.RootCID, Receipts: orig.Post.Receipts}
+	if err := neg.Post.Check(neg, correct); err != nil {
+		t.Fatalf("expected a correct result to meet the postconditions, and thus fail the negated vector, got: %s", err)
+	}
+	incorrect := &ExecutionResult{StateRoot: mkCid(t, "other"), Receipts: orig.Post.Receipts}
+	if err := neg.Post.Check(neg, incorrect); err == nil {
+		t.Fatal("expected an incorrect result not to meet the postconditions, and thus pass the negated vector")
+	}
+	if orig.HasHint(HintNegate) || orig.Meta.ID != "full-vector" {
+		t.Fatal("negating mutated the original vector")
+	}
+
+	if _, err := neg.NegateVariant(); err == nil {
+		t.Fatal("expected an error negating a negated vector")
+	}
+	if _, err := (TestVector{}).NegateVariant(); err == nil {
+		t.Fatal("expected an error negating a vector without postconditions")
+	}
+}
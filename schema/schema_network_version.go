@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// NetworkVersion is a Filecoin network version, e.g. 16 for the upgrade that
+// introduced the FVM. It must be interpreted by the driver as a
+// network.Version in Lotus, or equivalent type in other implementations.
+type NetworkVersion uint
+
+// MaxNetworkVersion is the latest network version known to this package.
+// Validate rejects later ones, which are most likely typos; it's raised as
+// network upgrades ship.
+const MaxNetworkVersion NetworkVersion = 25
+
+// NetworkVersion returns the network version the vector pins, if any. In
+// order of precedence, that's:
+//
+//   - the network version of its preconditions;
+//   - the network version of its variants, provided they all share it;
+//   - the one its selector holds under SelectorNetworkVersion, or an alias of
+//     it, provided it's an exact version number rather than a comparison like
+//     ">=16".
+//
+// Drivers should rely on it, rather than parse selectors themselves.
+func (tv TestVector) NetworkVersion() (NetworkVersion, bool) {
+	if nv, ok := tv.Pre.networkVersion(); ok {
+		return nv, true
+	}
+	return tv.Selector.networkVersion()
+}
+
+// networkVersion returns the network version the preconditions pin, if any:
+// their own, or else the one all their variants share.
+func (p *Preconditions) networkVersion() (NetworkVersion, bool) {
+	switch {
+	case p == nil:
+		return 0, false
+	case p.NetworkVersion != nil:
+		return *p.NetworkVersion, true
+	case len(p.Variants) == 0:
+		return 0, false
+	}
+	nv := p.Variants[0].NetworkVersion
+	for _, v := range p.Variants[1:] {
+		if v.NetworkVersion != nv {
+			return 0, false
+		}
+	}
+	return NetworkVersion(nv), true
+}
+
+// networkVersion returns the network version the selector pins, if any. The
+// key itself is looked up before its aliases, in order.
+func (s Selector) networkVersion() (NetworkVersion, bool) {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		if key, ok := canonicalSelectorKey(k); ok && key == SelectorNetworkVersion && k != key {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range append([]string{SelectorNetworkVersion}, keys...) {
+		if nv, err := strconv.ParseUint(s[k], 10, 32); err == nil {
+			return NetworkVersion(nv), true
+		}
+	}
+	return 0, false
+}
+
+// validateNetworkVersion checks that the network version of the
+// preconditions, if any, is a known one, and that the variants agree with it.
+func (tv TestVector) validateNetworkVersion() error {
+	if tv.Pre == nil || tv.Pre.NetworkVersion == nil {
+		return nil
+	}
+	nv := *tv.Pre.NetworkVersion
+	if nv > MaxNetworkVersion {
+		return fmt.Errorf("network version %d is beyond the latest known network version %d", nv, MaxNetworkVersion)
+	}
+	for i, v := range tv.Pre.Variants {
+		if NetworkVersion(v.NetworkVersion) != nv {
+			return fmt.Errorf("variant %q at index %d has network version %d, but the preconditions pin network version %d", v.ID, i, v.NetworkVersion, nv)
+		}
+	}
+	return nil
+}
+
+// lintNetworkVersion warns about selectors pinning a network version other
+// than the one of the preconditions or their variants, which takes
+// precedence.
+func (tv TestVector) lintNetworkVersion() []string {
+	pinned, ok := tv.Pre.networkVersion()
+	if !ok {
+		return nil
+	}
+	if nv, ok := tv.Selector.networkVersion(); ok && nv != pinned {
+		return []string{fmt.Sprintf("selector pins network version %d, but the preconditions pin network version %d, which takes precedence", nv, pinned)}
+	}
+	return nil
+}
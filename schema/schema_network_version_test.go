@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNetworkVersion(t *testing.T) {
+	nv := func(v NetworkVersion) *NetworkVersion { return &v }
+
+	var tv TestVector
+	if _, ok := tv.NetworkVersion(); ok {
+		t.Fatal("expected no network version")
+	}
+	tv.Selector = Selector{SelectorNetworkVersion: ">=16"}
+	if _, ok := tv.NetworkVersion(); ok {
+		t.Fatal("expected a comparison not to pin a network version")
+	}
+	tv.Selector = Selector{"network_version": "16"}
+	if v, ok := tv.NetworkVersion(); !ok || v != 16 {
+		t.Fatalf("expected network version 16 from the selector alias, got %d, %t", v, ok)
+	}
+	tv.Selector = Selector{SelectorNetworkVersion: "17", "network_version": "16"}
+	if v, _ := tv.NetworkVersion(); v != 17 {
+		t.Fatalf("expected the key to take precedence over its aliases, got %d", v)
+	}
+
+	// variants sharing a network version take precedence over the selector.
+	tv.Pre = &Preconditions{Variants: []Variant{{ID: "a", NetworkVersion: 16}, {ID: "b", NetworkVersion: 16}}}
+	tv.Selector = nil
+	if v, ok := tv.NetworkVersion(); !ok || v != 16 {
+		t.Fatalf("expected network version 16 from the variants, got %d, %t", v, ok)
+	}
+	tv.Pre.Variants[1].NetworkVersion = 17
+	if _, ok := tv.NetworkVersion(); ok {
+		t.Fatal("expected variants with different network versions not to pin one")
+	}
+
+	// the typed field is authoritative.
+	tv.Pre = &Preconditions{NetworkVersion: nv(18)}
+	tv.Selector = Selector{SelectorNetworkVersion: "17"}
+	if v, ok := tv.NetworkVersion(); !ok || v != 18 {
+		t.Fatalf("expected network version 18 from the preconditions, got %d, %t", v, ok)
+	}
+	if w := tv.Lint(); len(w) != 1 || !strings.Contains(w[0], "selector pins network version 17, but the preconditions pin network version 18") {
+		t.Fatalf("expected a conflicting network version warning, got %v", w)
+	}
+}
+
+func TestValidateNetworkVersion(t *testing.T) {
+	tv := fullTestVector(t)
+	v := NetworkVersion(2)
+	tv.Pre.NetworkVersion = &v
+	if err := tv.validateNetworkVersion(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the field survives both encodings.
+	var buf bytes.Buffer
+	if err := tv.EncodeCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeCBOR(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Pre.NetworkVersion, &v) {
+		t.Fatalf("expected network version 2 after a cbor round trip, got %v", decoded.Pre.NetworkVersion)
+	}
+	if err := AssertRoundTrip(tv); err != nil {
+		t.Fatal(err)
+	}
+
+	v = 3
+	if err := tv.validateNetworkVersion(); err == nil || !strings.Contains(err.Error(), `variant "genesis" at index 0 has network version 2, but the preconditions pin network version 3`) {
+		t.Fatalf("expected a variant mismatch error, got: %v", err)
+	}
+	v = MaxNetworkVersion + 1
+	tv.Pre.Variants = nil
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "is beyond the latest known network version") {
+		t.Fatalf("expected an unknown network version error, got: %v", err)
+	}
+}
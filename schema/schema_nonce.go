@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+)
+
+// MessageHeader holds the fields of a message that validations relating
+// messages to each other, and to their receipts, need: the sender and nonce,
+// which determine the order in which the VM accepts messages, and the gas
+// limit.
+type MessageHeader struct {
+	From     address.Address
+	Nonce    uint64
+	GasLimit int64
+}
+
+// MessageHeaderDecoder decodes the header of the serialized form of a message,
+// usually by decoding it into the message type of the implementation, e.g.
+// with types.DecodeMessage in Lotus.
+type MessageHeaderDecoder func(b []byte) (MessageHeader, error)
+
+// ValidateNonceOrder checks that the messages to apply from each sender have
+// strictly increasing nonces, as the VM rejects them otherwise. Messages
+// expected to be rejected for their nonce, i.e. whose receipt carries
+// ExitSysErrSenderStateInvalid, as well as those expected to fail to be
+// applied outright, are exempt, as they don't consume a nonce.
+//
+// Senders are compared by address as decoded, without resolving them, so the
+// check doesn't relate messages sent by the same actor through its ID and
+// robust addresses.
+func (tv TestVector) ValidateNonceOrder(decode MessageHeaderDecoder) error {
+	exempt := make(map[int]bool, len(tv.ApplyMessages))
+	if tv.Post != nil {
+		for _, i := range tv.Post.ApplyMessageFailures {
+			exempt[i] = true
+		}
+		for i, r := range tv.Post.Receipts {
+			if r != nil && r.ExitCode == ExitSysErrSenderStateInvalid {
+				exempt[i] = true
+			}
+		}
+	}
+
+	type last struct {
+		idx   int
+		nonce uint64
+	}
+	senders := make(map[address.Address]last)
+	for i, m := range tv.ApplyMessages {
+		if exempt[i] {
+			continue
+		}
+		h, err := decode(m.Bytes)
+		if err != nil {
+			return fmt.Errorf("decoding message at index %d: %w", i, err)
+		}
+		if prev, ok := senders[h.From]; ok && h.Nonce <= prev.nonce {
+			return fmt.Errorf("message at index %d, from %s, has nonce %d, which doesn't follow nonce %d of the message at index %d", i, h.From, h.Nonce, prev.nonce, prev.idx)
+		}
+		senders[h.From] = last{idx: i, nonce: h.Nonce}
+	}
+	return nil
+}
+
+// ValidateReceiptOrder checks that the receipts of a message-class vector are
+// plausible for the messages they're paired with, by index: a receipt can't
+// report more gas used than the gas limit of its message, which is the usual
+// symptom of receipts misaligned with their messages. Messages expected to
+// fail to be applied have no receipt, and aren't checked. It's a no-op for
+// vectors of other classes.
+func (tv TestVector) ValidateReceiptOrder(decode MessageHeaderDecoder) error {
+	if tv.Class != ClassMessage || tv.Post == nil {
+		return nil
+	}
+	if len(tv.Post.Receipts) != len(tv.ApplyMessages) {
+		return fmt.Errorf("expected %d receipts, one per message, got %d", len(tv.ApplyMessages), len(tv.Post.Receipts))
+	}
+	for i, r := range tv.Post.Receipts {
+		if r == nil {
+			continue
+		}
+		h, err := decode(tv.ApplyMessages[i].Bytes)
+		if err != nil {
+			return fmt.Errorf("decoding message at index %d: %w", i, err)
+		}
+		if r.GasUsed > h.GasLimit {
+			return fmt.Errorf("receipt at index %d uses %d gas, more than the gas limit %d of its message; the receipts may be misaligned with the messages", i, r.GasUsed, h.GasLimit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateNonceOrder(t *testing.T) {
+	alice, bob := mustIDAddress(t, 100), mustIDAddress(t, 101)
+	mk := func(headers ...MessageHeader) TestVector {
+		var tv TestVector
+		for _, h := range headers {
+			b, err := json.Marshal(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tv.ApplyMessages = append(tv.ApplyMessages, Message{Bytes: b})
+		}
+		return tv
+	}
+	decode := func(b []byte) (MessageHeader, error) {
+		var h MessageHeader
+		err := json.Unmarshal(b, &h)
+		return h, err
+	}
+
+	tv := mk(MessageHeader{From: alice, Nonce: 0}, MessageHeader{From: bob, Nonce: 5}, MessageHeader{From: alice, Nonce: 1}, MessageHeader{From: bob, Nonce: 7})
+	if err := tv.ValidateNonceOrder(decode); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tv = mk(MessageHeader{From: alice, Nonce: 0}, MessageHeader{From: bob, Nonce: 5}, MessageHeader{From: alice, Nonce: 1}, MessageHeader{From: alice, Nonce: 1})
+	err := tv.ValidateNonceOrder(decode)
+	if err == nil || !strings.Contains(err.Error(), "message at index 3, from t0100, has nonce 1, which doesn't follow nonce 1 of the message at index 2") {
+		t.Fatalf("expected a nonce order error, got: %v", err)
+	}
+
+	// messages rejected for their nonce, or failing outright, are exempt.
+	tv.Post = &Postconditions{Receipts: []*Receipt{{}, {}, {}, {ExitCode: ExitSysErrSenderStateInvalid}}}
+	if err := tv.ValidateNonceOrder(decode); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tv.Post = &Postconditions{ApplyMessageFailures: []int{2}, Receipts: []*Receipt{{}, {}, nil, {}}}
+	if err := tv.ValidateNonceOrder(decode); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tv = mk(MessageHeader{From: alice, Nonce: 0})
+	err = tv.ValidateNonceOrder(func([]byte) (MessageHeader, error) { return MessageHeader{}, errors.New("malformed") })
+	if err == nil || !strings.Contains(err.Error(), "decoding message at index 0: malformed") {
+		t.Fatalf("expected a decoding error, got: %v", err)
+	}
+}
+
+func TestValidateReceiptOrder(t *testing.T) {
+	alice := mustIDAddress(t, 100)
+	var tv TestVector
+	tv.Class = ClassMessage
+	for _, limit := range []int64{1000, 10} {
+		b, err := json.Marshal(MessageHeader{From: alice, GasLimit: limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tv.ApplyMessages = append(tv.ApplyMessages, Message{Bytes: b})
+	}
+	decode := func(b []byte) (MessageHeader, error) {
+		var h MessageHeader
+		err := json.Unmarshal(b, &h)
+		return h, err
+	}
+
+	tv.Post = &Postconditions{Receipts: []*Receipt{{GasUsed: 1000}, {GasUsed: 10}}}
+	if err := tv.ValidateReceiptOrder(decode); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// swapped receipts.
+	tv.Post.Receipts = []*Receipt{{GasUsed: 10}, {GasUsed: 1000}}
+	err := tv.ValidateReceiptOrder(decode)
+	if err == nil || !strings.Contains(err.Error(), "receipt at index 1 uses 1000 gas, more than the gas limit 10 of its message") {
+		t.Fatalf("expected a misaligned receipt error, got: %v", err)
+	}
+
+	// messages that fail to be applied have no receipt to check.
+	tv.Post = &Postconditions{ApplyMessageFailures: []int{1}, Receipts: []*Receipt{{GasUsed: 10}, nil}}
+	if err := tv.ValidateReceiptOrder(decode); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tv.Post.Receipts = tv.Post.Receipts[:1]
+	if err := tv.ValidateReceiptOrder(decode); err == nil || !strings.Contains(err.Error(), "expected 2 receipts, one per message, got 1") {
+		t.Fatalf("expected a receipt count error, got: %v", err)
+	}
+
+	tv.Class = ClassTipset
+	if err := tv.ValidateReceiptOrder(decode); err != nil {
+		t.Fatalf("expected other classes to be skipped, got: %s", err)
+	}
+}
@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+)
+
+// NormalizeCIDs converts every CID in the vector to its canonical form, so
+// that re-serializing it is stable regardless of the tool that produced it.
+//
+// The canonical form is CIDv1, which is rendered in JSON as a lowercase
+// base32 multibase string (the "b..." form). CIDv0s, rendered as base58 "Qm..."
+// strings, are converted to CIDv1s with the same (dag-pb) codec and multihash.
+// Codecs are never changed, as they determine how the block is decoded; the
+// state trees and messages of Filecoin are dag-cbor already.
+//
+// Map keys are left to the type of the map: the keys of the message repo are
+// canonicalized to CIDv1 dag-cbor, as MessageRepo does when encoding them, and
+// keys that don't canonicalize are left as is.
+//
+// The converted CIDs address the same blocks; the blockstore returned by
+// LoadCAR looks blocks up by multihash, so it resolves either form.
+func (tv *TestVector) NormalizeCIDs() {
+	normalizeCIDs(reflect.ValueOf(tv).Elem())
+}
+
+// normalizeCIDs normalizes the CIDs within v, which must be settable.
+func normalizeCIDs(v reflect.Value) {
+	switch v.Type() {
+	case cidType:
+		v.Set(reflect.ValueOf(normalizeCID(v.Interface().(cid.Cid))))
+		return
+	case addressType, tokenAmountType, base64BytesType:
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			normalizeCIDs(v.Elem())
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.PkgPath != "" && !f.Anonymous {
+				continue // unexported.
+			}
+			normalizeCIDs(v.Field(i))
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			normalizeCIDs(v.Index(i))
+		}
+
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		if v.Type() == messageRepoType {
+			v.Set(reflect.ValueOf(v.Interface().(MessageRepo).canonicalize()))
+			return
+		}
+		// Map values are not settable, so the map is rebuilt. Keys are left
+		// alone, as their meaning is up to the type of the map.
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			val := reflect.New(v.Type().Elem()).Elem()
+			val.Set(iter.Value())
+			normalizeCIDs(val)
+			m.SetMapIndex(iter.Key(), val)
+		}
+		v.Set(m)
+	}
+}
+
+func normalizeCID(c cid.Cid) cid.Cid {
+	if !c.Defined() || c.Version() != 0 {
+		return c
+	}
+	return cid.NewCidV1(c.Type(), c.Hash())
+}
+
+// CanonicalizeOrder sorts the CIDs in the chain head of the vector by their
+// bytes, so that vectors that expect the same head compare and serialize
+// identically, regardless of the order in which they were generated.
+//
+// That order is purely for stable serialization, and carries no meaning: the
+// chain head is the set of blocks forming the expected head tipset, and
+// Filecoin orders the blocks of a tipset by ticket, which drivers determine
+// from the blocks themselves. The receipts roots are left as is, as their
+// order is meaningful: each corresponds to the applied tipset at its index.
+func (tv *TestVector) CanonicalizeOrder() {
+	if tv.Post == nil {
+		return
+	}
+	sort.Slice(tv.Post.ChainHead, func(i, j int) bool {
+		return bytes.Compare(tv.Post.ChainHead[i].Bytes(), tv.Post.ChainHead[j].Bytes()) < 0
+	})
+}
+
+// Canonicalize rewrites the vector in place to its canonical form, so that
+// vectors that only differ in the encoding of their contents marshal to
+// identical bytes with MarshalJSONDeterministic. It:
+//
+//   - converts every CID to its canonical form, including the keys of the
+//     message repo, which become CIDv1 dag-cbor (see NormalizeCIDs);
+//   - sorts the CIDs of the chain head (see CanonicalizeOrder);
+//   - sorts the hints, and the tags and related vectors of the metadata,
+//     dropping duplicates, as they're sets;
+//   - rewrites the aliases of registered selector keys to the keys
+//     themselves, and re-encodes SelectorOr and SelectorAnd groups compactly,
+//     with sorted keys (see Selector.Normalize).
+//
+// Other encodings are canonical already, so it needn't touch them: token
+// amounts, e.g. base fees, are always marshalled as decimal big.Int strings,
+// however they were unmarshalled (plain JSON numbers in older vectors, or
+// strings with leading zeros or a plus sign), and the keys of the selector
+// and of the message repo are emitted in sorted order.
+//
+// Elements whose order is meaningful, e.g. messages, receipts, receipts roots
+// and randomness rules, are left in their order. It returns an error, leaving
+// the selector unchanged, if the selector doesn't normalize.
+func (tv *TestVector) Canonicalize() error {
+	tv.NormalizeCIDs()
+	tv.CanonicalizeOrder()
+
+	tv.Hints = sortedSet(tv.Hints, func(i, j int) bool { return tv.Hints[i] < tv.Hints[j] }).([]Hint)
+	if m := tv.Meta; m != nil {
+		m.Tags = sortedSet(m.Tags, func(i, j int) bool { return m.Tags[i] < m.Tags[j] }).([]string)
+		m.Related = sortedSet(m.Related, func(i, j int) bool {
+			a, b := m.Related[i], m.Related[j]
+			if a.Relation != b.Relation {
+				return a.Relation < b.Relation
+			}
+			return a.Target < b.Target
+		}).([]RelatedVector)
+	}
+
+	sel, err := tv.Selector.Normalize()
+	if err != nil {
+		return fmt.Errorf("normalizing selector: %w", err)
+	}
+	tv.Selector = sel
+	return nil
+}
+
+// sortedSet sorts the slice with less, and drops its duplicate elements,
+// returning the result, which is nil if the slice is empty.
+func sortedSet(slice interface{}, less func(i, j int) bool) interface{} {
+	v := reflect.ValueOf(slice)
+	if v.Len() == 0 {
+		return reflect.Zero(v.Type()).Interface()
+	}
+	sort.SliceStable(slice, less)
+	n := 1
+	for i := 1; i < v.Len(); i++ {
+		if v.Index(i).Interface() != v.Index(n-1).Interface() {
+			v.Index(n).Set(v.Index(i))
+			n++
+		}
+	}
+	return v.Slice(0, n).Interface()
+}
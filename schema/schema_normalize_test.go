@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestNormalizeCIDs(t *testing.T) {
+	v0, err := cid.Decode("QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := cid.NewCidV1(cid.DagProtobuf, v0.Hash())
+	msgCid := cid.NewCidV1(cid.DagCBOR, v0.Hash())
+
+	tv := fullTestVector(t)
+	v1Vector := tv.Clone()
+	tv.Pre.StateTree.RootCID = v0
+	tv.Post.ReceiptsRoots = []cid.Cid{v0, cid.Undef}
+	tv.ApplyBlockseq.MessageRepo[v0] = []byte("v0")
+	v1Vector.Pre.StateTree.RootCID = v1
+	v1Vector.Post.ReceiptsRoots = []cid.Cid{v1, cid.Undef}
+	v1Vector.ApplyBlockseq.MessageRepo[msgCid] = []byte("v0")
+
+	tv.NormalizeCIDs()
+	if !tv.Pre.StateTree.RootCID.Equals(v1) || tv.Post.ReceiptsRoots[1].Defined() {
+		t.Fatalf("unexpected cids after normalization: %s, %v", tv.Pre.StateTree.RootCID, tv.Post.ReceiptsRoots)
+	}
+	// message repo keys are canonicalized to dag-cbor; see MessageRepo.
+	if _, ok := tv.ApplyBlockseq.MessageRepo[msgCid]; !ok {
+		t.Fatal("expected message repo keys to be canonicalized")
+	}
+
+	a, err := json.Marshal(tv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(v1Vector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Fatalf("expected the normalized vector to serialize like the v1 one:\n%s\n%s", a, b)
+	}
+}
+
+func TestCanonicalizeOrder(t *testing.T) {
+	a, b, c := mkCid(t, "a"), mkCid(t, "b"), mkCid(t, "c")
+	sorted := []cid.Cid{a, b, c}
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Bytes(), sorted[j].Bytes()) < 0 })
+
+	tv1, tv2 := fullTestVector(t), fullTestVector(t)
+	tv1.Post.ChainHead = []cid.Cid{sorted[2], sorted[0], sorted[1]}
+	tv2.Post.ChainHead = []cid.Cid{sorted[1], sorted[2], sorted[0]}
+	tv1.Post.ReceiptsRoots = []cid.Cid{sorted[1], sorted[0]}
+
+	tv1.CanonicalizeOrder()
+	tv2.CanonicalizeOrder()
+	if !reflect.DeepEqual(tv1.Post.ChainHead, sorted) {
+		t.Fatalf("expected chain head %v, got %v", sorted, tv1.Post.ChainHead)
+	}
+	if !reflect.DeepEqual(tv1.Post.ChainHead, tv2.Post.ChainHead) {
+		t.Fatalf("expected chain heads to be in the same order: %v, %v", tv1.Post.ChainHead, tv2.Post.ChainHead)
+	}
+	if !tv1.Post.ReceiptsRoots[0].Equals(sorted[1]) {
+		t.Fatal("expected receipts roots to keep their order")
+	}
+
+	// vectors without postconditions are left alone.
+	(&TestVector{}).CanonicalizeOrder()
+}
+
+func TestCanonicalize(t *testing.T) {
+	v0, err := cid.Decode("QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := mkCid(t, "a"), mkCid(t, "b")
+
+	tv1, tv2 := fullTestVector(t), fullTestVector(t)
+	tv1.Hints = []Hint{HintNegate, HintIncorrect, HintNegate}
+	tv2.Hints = []Hint{HintIncorrect, HintNegate}
+	tv1.Meta.Tags = []string{"b", "a", "b"}
+	tv2.Meta.Tags = []string{"a", "b"}
+	tv1.Meta.Related = []RelatedVector{{Relation: RelationDerivedFrom, Target: "y"}, {Relation: RelationDerivedFrom, Target: "x"}}
+	tv2.Meta.Related = []RelatedVector{{Relation: RelationDerivedFrom, Target: "x"}, {Relation: RelationDerivedFrom, Target: "y"}}
+	tv1.Selector = Selector{"chain": "mainnet"}
+	tv2.Selector = Selector{SelectorNetwork: "mainnet"}
+	tv1.Pre.StateTree.RootCID = v0
+	tv2.Pre.StateTree.RootCID = cid.NewCidV1(cid.DagProtobuf, v0.Hash())
+	tv1.Post.ChainHead = []cid.Cid{b, a}
+	tv2.Post.ChainHead = []cid.Cid{a, b}
+	// a CIDv0 message repo key is rewritten to dag-cbor, and still marshals.
+	tv1.ApplyBlockseq.MessageRepo[v0] = []byte("msg")
+	tv2.ApplyBlockseq.MessageRepo[cid.NewCidV1(cid.DagCBOR, v0.Hash())] = []byte("msg")
+
+	for _, tv := range []*TestVector{tv1, tv2} {
+		if err := tv.Canonicalize(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	j1, err := tv1.MarshalJSONDeterministic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	j2, err := tv2.MarshalJSONDeterministic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(j1, j2) {
+		t.Fatalf("expected canonical vectors to serialize identically:\n%s\n%s", j1, j2)
+	}
+	if !reflect.DeepEqual(tv1.Meta.Tags, []string{"a", "b"}) || len(tv1.Hints) != 2 {
+		t.Fatalf("expected duplicates to be dropped, got tags %v, hints %v", tv1.Meta.Tags, tv1.Hints)
+	}
+
+	tv1.Selector = Selector{"net": "mainnet", "chain": "calibnet"}
+	if err := tv1.Canonicalize(); err == nil {
+		t.Fatal("expected a conflicting selector to fail")
+	}
+
+	// a bare vector is left alone.
+	var empty TestVector
+	if err := empty.Canonicalize(); err != nil || empty.Hints != nil {
+		t.Fatalf("unexpected result for an empty vector: %v, %v", err, empty.Hints)
+	}
+}
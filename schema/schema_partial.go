@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// ActorAssertion is a postcondition on the state of a single actor, which
+// drivers check against the state tree they compute. See
+// Postconditions.PartialState.
+type ActorAssertion struct {
+	// Address is the address of the actor. It may be an ID address, or any
+	// address that the init actor resolves to one.
+	Address address.Address `json:"address"`
+
+	// State, if set, is the expected CID of the state of the actor (its
+	// head).
+	State *cid.Cid `json:"state,omitempty"`
+
+	// Balance, if set, is the expected balance of the actor.
+	Balance *TokenAmount `json:"balance,omitempty"`
+}
+
+// validatePartialState checks that every partial state assertion names an
+// actor, asserts something about it, and that no actor is asserted twice.
+// Whether the actors exist is checked by ValidateCARReachability, as it
+// requires the CAR.
+func (tv TestVector) validatePartialState() error {
+	if tv.Post == nil {
+		return nil
+	}
+	seen := make(map[address.Address]int, len(tv.Post.PartialState))
+	for i, a := range tv.Post.PartialState {
+		if a.Address == address.Undef {
+			return fmt.Errorf("partial state assertion at index %d has no address", i)
+		}
+		if a.State == nil && a.Balance == nil {
+			return fmt.Errorf("partial state assertion at index %d, for actor %s, asserts neither a state nor a balance", i, a.Address)
+		}
+		if a.State != nil && !a.State.Defined() {
+			return fmt.Errorf("partial state assertion at index %d, for actor %s, has an undefined state cid", i, a.Address)
+		}
+		if b := a.Balance.BigInt(); b != nil && b.Sign() < 0 {
+			return fmt.Errorf("partial state assertion at index %d, for actor %s, has negative balance %s attoFIL", i, a.Address, b)
+		}
+		if j, ok := seen[a.Address]; ok {
+			return fmt.Errorf("partial state assertions at indices %d and %d are both for actor %s", j, i, a.Address)
+		}
+		seen[a.Address] = i
+	}
+	return nil
+}
+
+// checkPartialState checks that the actors named by the partial state
+// assertions of the vector are present in its postcondition state tree, held
+// in bs. It's a no-op for vectors without a postcondition state tree root.
+func (tv TestVector) checkPartialState(bs blockstore.Blockstore) error {
+	if tv.Post == nil || len(tv.Post.PartialState) == 0 || tv.Post.StateTree == nil || !tv.Post.StateTree.RootCID.Defined() {
+		return nil
+	}
+	st, err := loadStateTree(bs, tv.Post.StateTree.RootCID)
+	if err != nil {
+		return fmt.Errorf("loading postcondition state tree: %w", err)
+	}
+	for i, a := range tv.Post.PartialState {
+		_, found, err := st.actor(a.Address)
+		if err != nil {
+			return fmt.Errorf("partial state assertion at index %d: looking up actor %s: %w", i, a.Address, err)
+		}
+		if !found {
+			return fmt.Errorf("partial state assertion at index %d: actor %s not found in the postcondition state tree", i, a.Address)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// mkHAMT encodes a single-node HAMT of the given raw values. legacy selects
+// the original encoding of pointers, as maps.
+func mkHAMT(t *testing.T, entries map[string][]byte, legacy bool) string {
+	buckets := make(map[int][]string)
+	for k := range entries {
+		hash := sha256.Sum256([]byte(k))
+		idx := hashBits(hash[:], 0, hamtBitWidth)
+		buckets[idx] = append(buckets[idx], k)
+	}
+	var (
+		bf   = new(big.Int)
+		idxs []int
+	)
+	for idx := range buckets {
+		bf.SetBit(bf, idx, 1)
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+
+	var buf bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 2)
+	_ = writeByteString(&buf, bf.Bytes())
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(idxs)))
+	for _, idx := range idxs {
+		keys := buckets[idx]
+		sort.Strings(keys)
+		if legacy {
+			_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajMap, 1)
+			_ = writeTextString(&buf, "1")
+		}
+		_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(keys)))
+		for _, k := range keys {
+			_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 2)
+			_ = writeByteString(&buf, []byte(k))
+			buf.Write(entries[k])
+		}
+	}
+	return buf.String()
+}
+
+// mkActor encodes an actor with the given head.
+func mkActor(t *testing.T, head cid.Cid) []byte {
+	var buf bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 4)
+	if err := cbg.WriteCid(&buf, mkCid(t, "code")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cbg.WriteCid(&buf, head); err != nil {
+		t.Fatal(err)
+	}
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajUnsignedInt, 0)
+	_ = writeByteString(&buf, nil)
+	return buf.Bytes()
+}
+
+// mkStateTree returns a CAR holding a state tree with an init actor, f01, and
+// an account actor, f0100, whose robust address is returned too. legacy
+// selects the original format, whose root is the HAMT of actors.
+func mkStateTree(t *testing.T, legacy bool) ([]byte, cid.Cid, address.Address) {
+	robust, err := address.NewSecp256k1Address([]byte("pubkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _ := address.NewIDAddress(100)
+
+	addrMap := mkHAMT(t, map[string][]byte{string(robust.Bytes()): cbg.CborEncodeMajorType(cbg.MajUnsignedInt, 100)}, legacy)
+	var initState bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&initState, cbg.MajArray, 3)
+	_ = cbg.WriteCid(&initState, mkCid(t, addrMap))
+	_ = cbg.WriteMajorTypeHeader(&initState, cbg.MajUnsignedInt, 101)
+	_ = writeTextString(&initState, "test")
+
+	actors := mkHAMT(t, map[string][]byte{
+		string(initActorAddr.Bytes()): mkActor(t, mkCid(t, initState.String())),
+		string(account.Bytes()):       mkActor(t, mkCid(t, "account state")),
+	}, legacy)
+	if legacy {
+		data, _ := mkCAR(t, []cid.Cid{mkCid(t, actors)}, addrMap, initState.String(), actors)
+		return data, mkCid(t, actors), robust
+	}
+
+	var root bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&root, cbg.MajArray, 3)
+	_ = cbg.WriteMajorTypeHeader(&root, cbg.MajUnsignedInt, 1)
+	_ = cbg.WriteCid(&root, mkCid(t, actors))
+	_ = cbg.WriteCid(&root, mkCid(t, "info"))
+	data, _ := mkCAR(t, []cid.Cid{mkCid(t, root.String())}, addrMap, initState.String(), actors, root.String())
+	return data, mkCid(t, root.String()), robust
+}
+
+func amount(n int64) *TokenAmount {
+	a := NewTokenAmount(n)
+	return &a
+}
+
+func TestValidatePartialState(t *testing.T) {
+	account, _ := address.NewIDAddress(100)
+	state := mkCid(t, "account state")
+	tv := TestVector{Post: &Postconditions{PartialState: []ActorAssertion{
+		{Address: account, State: &state},
+		{Address: initActorAddr, Balance: amount(0)},
+	}}}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		assertion ActorAssertion
+		err       string
+	}{
+		{ActorAssertion{State: &state}, "assertion at index 2 has no address"},
+		{ActorAssertion{Address: account}, "asserts neither a state nor a balance"},
+		{ActorAssertion{Address: account, State: &cid.Undef}, "undefined state cid"},
+		{ActorAssertion{Address: account, Balance: amount(-1)}, "negative balance -1"},
+		{ActorAssertion{Address: account, Balance: amount(1)}, "assertions at indices 0 and 2 are both for actor t0100"},
+	}
+	for _, c := range cases {
+		tv := TestVector{Post: &Postconditions{PartialState: append(append([]ActorAssertion(nil), tv.Post.PartialState...), c.assertion)}}
+		if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got: %v", c.err, err)
+		}
+	}
+}
+
+func TestValidateCARReachabilityPartialState(t *testing.T) {
+	var (
+		account, _ = address.NewIDAddress(100)
+		unknown, _ = address.NewIDAddress(200)
+		robust2, _ = address.NewSecp256k1Address([]byte("other pubkey"))
+		balance    = amount(10)
+	)
+	for _, legacy := range []bool{false, true} {
+		car, root, robust := mkStateTree(t, legacy)
+		tv := TestVector{
+			CAR: car,
+			Post: &Postconditions{
+				StateTree:    &StateTree{RootCID: root},
+				PartialState: []ActorAssertion{{Address: account, Balance: balance}, {Address: robust, Balance: balance}},
+			},
+		}
+		if err := tv.ValidateCARReachability(); err != nil {
+			t.Fatalf("legacy=%t: unexpected error: %s", legacy, err)
+		}
+
+		for _, addr := range []address.Address{unknown, robust2} {
+			tv.Post.PartialState = []ActorAssertion{{Address: account, Balance: balance}, {Address: addr, Balance: balance}}
+			err := tv.ValidateCARReachability()
+			if err == nil || !strings.Contains(err.Error(), "partial state assertion at index 1: actor "+addr.String()+" not found") {
+				t.Fatalf("legacy=%t: expected a missing actor error, got: %v", legacy, err)
+			}
+		}
+	}
+}
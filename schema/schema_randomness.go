@@ -1,6 +1,9 @@
 package schema
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+)
 
 // RandomnessKind specifies the type of randomness that is being requested.
 type RandomnessKind string
@@ -83,3 +86,23 @@ type RandomnessMatch struct {
 	On     RandomnessRule     `json:"on"`
 	Return Base64EncodedBytes `json:"ret"`
 }
+
+// DefaultRandomness is the randomness drivers must return when no rule
+// matches a request.
+const DefaultRandomness = "i_am_random_____i_am_random_____"
+
+// Lookup returns the randomness to return for the request described by the
+// rule, which is that of the first match whose rule equals it. It returns
+// false if no rule matches, in which case drivers should return
+// DefaultRandomness.
+func (r Randomness) Lookup(req RandomnessRule) (Base64EncodedBytes, bool) {
+	for _, m := range r {
+		if m.On.Kind == req.Kind &&
+			m.On.DomainSeparationTag == req.DomainSeparationTag &&
+			m.On.Epoch == req.Epoch &&
+			bytes.Equal(m.On.Entropy, req.Entropy) {
+			return m.Return, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,33 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRandomnessLookup(t *testing.T) {
+	var r Randomness
+	if err := json.Unmarshal([]byte(`[
+		{"on": ["beacon", 12, 49327, "yxpTbzLhr4uaj7bK0Hl4Vw=="], "ret": "iKyZ2N83N8IoiK2tNJ/H9g=="},
+		{"on": ["chain", 8, 61002, "aacQWICNcMJWtuwTnU+1Hg=="], "ret": "M6HqmihwZ5fXcbQQHhbtsg=="},
+		{"on": ["chain", 8, 61002, "aacQWICNcMJWtuwTnU+1Hg=="], "ret": "AAAA"}
+	]`), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	req := r[1].On
+	req.Entropy = append([]byte(nil), req.Entropy...)
+	ret, ok := r.Lookup(req)
+	if !ok || !bytes.Equal(ret, r[1].Return) {
+		t.Fatalf("expected the first matching rule to win, got: %s, %t", ret, ok)
+	}
+
+	req.Epoch++
+	if ret, ok := r.Lookup(req); ok {
+		t.Fatalf("expected no match, got: %s", ret)
+	}
+	if _, ok := Randomness(nil).Lookup(req); ok {
+		t.Fatal("expected no match")
+	}
+}
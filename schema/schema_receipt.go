@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// ReturnIsEmpty reports whether the receipt carries no return value.
+func (r Receipt) ReturnIsEmpty() bool {
+	return len(r.ReturnValue) == 0
+}
+
+// GasMatches reports whether the actual gas used is within GasUsedTolerance
+// of the expected GasUsed, inclusive.
+func (r Receipt) GasMatches(actual int64) bool {
+	d := actual - r.GasUsed
+	if d < 0 {
+		d = -d
+	}
+	return d <= r.GasUsedTolerance
+}
+
+// GasBreakdownTotal returns the sum of the components of the GasBreakdown,
+// which is zero if there are none.
+func (r Receipt) GasBreakdownTotal() int64 {
+	var total int64
+	for _, g := range r.GasBreakdown {
+		total += g
+	}
+	return total
+}
+
+// ErrorMatches reports whether the error reported by the implementation
+// contains the expected ErrorMessage. It always does if none is expected.
+func (r Receipt) ErrorMatches(actual string) bool {
+	return strings.Contains(actual, r.ErrorMessage)
+}
+
+// UnmarshalReturn decodes the CBOR return value of the receipt into v, which
+// is usually the return type of the invoked actor method. It fails if the
+// return value is not fully consumed.
+func (r Receipt) UnmarshalReturn(v cbg.CBORUnmarshaler) error {
+	br := bytes.NewReader(r.ReturnValue)
+	if err := v.UnmarshalCBOR(br); err != nil {
+		return fmt.Errorf("unmarshalling return value: %w", err)
+	}
+	if br.Len() > 0 {
+		return fmt.Errorf("unmarshalling return value: %d trailing bytes", br.Len())
+	}
+	return nil
+}
+
+// ComputeReceiptsRoot computes the root of the AMT holding the receipts, as
+// the VM commits to them in block headers, so that generators can populate
+// ReceiptsRoots rather than work it out by hand. Only the exit codes, return
+// values and gas used of the receipts are committed to, along with their
+// events roots, if any of them carries one, as only the receipts of network
+// versions supporting events have that field.
+//
+// It commits to all the receipts, so it yields the receipts root of a
+// message-class vector; the receipts of tipset vectors also cover implicit
+// messages, which block headers don't commit to. It returns an error if a
+// receipt is null, as messages that fail to be applied have no receipt to
+// commit to.
+func (p Postconditions) ComputeReceiptsRoot() (cid.Cid, error) {
+	withEvents := false
+	for _, r := range p.Receipts {
+		if r != nil && r.EventsRoot != nil {
+			withEvents = true
+		}
+	}
+	values := make([][]byte, len(p.Receipts))
+	for i, r := range p.Receipts {
+		if r == nil {
+			return cid.Undef, fmt.Errorf("receipt at index %d is null; messages that fail to be applied have no receipt to commit to", i)
+		}
+		var buf bytes.Buffer
+		if err := r.marshalCommitted(&buf, withEvents); err != nil {
+			return cid.Undef, fmt.Errorf("encoding receipt at index %d: %w", i, err)
+		}
+		values[i] = buf.Bytes()
+	}
+	return amtRoot(values)
+}
+
+// marshalCommitted writes the CBOR encoding of the fields of the receipt the
+// VM commits to, with or without the events root.
+func (r Receipt) marshalCommitted(w io.Writer, withEvents bool) error {
+	n := uint64(3)
+	if withEvents {
+		n = 4
+	}
+	if err := cbg.WriteMajorTypeHeader(w, cbg.MajArray, n); err != nil {
+		return err
+	}
+	if err := writeInt64(w, int64(r.ExitCode)); err != nil {
+		return err
+	}
+	if err := writeByteString(w, r.ReturnValue); err != nil {
+		return err
+	}
+	if err := writeInt64(w, r.GasUsed); err != nil {
+		return err
+	}
+	if !withEvents {
+		return nil
+	}
+	if r.EventsRoot == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	return cbg.WriteCid(w, *r.EventsRoot)
+}
@@ -0,0 +1,169 @@
+package schema
+
+import (
+	"bytes"
+	"testing"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+func TestReceiptUnmarshalReturn(t *testing.T) {
+	var buf bytes.Buffer
+	ret := cbg.CborInt(42)
+	if err := ret.MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r := Receipt{ReturnValue: buf.Bytes()}
+	if r.ReturnIsEmpty() {
+		t.Fatal("expected a non-empty return")
+	}
+	var v cbg.CborInt
+	if err := r.UnmarshalReturn(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != 42 {
+		t.Fatalf("expected 42, got %d", v)
+	}
+
+	r.ReturnValue = append(r.ReturnValue, 0x00)
+	if err := r.UnmarshalReturn(&v); err == nil {
+		t.Fatal("expected an error for trailing bytes")
+	}
+
+	r.ReturnValue = nil
+	if !r.ReturnIsEmpty() {
+		t.Fatal("expected an empty return")
+	}
+	if err := r.UnmarshalReturn(&v); err == nil {
+		t.Fatal("expected an error unmarshalling an empty return")
+	}
+}
+
+func TestReceiptGasMatches(t *testing.T) {
+	r := Receipt{GasUsed: 1000}
+	if !r.GasMatches(1000) || r.GasMatches(999) || r.GasMatches(1001) {
+		t.Fatal("expected an exact match without a tolerance")
+	}
+
+	r.GasUsedTolerance = 10
+	for actual, want := range map[int64]bool{990: true, 1000: true, 1010: true, 989: false, 1011: false} {
+		if got := r.GasMatches(actual); got != want {
+			t.Errorf("GasMatches(%d) = %t, want %t", actual, got, want)
+		}
+	}
+}
+
+func TestReceiptErrorMatches(t *testing.T) {
+	r := Receipt{ExitCode: ExitErrForbidden}
+	if !r.ErrorMatches("") || !r.ErrorMatches("anything") {
+		t.Fatal("expected any error to match without an expected message")
+	}
+
+	r.ErrorMessage = "caller is not the owner"
+	if !r.ErrorMatches("actor error: caller is not the owner (RetCode=18)") {
+		t.Fatal("expected the error to match")
+	}
+	if r.ErrorMatches("insufficient funds") {
+		t.Fatal("expected the error not to match")
+	}
+}
+
+func TestReceiptGasBreakdown(t *testing.T) {
+	r := Receipt{GasUsed: 1234, GasBreakdown: map[string]int64{"compute": 1000, "storage": 234}}
+	if total := r.GasBreakdownTotal(); total != 1234 {
+		t.Fatalf("expected a total of 1234, got %d", total)
+	}
+	tv := TestVector{Post: &Postconditions{Receipts: []*Receipt{nil, &r}}}
+	if err := tv.validateReceipts(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := map[string]map[string]int64{
+		"receipt at index 1: gas breakdown adds up to 1000, but gas used is 1234": {"compute": 1000},
+		`receipt at index 1: gas breakdown component "storage" is negative: -1`:   {"compute": 1235, "storage": -1},
+		"receipt at index 1: gas breakdown has a component without a name":        {"": 1234},
+	}
+	for expected, breakdown := range cases {
+		r.GasBreakdown = breakdown
+		if err := tv.validateReceipts(); err == nil || err.Error() != expected {
+			t.Errorf("expected error %q, got: %v", expected, err)
+		}
+	}
+
+	// malformed breakdowns are rejected even in vectors hinted as incorrect.
+	r.GasBreakdown = map[string]int64{"compute": 1000}
+	tv.Hints = []Hint{HintIncorrect}
+	if err := tv.validateReceipts(); err == nil {
+		t.Fatal("expected a malformed breakdown to be rejected in an incorrect vector")
+	}
+	tv.Hints = nil
+
+	// receipts without a breakdown are fine.
+	r.GasBreakdown = nil
+	if err := tv.validateReceipts(); err != nil || r.GasBreakdownTotal() != 0 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestComputeReceiptsRoot(t *testing.T) {
+	receipts := func(n int) []*Receipt {
+		rs := make([]*Receipt, n)
+		for i := range rs {
+			rs[i] = &Receipt{GasUsed: int64(i)}
+		}
+		return rs
+	}
+
+	// roots only depend on the receipts, and change with any of them,
+	// including past the first node of the AMT.
+	seen := make(map[string]int)
+	for _, n := range []int{0, 1, 8, 9, 65} {
+		post := Postconditions{Receipts: receipts(n)}
+		root, err := post.ComputeReceiptsRoot()
+		if err != nil {
+			t.Fatalf("%d receipts: %s", n, err)
+		}
+		again, err := post.ComputeReceiptsRoot()
+		if err != nil || !again.Equals(root) {
+			t.Fatalf("%d receipts: expected a stable root, got %s and %s", n, root, again)
+		}
+		if prev, ok := seen[root.String()]; ok {
+			t.Fatalf("%d receipts: same root as %d receipts", n, prev)
+		}
+		seen[root.String()] = n
+
+		if n > 0 {
+			post.Receipts[n-1].ExitCode = ExitErrForbidden
+			changed, err := post.ComputeReceiptsRoot()
+			if err != nil || changed.Equals(root) {
+				t.Fatalf("%d receipts: expected the root to change with the last receipt, got %s (%v)", n, changed, err)
+			}
+		}
+	}
+
+	post := Postconditions{Receipts: []*Receipt{{}, nil}}
+	if _, err := post.ComputeReceiptsRoot(); err == nil {
+		t.Fatal("expected an error for a null receipt")
+	}
+}
+
+// TestComputeReceiptsRootCorpus is a known-answer test of ComputeReceiptsRoot:
+// the receipts of the messages of a single-block tipset vector must produce
+// the receipts root that Lotus recorded for it. Its receipts also cover the
+// implicit messages that follow, which aren't committed to.
+func TestComputeReceiptsRootCorpus(t *testing.T) {
+	tv, err := LoadTestVectorFile("../corpus/msg_application/gas_cost--msg-ok-secp-bls-gas-costs--genesis.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(tv.ApplyTipsets[0].Blocks[0].Messages)
+	post := Postconditions{Receipts: tv.Post.Receipts[:n]}
+	root, err := post.ComputeReceiptsRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root.Equals(tv.Post.ReceiptsRoots[0]) {
+		t.Fatalf("expected receipts root %s, got %s", tv.Post.ReceiptsRoots[0], root)
+	}
+}
@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentRegistration registers entries in every registry from
+// concurrent goroutines, while others look entries up, for the race detector
+// to catch unguarded accesses.
+func TestConcurrentRegistration(t *testing.T) {
+	const n = 8
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			registerTestDiagnosticsFormat(t, fmt.Sprintf("race-format-%d", i), decodeGasTrace)
+			registerTestSelectorKey(t, fmt.Sprintf("race-key-%d", i), fmt.Sprintf("race-alias-%d", i))
+			registerTestChecker(t, fmt.Sprintf("race-check-%d", i), PostconditionCheckerFunc(func(*TestVector, *ExecutionResult) error {
+				return nil
+			}))
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = Diagnostics{Format: fmt.Sprintf("race-format-%d", i), Data: []byte("[]")}.Decode()
+			_, _ = Selector{fmt.Sprintf("race-alias-%d", i): "1"}.Normalize()
+			tv := &TestVector{Post: &Postconditions{CustomChecks: []string{fmt.Sprintf("race-check-%d", i)}}}
+			_ = tv.Post.Check(tv, &ExecutionResult{})
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if _, err := (Diagnostics{Format: fmt.Sprintf("race-format-%d", i), Data: []byte("[]")}).Decode(); err != nil {
+			t.Errorf("format %d: %s", i, err)
+		}
+		if s, err := (Selector{fmt.Sprintf("race-alias-%d", i): "1"}).Normalize(); err != nil || s[fmt.Sprintf("race-key-%d", i)] != "1" {
+			t.Errorf("selector key %d: %v, %v", i, s, err)
+		}
+		tv := &TestVector{Post: &Postconditions{CustomChecks: []string{fmt.Sprintf("race-check-%d", i)}}}
+		if err := tv.Post.Check(tv, &ExecutionResult{}); err != nil {
+			t.Errorf("check %d: %s", i, err)
+		}
+	}
+}
+
+// registerTestSelectorKey registers a selector key and its aliases for the
+// duration of the test, so that the test can run repeatedly, e.g. with -count.
+func registerTestSelectorKey(t *testing.T, key string, aliases ...string) {
+	RegisterSelectorKey(key, aliases...)
+	t.Cleanup(func() {
+		selectorKeys.Lock()
+		defer selectorKeys.Unlock()
+		for _, k := range append([]string{key}, aliases...) {
+			delete(selectorKeys.aliases, k)
+		}
+	})
+}
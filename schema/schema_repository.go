@@ -0,0 +1,93 @@
+package schema
+
+import "fmt"
+
+// Repository is an in-memory collection of test vectors, e.g. a whole suite
+// loaded by a driver, which can be queried for the subsets of vectors to run.
+// Queries return repositories themselves, so they can be chained, e.g.
+// repo.ByClass(ClassMessage).BySelector(env) selects the message vectors
+// relevant to an environment. Vectors are kept in the order they're added.
+//
+// A Repository is not safe for concurrent use while vectors are added.
+type Repository struct {
+	vectors []*TestVector
+	ids     map[string]*TestVector
+}
+
+// NewRepository returns a repository holding the vectors, or an error if any
+// of them can't be added (see Add).
+func NewRepository(vs ...*TestVector) (*Repository, error) {
+	r := new(Repository)
+	for _, tv := range vs {
+		if err := r.Add(tv); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add adds the vector to the repository. It returns an error if the vector is
+// nil, or if its metadata ID is already held by another vector; vectors
+// without an ID can't be looked up with ByID, but are otherwise accepted.
+func (r *Repository) Add(tv *TestVector) error {
+	if tv == nil {
+		return fmt.Errorf("nil vector")
+	}
+	if tv.Meta != nil && tv.Meta.ID != "" {
+		if _, dup := r.ids[tv.Meta.ID]; dup {
+			return fmt.Errorf("a vector with id %q is already in the repository", tv.Meta.ID)
+		}
+		if r.ids == nil {
+			r.ids = make(map[string]*TestVector)
+		}
+		r.ids[tv.Meta.ID] = tv
+	}
+	r.vectors = append(r.vectors, tv)
+	return nil
+}
+
+// Len returns the number of vectors in the repository.
+func (r *Repository) Len() int {
+	return len(r.vectors)
+}
+
+// Vectors returns the vectors in the repository, in the order they were
+// added.
+func (r *Repository) Vectors() []*TestVector {
+	return append([]*TestVector(nil), r.vectors...)
+}
+
+// ByID returns the vector with the metadata ID, if any.
+func (r *Repository) ByID(id string) (*TestVector, bool) {
+	tv, ok := r.ids[id]
+	return tv, ok
+}
+
+// ByClass returns the vectors of the class.
+func (r *Repository) ByClass(class Class) *Repository {
+	return r.filter(func(tv *TestVector) bool { return tv.Class == class })
+}
+
+// BySelector returns the vectors relevant to the environment, as determined
+// by their selectors (see Selector.Matches). Vectors without a selector are
+// relevant to every environment.
+func (r *Repository) BySelector(env map[string]string) *Repository {
+	return r.filter(func(tv *TestVector) bool { return tv.Selector.Matches(env) })
+}
+
+// ByTag returns the vectors whose metadata carries the tag.
+func (r *Repository) ByTag(tag string) *Repository {
+	return r.filter(func(tv *TestVector) bool { return tv.Meta != nil && tv.Meta.HasTag(tag) })
+}
+
+// filter returns a repository holding the vectors for which keep is true.
+func (r *Repository) filter(keep func(tv *TestVector) bool) *Repository {
+	ret := new(Repository)
+	for _, tv := range r.vectors {
+		if keep(tv) {
+			// vectors have distinct IDs already.
+			_ = ret.Add(tv)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRepository(t *testing.T) {
+	mk := func(id string, class Class, sel Selector, tags ...string) *TestVector {
+		return &TestVector{Class: class, Selector: sel, Meta: &Metadata{ID: id, Tags: tags}}
+	}
+	chaos := mk("chaos", ClassMessage, Selector{SelectorChaosActor: "true"}, "actors")
+	plain := mk("plain", ClassMessage, nil, "actors", "gas")
+	tipset := mk("tipset", ClassTipset, nil, "gas")
+	anonymous := &TestVector{Class: ClassMessage}
+
+	repo, err := NewRepository(chaos, plain, tipset, anonymous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := func(r *Repository) string {
+		var ret []string
+		for _, tv := range r.Vectors() {
+			if tv.Meta == nil {
+				ret = append(ret, "-")
+				continue
+			}
+			ret = append(ret, tv.Meta.ID)
+		}
+		return strings.Join(ret, ",")
+	}
+
+	if repo.Len() != 4 {
+		t.Fatalf("expected 4 vectors, got %d", repo.Len())
+	}
+	if got := ids(repo.ByClass(ClassMessage)); got != "chaos,plain,-" {
+		t.Errorf("unexpected message vectors: %s", got)
+	}
+	if got := ids(repo.ByTag("gas")); got != "plain,tipset" {
+		t.Errorf("unexpected gas vectors: %s", got)
+	}
+	env := map[string]string{SelectorChaosActor: "false"}
+	if got := ids(repo.ByClass(ClassMessage).BySelector(env)); got != "plain,-" {
+		t.Errorf("unexpected vectors for the environment: %s", got)
+	}
+	if got := ids(repo.ByTag("actors").ByClass(ClassTipset)); got != "" {
+		t.Errorf("expected no vectors, got: %s", got)
+	}
+
+	if tv, ok := repo.ByID("tipset"); !ok || tv != tipset {
+		t.Errorf("expected to find the tipset vector by id, got %v", tv)
+	}
+	if _, ok := repo.ByClass(ClassTipset).ByID("plain"); ok {
+		t.Error("expected filtered out vectors not to be found by id")
+	}
+
+	if err := repo.Add(mk("plain", ClassTipset, nil)); err == nil || !strings.Contains(err.Error(), `a vector with id "plain" is already in the repository`) {
+		t.Fatalf("expected a duplicate id error, got: %v", err)
+	}
+	if err := repo.Add(nil); err == nil {
+		t.Fatal("expected adding a nil vector to fail")
+	}
+	if repo.Len() != 4 {
+		t.Fatalf("expected rejected vectors not to be added, got %d vectors", repo.Len())
+	}
+}
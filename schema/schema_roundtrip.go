@@ -0,0 +1,44 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// AssertRoundTrip encodes the test vector to JSON, decodes it back, and checks
+// that the result equals the original, returning an error describing the
+// first divergence if it doesn't. It's meant for drivers and tools that build
+// or modify vectors, to verify that nothing they set is lost in their JSON
+// form.
+//
+// The comparison follows the rules of TestVector.Equal, except that metadata
+// (_meta) is compared too. The decoded vector is not validated.
+func AssertRoundTrip(tv *TestVector) error {
+	if tv == nil {
+		return fmt.Errorf("cannot round-trip a nil test vector")
+	}
+	b, err := tv.MarshalJSONDeterministic()
+	if err != nil {
+		return fmt.Errorf("encoding test vector: %w", err)
+	}
+	var decoded TestVector
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return fmt.Errorf("decoding test vector: %w", err)
+	}
+
+	if !tv.Equal(&decoded) {
+		diffs, err := Diff(tv, &decoded)
+		if err != nil {
+			return err
+		}
+		if len(diffs) > 0 {
+			return fmt.Errorf("test vector changed in round trip: %s", diffs[0])
+		}
+		return fmt.Errorf("test vector changed in round trip")
+	}
+	if !equalValues(reflect.ValueOf(tv.Meta), reflect.ValueOf(decoded.Meta)) {
+		return fmt.Errorf("test vector changed in round trip: _meta: %s -> %s", renderValue(reflect.ValueOf(tv.Meta)), renderValue(reflect.ValueOf(decoded.Meta)))
+	}
+	return nil
+}
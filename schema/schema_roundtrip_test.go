@@ -0,0 +1,25 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssertRoundTrip(t *testing.T) {
+	tv := fullTestVector(t)
+	if err := AssertRoundTrip(tv); err != nil {
+		t.Fatal(err)
+	}
+
+	// sub-millisecond offsets are lost in the JSON form.
+	tv.ApplyBlockseq.Blocks[0].OffsetMs += OffsetMillis(time.Microsecond)
+	err := AssertRoundTrip(tv)
+	if err == nil || !strings.Contains(err.Error(), "apply_blockseq.blocks[0].offset_ms") {
+		t.Fatalf("expected a divergence in the block offset, got: %v", err)
+	}
+
+	if err := AssertRoundTrip(nil); err == nil {
+		t.Fatal("expected an error for a nil vector")
+	}
+}
@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// SampleSuite returns a random sample of n vectors of the suite, for running
+// a representative subset of a large suite, e.g. on every change rather than
+// nightly. The sample is deterministic for a given seed and suite, so that
+// runs are reproducible.
+//
+// Vectors are stratified by class and set of tags, and each stratum
+// contributes to the sample in proportion to its size, so the sample
+// preserves the class and tag distribution of the suite; the vectors of each
+// stratum are picked by reservoir sampling. The sample retains the order of
+// the suite. It's the whole suite when n is at least its size, and empty when
+// n isn't positive.
+func SampleSuite(vs []*TestVector, n int, seed int64) []*TestVector {
+	if n <= 0 {
+		return nil
+	}
+	if n >= len(vs) {
+		return append([]*TestVector(nil), vs...)
+	}
+
+	strata := make(map[string][]int)
+	for i, tv := range vs {
+		k := sampleStratum(tv)
+		strata[k] = append(strata[k], i)
+	}
+	keys := make([]string, 0, len(strata))
+	for k := range strata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// apportion the sample to the strata by largest remainder, breaking ties
+	// in key order.
+	quotas := make(map[string]int, len(keys))
+	left := n
+	for _, k := range keys {
+		quotas[k] = n * len(strata[k]) / len(vs)
+		left -= quotas[k]
+	}
+	byRemainder := append([]string(nil), keys...)
+	sort.SliceStable(byRemainder, func(i, j int) bool {
+		return n*len(strata[byRemainder[i]])%len(vs) > n*len(strata[byRemainder[j]])%len(vs)
+	})
+	for _, k := range byRemainder[:left] {
+		quotas[k]++
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	var picked []int
+	for _, k := range keys {
+		picked = append(picked, reservoirSample(rnd, strata[k], quotas[k])...)
+	}
+	sort.Ints(picked)
+
+	ret := make([]*TestVector, len(picked))
+	for i, idx := range picked {
+		ret[i] = vs[idx]
+	}
+	return ret
+}
+
+// sampleStratum returns the key of the stratum of the vector in SampleSuite:
+// its class and sorted tags.
+func sampleStratum(tv *TestVector) string {
+	var tags []string
+	if tv.Meta != nil {
+		tags = append(tags, tv.Meta.Tags...)
+		sort.Strings(tags)
+	}
+	return string(tv.Class) + "\x00" + strings.Join(tags, "\x00")
+}
+
+// reservoirSample picks k of the items uniformly at random.
+func reservoirSample(rnd *rand.Rand, items []int, k int) []int {
+	if k <= 0 {
+		return nil
+	}
+	res := append([]int(nil), items[:k]...)
+	for i := k; i < len(items); i++ {
+		if j := rnd.Intn(i + 1); j < k {
+			res[j] = items[i]
+		}
+	}
+	return res
+}
@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSampleSuite(t *testing.T) {
+	var vs []*TestVector
+	add := func(count int, class Class, tags ...string) {
+		for i := 0; i < count; i++ {
+			vs = append(vs, &TestVector{Class: class, Meta: &Metadata{ID: fmt.Sprintf("%s-%v-%d", class, tags, i), Tags: tags}})
+		}
+	}
+	add(60, ClassMessage)
+	add(20, ClassMessage, "fvm")
+	add(15, ClassTipset)
+	add(5, ClassTipset, "fvm", "miner")
+
+	ids := func(vs []*TestVector) []string {
+		var ret []string
+		for _, tv := range vs {
+			ret = append(ret, tv.Meta.ID)
+		}
+		return ret
+	}
+	sample := SampleSuite(vs, 20, 42)
+	if len(sample) != 20 {
+		t.Fatalf("expected 20 vectors, got %d", len(sample))
+	}
+	if again := SampleSuite(vs, 20, 42); !reflect.DeepEqual(ids(sample), ids(again)) {
+		t.Fatalf("expected the same sample for the same seed, got %v and %v", ids(sample), ids(again))
+	}
+	if other := SampleSuite(vs, 20, 43); reflect.DeepEqual(ids(sample), ids(other)) {
+		t.Fatalf("expected a different sample for a different seed, got %v", ids(other))
+	}
+
+	// strata are represented in proportion, and the order of the suite is kept.
+	counts := make(map[string]int)
+	pos := make(map[*TestVector]int)
+	for i, tv := range vs {
+		pos[tv] = i
+	}
+	for i, tv := range sample {
+		counts[sampleStratum(tv)]++
+		if i > 0 && pos[sample[i-1]] >= pos[tv] {
+			t.Fatalf("expected the sample in suite order, got %v", ids(sample))
+		}
+	}
+	expected := map[string]int{
+		sampleStratum(vs[0]):  12,
+		sampleStratum(vs[60]): 4,
+		sampleStratum(vs[80]): 3,
+		sampleStratum(vs[95]): 1,
+	}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("expected stratum counts %v, got %v", expected, counts)
+	}
+
+	// the largest remainders get the leftover picks.
+	counts = make(map[string]int)
+	for _, tv := range SampleSuite(vs, 3, 1) {
+		counts[sampleStratum(tv)]++
+	}
+	if expected := map[string]int{sampleStratum(vs[0]): 2, sampleStratum(vs[60]): 1}; !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("expected stratum counts %v, got %v", expected, counts)
+	}
+
+	if s := SampleSuite(vs, len(vs)+1, 1); len(s) != len(vs) {
+		t.Fatalf("expected the whole suite, got %d vectors", len(s))
+	}
+	if s := SampleSuite(vs, 0, 1); len(s) != 0 {
+		t.Fatalf("expected an empty sample, got %d vectors", len(s))
+	}
+}
@@ -0,0 +1,263 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SelectorNegationPrefix, when prefixed to a selector value, negates the
+// constraint: the environment must not hold the value that follows.
+const SelectorNegationPrefix = "!="
+
+const (
+	// SelectorOr is a selector key grouping alternative selectors; it is
+	// satisfied if any of them is. Its value is a JSON array of selectors,
+	// e.g. `[{"network":"mainnet"},{"network":"calibnet"}]`.
+	SelectorOr = "$or"
+
+	// SelectorAnd is a selector key grouping selectors that must all be
+	// satisfied. Its value is a JSON array of selectors. Top-level keys are
+	// already ANDed together, so this is only useful inside a SelectorOr.
+	SelectorAnd = "$and"
+)
+
+// selectorComparisons are the numeric comparison operators that may prefix a
+// selector value, longest first so that ">=" isn't parsed as ">".
+var selectorComparisons = []struct {
+	op  string
+	cmp func(actual, expected float64) bool
+}{
+	{">=", func(a, e float64) bool { return a >= e }},
+	{"<=", func(a, e float64) bool { return a <= e }},
+	{">", func(a, e float64) bool { return a > e }},
+	{"<", func(a, e float64) bool { return a < e }},
+}
+
+// Matches evaluates the selector against an environment describing the
+// capabilities of the implementation under test, keyed like the selector
+// (e.g. SelectorChaosActor: "true").
+//
+// The vector is relevant if every key of the selector is either absent from
+// the environment, or satisfies the constraint. A constraint is satisfied if
+// the environment holds exactly the selector value, or, for values prefixed
+// with SelectorNegationPrefix (e.g. "!=genesis"), if it holds any other value.
+//
+// Matches also supports the expressions accepted by Eval; selectors that fail
+// to evaluate never match.
+func (s Selector) Matches(env map[string]string) bool {
+	ok, err := s.Eval(env)
+	return ok && err == nil
+}
+
+// Eval evaluates the selector against the environment like Matches does, and
+// additionally supports:
+//
+//   - numeric comparisons, by prefixing the value with >=, <=, > or <
+//     (e.g. "nv": ">=16"); both the selector and the environment values must
+//     then be numbers.
+//   - alternatives and groupings, through the SelectorOr and SelectorAnd
+//     keys, whose values are JSON arrays of nested selectors.
+//
+// It returns an error if the selector is malformed, or the environment holds a
+// value that cannot be compared.
+func (s Selector) Eval(env map[string]string) (bool, error) {
+	ret := true
+	for k, constraint := range s {
+		var (
+			ok  bool
+			err error
+		)
+		switch k {
+		case SelectorOr, SelectorAnd:
+			ok, err = evalSelectorGroup(k, constraint, env)
+		default:
+			if strings.HasPrefix(k, "$") {
+				return false, fmt.Errorf("unknown selector operator %q", k)
+			}
+			ok, err = evalSelectorConstraint(k, constraint, env)
+		}
+		if err != nil {
+			return false, err
+		}
+		// keep going, so that malformed expressions are always reported.
+		ret = ret && ok
+	}
+	return ret, nil
+}
+
+// evalSelectorGroup evaluates the JSON array of selectors held by a SelectorOr
+// or SelectorAnd key.
+func evalSelectorGroup(op, group string, env map[string]string) (bool, error) {
+	var sels []Selector
+	if err := json.Unmarshal([]byte(group), &sels); err != nil {
+		return false, fmt.Errorf("selector %s must be a json array of selectors: %w", op, err)
+	}
+	if len(sels) == 0 {
+		return false, fmt.Errorf("selector %s must not be empty", op)
+	}
+	ret := op == SelectorAnd
+	for i, sel := range sels {
+		ok, err := sel.Eval(env)
+		if err != nil {
+			return false, fmt.Errorf("selector %s at index %d: %w", op, i, err)
+		}
+		if op == SelectorOr {
+			ret = ret || ok
+		} else {
+			ret = ret && ok
+		}
+	}
+	return ret, nil
+}
+
+// evalSelectorConstraint evaluates the constraint on a single key of the
+// environment.
+func evalSelectorConstraint(key, constraint string, env map[string]string) (bool, error) {
+	actual, ok := env[key]
+	if !ok {
+		return true, nil
+	}
+	if neg := strings.TrimPrefix(constraint, SelectorNegationPrefix); neg != constraint {
+		return actual != neg, nil
+	}
+	for _, c := range selectorComparisons {
+		operand := strings.TrimPrefix(constraint, c.op)
+		if operand == constraint {
+			continue
+		}
+		expected, err := strconv.ParseFloat(strings.TrimSpace(operand), 64)
+		if err != nil {
+			return false, fmt.Errorf("selector %s: %q is not a number", key, operand)
+		}
+		a, err := strconv.ParseFloat(actual, 64)
+		if err != nil {
+			return false, fmt.Errorf("environment value %s=%q cannot be compared with %s", key, actual, constraint)
+		}
+		return c.cmp(a, expected), nil
+	}
+	return actual == constraint, nil
+}
+
+var selectorKeys = struct {
+	sync.RWMutex
+	aliases map[string]string // key or alias => key.
+}{aliases: make(map[string]string)}
+
+func init() {
+	RegisterSelectorKey(SelectorChaosActor)
+	RegisterSelectorKey(SelectorMinProtocolVersion)
+	RegisterSelectorKey(SelectorNetworkVersion, "network_version")
+	RegisterSelectorKey(SelectorNetwork, "net", "chain")
+}
+
+// RegisterSelectorKey registers a well-known selector key, along with aliases
+// that Selector.Normalize rewrites to it. Lint warns about selectors using
+// keys that are neither registered, nor carry the HintVendorPrefix. It panics
+// if the key or an alias is already registered, or is an operator. Keys are
+// expected to be registered at init time, but it's safe to do so while other
+// goroutines normalize or lint selectors.
+func RegisterSelectorKey(key string, aliases ...string) {
+	selectorKeys.Lock()
+	defer selectorKeys.Unlock()
+
+	keys := append([]string{key}, aliases...)
+	for _, k := range keys {
+		if k == "" || strings.HasPrefix(k, "$") {
+			panic("schema: invalid selector key " + strconv.Quote(k))
+		}
+		if _, dup := selectorKeys.aliases[k]; dup {
+			panic("schema: selector key " + k + " registered twice")
+		}
+	}
+	for _, k := range keys {
+		selectorKeys.aliases[k] = key
+	}
+}
+
+// canonicalSelectorKey returns the registered key that k is, or is an alias
+// of, if any.
+func canonicalSelectorKey(k string) (string, bool) {
+	selectorKeys.RLock()
+	defer selectorKeys.RUnlock()
+	key, ok := selectorKeys.aliases[k]
+	return key, ok
+}
+
+// Normalize returns a copy of the selector in which the aliases of registered
+// keys (see RegisterSelectorKey) are replaced by the keys themselves, e.g.
+// "chain" by SelectorNetwork, including within SelectorOr and SelectorAnd
+// groups. Other keys are kept as is. It returns an error if a key is given
+// conflicting values through its aliases, or a group is malformed.
+func (s Selector) Normalize() (Selector, error) {
+	if s == nil {
+		return nil, nil
+	}
+	ret := make(Selector, len(s))
+	from := make(map[string]string, len(s)) // key => the original key it was set through.
+	for k, v := range s {
+		key := k
+		switch k {
+		case SelectorOr, SelectorAnd:
+			var sels []Selector
+			if err := json.Unmarshal([]byte(v), &sels); err != nil {
+				return nil, fmt.Errorf("selector %s must be a json array of selectors: %w", k, err)
+			}
+			for i := range sels {
+				var err error
+				if sels[i], err = sels[i].Normalize(); err != nil {
+					return nil, fmt.Errorf("selector %s at index %d: %w", k, i, err)
+				}
+			}
+			// comparison operators must not be escaped, to remain readable.
+			var buf strings.Builder
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(sels); err != nil {
+				return nil, err
+			}
+			v = strings.TrimSuffix(buf.String(), "\n")
+		default:
+			if canonical, ok := canonicalSelectorKey(k); ok {
+				key = canonical
+			}
+		}
+		if prev, ok := ret[key]; ok && prev != v {
+			return nil, fmt.Errorf("selector key %s is set to %q through %s, and to %q through %s", key, prev, from[key], v, k)
+		}
+		ret[key], from[key] = v, k
+	}
+	return ret, nil
+}
+
+// lintKeys returns warnings about the keys of the selector, and of its nested
+// selectors, that are aliases of registered keys, or are not registered at
+// all. Malformed groups are left to Eval to report.
+func (s Selector) lintKeys() []string {
+	var warnings []string
+	for k, v := range s {
+		switch k {
+		case SelectorOr, SelectorAnd:
+			var sels []Selector
+			if err := json.Unmarshal([]byte(v), &sels); err != nil {
+				continue
+			}
+			for _, sel := range sels {
+				warnings = append(warnings, sel.lintKeys()...)
+			}
+			continue
+		}
+		canonical, ok := canonicalSelectorKey(k)
+		switch {
+		case ok && canonical != k:
+			warnings = append(warnings, fmt.Sprintf("selector key %q is an alias of %q, which drivers may expect instead; see Selector.Normalize", k, canonical))
+		case !ok && !strings.HasPrefix(k, HintVendorPrefix) && !strings.HasPrefix(k, "$"):
+			warnings = append(warnings, fmt.Sprintf("selector key %q is not a well-known key, so drivers may ignore it; register it, or prefix it with %q if it's vendor specific", k, HintVendorPrefix))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
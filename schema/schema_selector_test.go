@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	env := map[string]string{
+		SelectorChaosActor:         "false",
+		SelectorMinProtocolVersion: "actorsv2",
+	}
+	cases := []struct {
+		sel     Selector
+		matches bool
+	}{
+		{nil, true},
+		{Selector{SelectorMinProtocolVersion: "actorsv2"}, true},
+		{Selector{SelectorMinProtocolVersion: "genesis"}, false},
+		{Selector{SelectorChaosActor: "true"}, false},
+		{Selector{SelectorChaosActor: "!=true"}, true},
+		{Selector{SelectorChaosActor: "!=false"}, false},
+		{Selector{"unknown_feature": "true"}, true},
+		{Selector{SelectorMinProtocolVersion: "actorsv2", SelectorChaosActor: "true"}, false},
+	}
+	for _, c := range cases {
+		if m := c.sel.Matches(env); m != c.matches {
+			t.Errorf("expected selector %v to match: %t; got %t", c.sel, c.matches, m)
+		}
+	}
+}
+
+func TestSelectorEval(t *testing.T) {
+	env := map[string]string{
+		"nv":                       "16",
+		"network":                  "calibnet",
+		SelectorMinProtocolVersion: "actorsv2",
+	}
+	cases := []struct {
+		sel    Selector
+		result bool
+		err    bool
+	}{
+		{sel: Selector{SelectorMinProtocolVersion: "actorsv2"}, result: true},
+		{sel: Selector{"nv": ">=16"}, result: true},
+		{sel: Selector{"nv": ">16"}, result: false},
+		{sel: Selector{"nv": "<17", "network": "calibnet"}, result: true},
+		{sel: Selector{"nv": "<=15.5"}, result: false},
+		{sel: Selector{"unknown": ">=1"}, result: true},
+		{sel: Selector{SelectorOr: `[{"network":"mainnet"},{"network":"calibnet"}]`}, result: true},
+		{sel: Selector{SelectorOr: `[{"network":"mainnet"},{"nv":"<10"}]`}, result: false},
+		{sel: Selector{SelectorOr: `[{"network":"mainnet"},{"$and":"[{\"nv\":\">=16\"},{\"network\":\"!=mainnet\"}]"}]`}, result: true},
+		{sel: Selector{SelectorAnd: `[{"nv":">=16"},{"network":"mainnet"}]`}, result: false},
+
+		{sel: Selector{"nv": ">=sixteen"}, err: true},
+		{sel: Selector{"network": ">=1"}, err: true},
+		{sel: Selector{SelectorOr: `{"network":"mainnet"}`}, err: true},
+		{sel: Selector{SelectorOr: `[]`}, err: true},
+		{sel: Selector{"$not": `[]`}, err: true},
+		{sel: Selector{SelectorOr: `[{"network":"calibnet"},{"nv":">x"}]`}, err: true},
+	}
+	for _, c := range cases {
+		result, err := c.sel.Eval(env)
+		switch {
+		case c.err && err == nil:
+			t.Errorf("expected selector %v to fail evaluation", c.sel)
+		case !c.err && err != nil:
+			t.Errorf("unexpected error evaluating selector %v: %s", c.sel, err)
+		case result != c.result:
+			t.Errorf("expected selector %v to evaluate to %t; got %t", c.sel, c.result, result)
+		}
+		if c.sel.Matches(env) != (c.result && !c.err) {
+			t.Errorf("expected Matches to agree with Eval for selector %v", c.sel)
+		}
+	}
+}
+
+func TestSelectorNormalize(t *testing.T) {
+	sel := Selector{
+		"chain":                "calibnet",
+		"network_version":      ">=16",
+		SelectorChaosActor:     "true",
+		"x-lotus-fast":         "true",
+		SelectorOr:             `[{"net":"mainnet"},{"nv":"<10"}]`,
+		SelectorNetworkVersion: ">=16",
+	}
+	normalized, err := sel.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Selector{
+		SelectorNetwork:        "calibnet",
+		SelectorNetworkVersion: ">=16",
+		SelectorChaosActor:     "true",
+		"x-lotus-fast":         "true",
+		SelectorOr:             `[{"network":"mainnet"},{"nv":"<10"}]`,
+	}
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Fatalf("expected %v, got %v", expected, normalized)
+	}
+	if sel["chain"] != "calibnet" {
+		t.Fatal("expected the original selector to be left as is")
+	}
+
+	if _, err := (Selector{"net": "mainnet", "chain": "calibnet"}).Normalize(); err == nil || !strings.Contains(err.Error(), "selector key network is set to") {
+		t.Fatalf("expected a conflicting aliases error, got: %v", err)
+	}
+	if _, err := (Selector{SelectorAnd: `[{"net":"mainnet","network":"calibnet"}]`}).Normalize(); err == nil || !strings.Contains(err.Error(), "selector $and at index 0") {
+		t.Fatalf("expected a nested conflicting aliases error, got: %v", err)
+	}
+	if _, err := (Selector{SelectorOr: `{}`}).Normalize(); err == nil {
+		t.Fatal("expected a malformed group error")
+	}
+}
+
+func TestRegisterSelectorKeyTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering an alias twice to panic")
+		}
+		if _, ok := canonicalSelectorKey("x-never-registered"); ok {
+			t.Fatal("expected a failed registration to register nothing")
+		}
+	}()
+	RegisterSelectorKey("x-never-registered", "chain")
+}
@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// SizeStats is a breakdown of the size of a test vector, in bytes, to help
+// spot where its bulk comes from. See TestVector.SizeStats.
+type SizeStats struct {
+	// CAR is the size of the embedded CARs, including named ones, as stored
+	// (i.e. compressed, when they're gzipped).
+	CAR int
+
+	// Messages is the total size of the serialized messages to apply, and of
+	// their signatures, including those within tipsets, and, for blockseq
+	// vectors, the blocks and the message repo.
+	Messages int
+
+	// Diagnostics is the size of the diagnostics data.
+	Diagnostics int
+
+	// Other is the total size of the remaining binary fields: receipt return
+	// values and events, and randomness entropies and values.
+	Other int
+
+	// JSONOverhead is how much the base64 encoding of all the above inflates
+	// them in the JSON form of the vector. The JSON structure itself, and
+	// the non-binary fields, are not accounted for.
+	JSONOverhead int
+}
+
+// Total returns the size of all binary data in the vector as it appears in
+// its JSON form, i.e. including the JSONOverhead.
+func (s SizeStats) Total() int {
+	return s.CAR + s.Messages + s.Diagnostics + s.Other + s.JSONOverhead
+}
+
+// SizeStats reports the size of the binary data in this vector, broken down
+// by purpose. It sums the lengths of the decoded fields, so it's cheap: the
+// vector is not re-encoded.
+func (tv TestVector) SizeStats() SizeStats {
+	var s SizeStats
+	add := func(dst *int, b []byte) {
+		*dst += len(b)
+		s.JSONOverhead += base64.StdEncoding.EncodedLen(len(b)) - len(b)
+	}
+
+	add(&s.CAR, tv.CAR)
+	for _, c := range tv.CARs {
+		add(&s.CAR, c)
+	}
+	for _, m := range tv.ApplyMessages {
+		add(&s.Messages, m.Bytes)
+		if m.Signature != nil {
+			add(&s.Messages, m.Signature.Data)
+		}
+	}
+	for _, ts := range tv.ApplyTipsets {
+		for _, b := range ts.Blocks {
+			for _, m := range b.Messages {
+				add(&s.Messages, m)
+			}
+		}
+	}
+	if tv.ApplyBlockseq != nil {
+		for _, b := range tv.ApplyBlockseq.Blocks {
+			add(&s.Messages, b.Bytes)
+		}
+		for _, m := range tv.ApplyBlockseq.MessageRepo {
+			add(&s.Messages, m)
+		}
+	}
+	if tv.Diagnostics != nil {
+		add(&s.Diagnostics, tv.Diagnostics.Data)
+	}
+	for _, r := range tv.Randomness {
+		add(&s.Other, r.On.Entropy)
+		add(&s.Other, r.Return)
+	}
+	if tv.Post != nil {
+		for _, r := range tv.Post.Receipts {
+			if r == nil {
+				continue
+			}
+			add(&s.Other, r.ReturnValue)
+			for _, e := range r.Events {
+				add(&s.Other, e)
+			}
+		}
+	}
+	return s
+}
+
+// MessageCount returns the number of messages the vector applies: its messages
+// to apply, the messages of the blocks of its tipsets, and the messages in the
+// repo of its block sequence.
+func (tv TestVector) MessageCount() int {
+	n := len(tv.ApplyMessages)
+	for _, ts := range tv.ApplyTipsets {
+		for _, b := range ts.Blocks {
+			n += len(b.Messages)
+		}
+	}
+	if tv.ApplyBlockseq != nil {
+		n += len(tv.ApplyBlockseq.MessageRepo)
+	}
+	return n
+}
+
+// validateBudget checks that the vector fits in the budgets set in opts.
+func (tv TestVector) validateBudget(opts ValidateOptions) error {
+	if opts.MaxCARBytes > 0 {
+		n := len(tv.CAR)
+		for _, c := range tv.CARs {
+			n += len(c)
+		}
+		if n > opts.MaxCARBytes {
+			return fmt.Errorf("car is %d bytes, over the budget of %d bytes", n, opts.MaxCARBytes)
+		}
+	}
+	if n := tv.MessageCount(); opts.MaxMessages > 0 && n > opts.MaxMessages {
+		return fmt.Errorf("vector applies %d messages, over the budget of %d messages", n, opts.MaxMessages)
+	}
+	if n := len(tv.ApplyTipsets); opts.MaxTipsets > 0 && n > opts.MaxTipsets {
+		return fmt.Errorf("vector applies %d tipsets, over the budget of %d tipsets", n, opts.MaxTipsets)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSizeStats(t *testing.T) {
+	got := fullTestVector(t).SizeStats()
+	expected := SizeStats{CAR: 9, Messages: 18, Diagnostics: 4, Other: 16, JSONOverhead: 25}
+	if got != expected {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	if got.Total() != 72 {
+		t.Fatalf("expected a total of 72 bytes, got %d", got.Total())
+	}
+
+	if got := (TestVector{}).SizeStats(); got != (SizeStats{}) {
+		t.Fatalf("expected zero stats for an empty vector, got %+v", got)
+	}
+}
+
+func TestValidateBudget(t *testing.T) {
+	tv := fullTestVector(t)
+	if n := tv.MessageCount(); n != 4 {
+		t.Fatalf("expected 4 messages, got %d", n)
+	}
+
+	opts := ValidateOptions{MaxCARBytes: 9, MaxMessages: 4, MaxTipsets: 1}
+	if err := tv.validateBudget(opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cases := []struct {
+		mutate func(*ValidateOptions)
+		err    string
+	}{
+		{func(o *ValidateOptions) { o.MaxCARBytes = 8 }, "car is 9 bytes, over the budget of 8 bytes"},
+		{func(o *ValidateOptions) { o.MaxMessages = 2 }, "vector applies 4 messages, over the budget of 2 messages"},
+	}
+	for _, c := range cases {
+		o := opts
+		c.mutate(&o)
+		if err := tv.validateBudget(o); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got: %v", c.err, err)
+		}
+	}
+
+	// zero budgets are unlimited.
+	tv.ApplyTipsets = append(tv.ApplyTipsets, Tipset{})
+	if err := tv.validateBudget(ValidateOptions{MaxCARBytes: 9}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := tv.ValidateWithOptions(ValidateOptions{MaxTipsets: 1}); err == nil || !strings.Contains(err.Error(), "vector applies 2 tipsets, over the budget of 1 tipsets") {
+		t.Fatalf("expected a tipset budget error, got: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// SplitIDSuffix is the format of the suffix appended to the metadata ID of
+// the vectors produced by SplitByMessage, where the number is the index of the
+// last message applied.
+const SplitIDSuffix = "-upto-%d"
+
+// SplitByMessage splits a message-class vector into as many vectors as it has
+// messages to apply, for bisecting failures: vector i applies messages 0
+// through i, and expects their receipts.
+//
+// The postcondition state trees past each message are not known, except for
+// the last one, so all vectors but the last have no postcondition state tree
+// and carry HintPostStateUnknown. Likewise, their receipts roots, partial
+// state assertions, state diffs and diagnostics are dropped, as are the null
+// rounds past their last message. The last vector is equivalent to this one.
+//
+// The metadata ID of each vector is suffixed with SplitIDSuffix, and it's
+// linked to this vector with RelationDerivedFrom.
+func (tv TestVector) SplitByMessage() ([]*TestVector, error) {
+	if tv.Class != ClassMessage {
+		return nil, fmt.Errorf("only message vectors can be split by message, got a %s vector", tv.Class)
+	}
+	if len(tv.ApplyMessages) == 0 {
+		return nil, fmt.Errorf("vector has no messages to apply")
+	}
+	if tv.Post == nil || len(tv.Post.Receipts) != len(tv.ApplyMessages) {
+		return nil, fmt.Errorf("length of postcondition receipts must match length of messages to apply")
+	}
+
+	ret := make([]*TestVector, 0, len(tv.ApplyMessages))
+	for i := range tv.ApplyMessages {
+		split := tv.Clone()
+		if split.Meta != nil {
+			if tv.Meta.ID != "" {
+				split.Meta.AddRelation(RelationDerivedFrom, tv.Meta.ID)
+			}
+			split.Meta.ID += fmt.Sprintf(SplitIDSuffix, i)
+		}
+
+		if i < len(tv.ApplyMessages)-1 {
+			split.ApplyMessages = split.ApplyMessages[:i+1]
+			split.Post.Receipts = split.Post.Receipts[:i+1]
+
+			var failures []int
+			for _, idx := range split.Post.ApplyMessageFailures {
+				if idx <= i {
+					failures = append(failures, idx)
+				}
+			}
+			split.Post.ApplyMessageFailures = failures
+
+			var nullRounds []int64
+			for _, r := range split.NullRounds {
+				if r < tv.ApplyMessages[i].epochOffset() {
+					nullRounds = append(nullRounds, r)
+				}
+			}
+			split.NullRounds = nullRounds
+			split.Post.StateTree = nil
+			split.Post.ReceiptsRoots = nil
+			split.Post.PartialState = nil
+			split.Post.StateDiff = nil
+			split.Diagnostics = nil
+			if !split.HasHint(HintPostStateUnknown) {
+				split.Hints = append(split.Hints, HintPostStateUnknown)
+			}
+		}
+		ret = append(ret, split)
+	}
+	return ret, nil
+}
+
+// MergeMessageVectors packs message-class vectors into a single vector that
+// applies all their messages, in order, and expects all their receipts. It's
+// the inverse of SplitByMessage.
+//
+// All vectors must share the same CARs and precondition state trees, named
+// ones included, and the same selector, variants, precondition amounts and
+// null rounds, as the merged vector applies all messages on top of that single
+// state. Their messages must remain in epoch order once concatenated. Their
+// randomness rules are concatenated. Receipts are carried over verbatim, so
+// they only hold if the messages are independent of one another (e.g. they
+// have distinct senders). The postcondition state tree past all messages is
+// not known, so unless a single vector is merged, the result carries none and
+// it's hinted with HintPostStateUnknown; receipts roots, partial state
+// assertions, state diffs and diagnostics are dropped too.
+//
+// The metadata of the result is that of the first vector, linked to every
+// merged vector with RelationDerivedFrom; callers usually assign it a new ID.
+func MergeMessageVectors(vs []*TestVector) (*TestVector, error) {
+	if len(vs) == 0 {
+		return nil, fmt.Errorf("no vectors to merge")
+	}
+	for i, v := range vs {
+		if err := v.checkMergeable(vs[0]); err != nil {
+			return nil, fmt.Errorf("vector at index %d cannot be merged: %w", i, err)
+		}
+	}
+	if len(vs) == 1 {
+		return vs[0].Clone(), nil
+	}
+
+	ret := vs[0].Clone()
+	ret.ApplyMessages, ret.Post.Receipts, ret.Post.ApplyMessageFailures = nil, nil, nil
+	ret.Post.StateTree, ret.Post.ReceiptsRoots, ret.Diagnostics, ret.Randomness = nil, nil, nil, nil
+	ret.Post.PartialState, ret.Post.StateDiff = nil, nil
+	for _, v := range vs {
+		v = v.Clone()
+		for _, idx := range v.Post.ApplyMessageFailures {
+			ret.Post.ApplyMessageFailures = append(ret.Post.ApplyMessageFailures, len(ret.ApplyMessages)+idx)
+		}
+		ret.ApplyMessages = append(ret.ApplyMessages, v.ApplyMessages...)
+		ret.Post.Receipts = append(ret.Post.Receipts, v.Post.Receipts...)
+		ret.Randomness = append(ret.Randomness, v.Randomness...)
+		for _, h := range v.Hints {
+			if !ret.HasHint(h) {
+				ret.Hints = append(ret.Hints, h)
+			}
+		}
+		if ret.Meta != nil && v.Meta != nil && v.Meta.ID != "" {
+			ret.Meta.AddRelation(RelationDerivedFrom, v.Meta.ID)
+		}
+	}
+	if !ret.HasHint(HintPostStateUnknown) {
+		ret.Hints = append(ret.Hints, HintPostStateUnknown)
+	}
+	if err := ret.validateMessageEpochs(); err != nil {
+		return nil, fmt.Errorf("merging messages: %w", err)
+	}
+	return ret, nil
+}
+
+// checkMergeable checks that the vector can be merged with the first vector
+// of a merge.
+func (tv TestVector) checkMergeable(first *TestVector) error {
+	switch {
+	case tv.Class != ClassMessage:
+		return fmt.Errorf("only message vectors can be merged, got a %s vector", tv.Class)
+	case tv.Pre == nil || tv.Pre.StateTree == nil:
+		return fmt.Errorf("vector has no precondition state tree")
+	case tv.Post == nil || len(tv.Post.Receipts) != len(tv.ApplyMessages):
+		return fmt.Errorf("length of postcondition receipts must match length of messages to apply")
+	case !tv.Pre.StateTree.RootCID.Equals(first.Pre.StateTree.RootCID):
+		return fmt.Errorf("precondition state tree root %s differs from %s", tv.Pre.StateTree.RootCID, first.Pre.StateTree.RootCID)
+	case !equalValues(reflect.ValueOf(tv.Pre.NamedStateTrees), reflect.ValueOf(first.Pre.NamedStateTrees)):
+		return fmt.Errorf("named precondition state trees differ")
+	case !bytes.Equal(tv.CAR, first.CAR):
+		return fmt.Errorf("car differs")
+	case !equalValues(reflect.ValueOf(tv.CARs), reflect.ValueOf(first.CARs)):
+		return fmt.Errorf("named cars differ")
+	case !equalValues(reflect.ValueOf(tv.Selector), reflect.ValueOf(first.Selector)):
+		return fmt.Errorf("selector differs")
+	case !equalValues(reflect.ValueOf(tv.Pre.Variants), reflect.ValueOf(first.Pre.Variants)):
+		return fmt.Errorf("variants differ")
+	case !equalValues(reflect.ValueOf(tv.Pre.BaseFee), reflect.ValueOf(first.Pre.BaseFee)),
+		!equalValues(reflect.ValueOf(tv.Pre.CircSupply), reflect.ValueOf(first.Pre.CircSupply)):
+		return fmt.Errorf("precondition amounts differ")
+	case !equalValues(reflect.ValueOf(tv.NullRounds), reflect.ValueOf(first.NullRounds)):
+		return fmt.Errorf("null rounds differ")
+	}
+	return nil
+}
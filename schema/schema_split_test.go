@@ -0,0 +1,162 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitByMessage(t *testing.T) {
+	tv, err := NewMessageVector().
+		WithMeta(Metadata{ID: "batch", Gen: []GenerationData{{Source: "test"}}}).
+		WithVariant(Variant{ID: "genesis"}).
+		WithPreState(mkCid(t, "pre")).
+		WithPostState(mkCid(t, "post")).
+		AddMessage([]byte("a"), 0).ExpectReceipt(ExitOK, nil, 10).
+		AddMessage([]byte("b"), 0).ExpectReceipt(ExitOK, nil, 20).
+		AddNullRound(1).
+		AddMessage([]byte("c"), 2).ExpectReceipt(ExitErrForbidden, nil, 30).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tv.Post.ApplyMessageFailures = []int{1}
+	tv.Post.Receipts[1] = nil
+	tv.Post.PartialState = []ActorAssertion{{Address: mustIDAddress(t, 100), Balance: amount(1)}}
+	if err := tv.Post.SetStateDiff(StateDiff{Actors: []ActorChange{{Address: mustIDAddress(t, 100), Balance: amount(1)}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	splits, err := tv.SplitByMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(splits) != 3 {
+		t.Fatalf("expected 3 vectors, got %d", len(splits))
+	}
+	for i, split := range splits {
+		if err := split.Validate(); err != nil {
+			t.Fatalf("vector %d is invalid: %s", i, err)
+		}
+		if len(split.ApplyMessages) != i+1 || len(split.Post.Receipts) != i+1 {
+			t.Fatalf("vector %d applies %d messages, and expects %d receipts", i, len(split.ApplyMessages), len(split.Post.Receipts))
+		}
+		if expected := []RelatedVector{{Relation: RelationDerivedFrom, Target: "batch"}}; !reflect.DeepEqual(split.Meta.Related, expected) {
+			t.Fatalf("vector %d: expected related vectors %v, got %v", i, expected, split.Meta.Related)
+		}
+	}
+
+	first := splits[0]
+	if first.Meta.ID != "batch-upto-0" || first.Post.StateTree != nil || !first.HasHint(HintPostStateUnknown) || first.Post.ApplyMessageFailures != nil {
+		t.Fatalf("unexpected first vector: %+v", first)
+	}
+	if failures := splits[1].Post.ApplyMessageFailures; !reflect.DeepEqual(failures, []int{1}) {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+	for i, split := range splits[:2] {
+		if split.Post.PartialState != nil {
+			t.Fatalf("vector %d: expected no partial state assertions, got %v", i, split.Post.PartialState)
+		}
+		if split.Post.StateDiff != nil {
+			t.Fatalf("vector %d: expected no state diff", i)
+		}
+	}
+	if splits[1].NullRounds != nil || !reflect.DeepEqual(splits[2].NullRounds, []int64{1}) {
+		t.Fatalf("unexpected null rounds %v, %v", splits[1].NullRounds, splits[2].NullRounds)
+	}
+	last := splits[2]
+	if last.HasHint(HintPostStateUnknown) || !last.Equal(tv) || last.Meta.ID != "batch-upto-2" {
+		t.Fatalf("expected the last vector to be equivalent to the original, got: %+v", last)
+	}
+	if tv.Meta.ID != "batch" || len(tv.ApplyMessages) != 3 {
+		t.Fatal("original vector was modified")
+	}
+
+	if _, err := (TestVector{Class: ClassTipset}).SplitByMessage(); err == nil || !strings.Contains(err.Error(), "got a tipset vector") {
+		t.Fatalf("expected a class error, got: %v", err)
+	}
+}
+
+func TestMergeMessageVectors(t *testing.T) {
+	mk := func(id string, msgs ...string) *TestVector {
+		b := NewMessageVector().
+			WithMeta(Metadata{ID: id, Gen: []GenerationData{{Source: "test"}}}).
+			WithVariant(Variant{ID: "genesis"}).
+			WithPreState(mkCid(t, "pre")).
+			WithPostState(mkCid(t, id))
+		for _, msg := range msgs {
+			b.AddMessage([]byte(msg), 0).ExpectReceipt(ExitOK, []byte(msg), 10)
+		}
+		tv, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tv
+	}
+
+	a, b := mk("a", "a1"), mk("b", "b1", "b2")
+	b.Post.ApplyMessageFailures = []int{1}
+	b.Post.Receipts[1] = nil
+	a.Post.PartialState = []ActorAssertion{{Address: mustIDAddress(t, 100), Balance: amount(1)}}
+	if err := a.Post.SetStateDiff(StateDiff{Actors: []ActorChange{{Address: mustIDAddress(t, 100), Balance: amount(1)}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := MergeMessageVectors([]*TestVector{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := merged.Validate(); err != nil {
+		t.Fatalf("merged vector is invalid: %s", err)
+	}
+	if len(merged.ApplyMessages) != 3 || string(merged.ApplyMessages[2].Bytes) != "b2" || string(merged.Post.Receipts[1].ReturnValue) != "b1" {
+		t.Fatalf("unexpected messages or receipts: %+v, %+v", merged.ApplyMessages, merged.Post.Receipts)
+	}
+	if !reflect.DeepEqual(merged.Post.ApplyMessageFailures, []int{2}) {
+		t.Fatalf("unexpected failures %v", merged.Post.ApplyMessageFailures)
+	}
+	if merged.Post.StateTree != nil || !merged.HasHint(HintPostStateUnknown) {
+		t.Fatal("expected the merged post state to be unknown")
+	}
+	if merged.Post.PartialState != nil || merged.Post.StateDiff != nil {
+		t.Fatalf("expected no partial state assertions or state diff, got %v, %v", merged.Post.PartialState, merged.Post.StateDiff)
+	}
+	expected := []RelatedVector{{Relation: RelationDerivedFrom, Target: "a"}, {Relation: RelationDerivedFrom, Target: "b"}}
+	if merged.Meta.ID != "a" || !reflect.DeepEqual(merged.Meta.Related, expected) {
+		t.Fatalf("unexpected metadata: %+v", merged.Meta)
+	}
+	if len(a.ApplyMessages) != 1 || a.Post.StateTree == nil {
+		t.Fatal("merged vector was modified")
+	}
+
+	c := mk("c", "c1")
+	c.Pre.StateTree.RootCID = mkCid(t, "other")
+	if _, err := MergeMessageVectors([]*TestVector{a, c}); err == nil || !strings.Contains(err.Error(), "vector at index 1 cannot be merged: precondition state tree root") {
+		t.Fatalf("expected a pre root error, got: %v", err)
+	}
+	c = mk("c", "c1")
+	c.CAR = []byte("car")
+	if _, err := MergeMessageVectors([]*TestVector{a, c}); err == nil || !strings.Contains(err.Error(), "car differs") {
+		t.Fatalf("expected a car error, got: %v", err)
+	}
+	c = mk("c", "c1")
+	c.CARs = map[string]Base64EncodedBytes{CARPost: []byte("car")}
+	if _, err := MergeMessageVectors([]*TestVector{a, c}); err == nil || !strings.Contains(err.Error(), "named cars differ") {
+		t.Fatalf("expected a named car error, got: %v", err)
+	}
+	c = mk("c", "c1")
+	c.Pre.NamedStateTrees = map[string]StateTree{"snapshot": {RootCID: mkCid(t, "snapshot")}}
+	if _, err := MergeMessageVectors([]*TestVector{a, c}); err == nil || !strings.Contains(err.Error(), "named precondition state trees differ") {
+		t.Fatalf("expected a named state tree error, got: %v", err)
+	}
+	c = mk("c", "c1")
+	c.NullRounds = []int64{1}
+	if _, err := MergeMessageVectors([]*TestVector{a, c}); err == nil || !strings.Contains(err.Error(), "null rounds differ") {
+		t.Fatalf("expected a null rounds error, got: %v", err)
+	}
+	offset := int64(1)
+	a.ApplyMessages[0].EpochOffset = &offset
+	if _, err := MergeMessageVectors([]*TestVector{a, b}); err == nil || !strings.Contains(err.Error(), "merging messages: message at index 1 has epoch offset 0") {
+		t.Fatalf("expected an epoch order error, got: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// StateDiff is the expected change to the state tree made by a vector: the
+// actors it creates or modifies, with their new values. Applied to the
+// precondition state tree, it yields the postcondition state tree. See
+// Postconditions.StateDiff.
+type StateDiff struct {
+	// Actors are the changes to actors, each for a distinct actor.
+	Actors []ActorChange `json:"actors"`
+}
+
+// ActorChange is the change to a single actor within a StateDiff. Fields left
+// unset are unchanged.
+type ActorChange struct {
+	// Address is the ID address of the actor.
+	Address address.Address `json:"address"`
+
+	// Created is set if the actor is absent from the precondition state tree.
+	// Created actors start with a zero nonce and balance, and must set Code
+	// and Head.
+	Created bool `json:"created,omitempty"`
+
+	// Code, if set, is the new code CID of the actor.
+	Code *cid.Cid `json:"code,omitempty"`
+
+	// Head, if set, is the new CID of the state of the actor.
+	Head *cid.Cid `json:"head,omitempty"`
+
+	// Nonce, if set, is the new nonce of the actor.
+	Nonce *uint64 `json:"nonce,omitempty"`
+
+	// Balance, if set, is the new balance of the actor.
+	Balance *TokenAmount `json:"balance,omitempty"`
+}
+
+// SetStateDiff sets the expected state diff of the postconditions to d.
+func (p *Postconditions) SetStateDiff(d StateDiff) error {
+	var buf bytes.Buffer
+	if err := encodeCBOR(&buf, reflect.ValueOf(d)); err != nil {
+		return fmt.Errorf("encoding state diff: %w", err)
+	}
+	p.StateDiff = buf.Bytes()
+	return nil
+}
+
+// DecodeStateDiff decodes the expected state diff of the postconditions. It
+// returns nil if there's none.
+func (p Postconditions) DecodeStateDiff() (*StateDiff, error) {
+	if len(p.StateDiff) == 0 {
+		return nil, nil
+	}
+	var d StateDiff
+	br := bytes.NewReader(p.StateDiff)
+	if err := decodeCBOR(br, reflect.ValueOf(&d).Elem()); err != nil {
+		return nil, fmt.Errorf("decoding state diff: %w", err)
+	}
+	if br.Len() > 0 {
+		return nil, fmt.Errorf("decoding state diff: %d trailing bytes", br.Len())
+	}
+	return &d, nil
+}
+
+// ApplyDiff applies the expected state diff of the postconditions to the
+// state tree with the given root, held in preState, and returns the root of
+// the resulting state tree, which is the expected postcondition state tree
+// root. The blocks of the resulting state tree are written to preState.
+// Without a state diff, it returns preRoot.
+func (p Postconditions) ApplyDiff(preState blockstore.Blockstore, preRoot cid.Cid) (cid.Cid, error) {
+	d, err := p.DecodeStateDiff()
+	if err != nil || d == nil {
+		return preRoot, err
+	}
+	if err := d.validate(); err != nil {
+		return cid.Undef, err
+	}
+	st, err := loadStateTree(preState, preRoot)
+	if err != nil {
+		return cid.Undef, err
+	}
+	for i, c := range d.Actors {
+		if err := st.applyChange(c); err != nil {
+			return cid.Undef, fmt.Errorf("state diff change at index %d, for actor %s: %w", i, c.Address, err)
+		}
+	}
+	return st.Flush()
+}
+
+// applyChange applies the change to its actor in the state tree.
+func (st *stateTree) applyChange(c ActorChange) error {
+	raw, found, err := st.actor(c.Address)
+	if err != nil {
+		return err
+	}
+	if found == c.Created {
+		if found {
+			return fmt.Errorf("actor is created, but already exists")
+		}
+		return fmt.Errorf("actor not found")
+	}
+
+	// actors are arrays of (code, head, nonce, balance, ...); later versions
+	// append fields, which are preserved.
+	var fields []cbg.Deferred
+	if c.Created {
+		fields = make([]cbg.Deferred, 4)
+		fields[2].Raw = cbg.CborEncodeMajorType(cbg.MajUnsignedInt, 0)
+		fields[3].Raw = encodeValue(NewTokenAmount(0))
+		if st.versioned && st.version >= 5 {
+			// the delegated address, added with version 5.
+			fields = append(fields, cbg.Deferred{Raw: cbg.CborNull})
+		}
+	} else {
+		br := bytes.NewReader(raw)
+		maj, n, err := cbg.CborReadHeader(br)
+		if err != nil || maj != cbg.MajArray || n < 4 {
+			return fmt.Errorf("expected actor to be a cbor array of at least 4 elements")
+		}
+		fields = make([]cbg.Deferred, n)
+		for i := range fields {
+			if err := fields[i].UnmarshalCBOR(br); err != nil {
+				return fmt.Errorf("reading actor: %w", err)
+			}
+		}
+	}
+
+	if c.Code != nil {
+		fields[0].Raw = encodeValue(*c.Code)
+	}
+	if c.Head != nil {
+		fields[1].Raw = encodeValue(*c.Head)
+	}
+	if c.Nonce != nil {
+		fields[2].Raw = cbg.CborEncodeMajorType(cbg.MajUnsignedInt, *c.Nonce)
+	}
+	if c.Balance != nil {
+		fields[3].Raw = encodeValue(*c.Balance)
+	}
+
+	var buf bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(fields)))
+	for _, f := range fields {
+		buf.Write(f.Raw)
+	}
+	return st.setActor(c.Address, buf.Bytes())
+}
+
+// encodeValue returns the CBOR encoding of a CID or TokenAmount, which can't
+// fail.
+func encodeValue(v interface{}) []byte {
+	var buf bytes.Buffer
+	_ = encodeCBOR(&buf, reflect.ValueOf(v))
+	return buf.Bytes()
+}
+
+// validate checks that every change of the diff is for a distinct actor,
+// named by its ID address, and that it's well-formed.
+func (d StateDiff) validate() error {
+	seen := make(map[address.Address]int, len(d.Actors))
+	for i, c := range d.Actors {
+		if c.Address.Protocol() != address.ID || c.Address == address.Undef {
+			return fmt.Errorf("state diff change at index %d is for %q, which is not an id address", i, c.Address)
+		}
+		if j, ok := seen[c.Address]; ok {
+			return fmt.Errorf("state diff changes at indices %d and %d are both for actor %s", j, i, c.Address)
+		}
+		seen[c.Address] = i
+
+		if c.Created && (c.Code == nil || c.Head == nil) {
+			return fmt.Errorf("state diff change at index %d creates actor %s without a code and head", i, c.Address)
+		}
+		if !c.Created && c.Code == nil && c.Head == nil && c.Nonce == nil && c.Balance == nil {
+			return fmt.Errorf("state diff change at index %d, for actor %s, changes nothing", i, c.Address)
+		}
+		if b := c.Balance.BigInt(); b != nil && b.Sign() < 0 {
+			return fmt.Errorf("state diff change at index %d, for actor %s, has negative balance %s attoFIL", i, c.Address, b)
+		}
+	}
+	return nil
+}
+
+// validateStateDiff checks that the state diff of the vector, if any,
+// decodes, and is well-formed. Whether it applies to the precondition state
+// tree is checked by ValidateCARReachability, as it requires the CAR.
+func (tv TestVector) validateStateDiff() error {
+	if tv.Post == nil {
+		return nil
+	}
+	d, err := tv.Post.DecodeStateDiff()
+	if err != nil || d == nil {
+		return err
+	}
+	return d.validate()
+}
+
+// checkStateDiff checks that the state diff of the vector, applied to its
+// precondition state tree held in bs, yields its postcondition state tree
+// root. It's a no-op for vectors without a state diff, or without either
+// root.
+func (tv TestVector) checkStateDiff(bs blockstore.Blockstore) error {
+	if tv.Post == nil || len(tv.Post.StateDiff) == 0 || tv.Pre == nil || tv.Pre.StateTree == nil || tv.Post.StateTree == nil {
+		return nil
+	}
+	pre, post := tv.Pre.StateTree.RootCID, tv.Post.StateTree.RootCID
+	if !pre.Defined() || !post.Defined() {
+		return nil
+	}
+	root, err := tv.Post.ApplyDiff(bs, pre)
+	if err != nil {
+		return fmt.Errorf("applying state diff: %w", err)
+	}
+	if !root.Equals(post) {
+		return fmt.Errorf("state diff applied to the precondition state tree yields root %s; expected the postcondition state tree root %s", root, post)
+	}
+	return nil
+}
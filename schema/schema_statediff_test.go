@@ -0,0 +1,301 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+func TestStateDiffRoundTrip(t *testing.T) {
+	var (
+		account, _ = address.NewIDAddress(100)
+		head       = mkCid(t, "new state")
+		nonce      = uint64(1)
+		diff       = StateDiff{Actors: []ActorChange{{Address: account, Head: &head, Nonce: &nonce, Balance: amount(10)}}}
+		post       Postconditions
+	)
+	if d, err := post.DecodeStateDiff(); err != nil || d != nil {
+		t.Fatalf("expected no state diff, got: %v, %v", d, err)
+	}
+	if err := post.SetStateDiff(diff); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Postconditions
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	d, err := decoded.DecodeStateDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*d, diff) {
+		t.Fatalf("expected %+v, got %+v", diff, *d)
+	}
+
+	decoded.StateDiff = append(decoded.StateDiff, 0)
+	if _, err := decoded.DecodeStateDiff(); err == nil || !strings.Contains(err.Error(), "1 trailing bytes") {
+		t.Fatalf("expected a trailing bytes error, got: %v", err)
+	}
+}
+
+func TestValidateStateDiff(t *testing.T) {
+	var (
+		account, _ = address.NewIDAddress(100)
+		created, _ = address.NewIDAddress(101)
+		robust, _  = address.NewSecp256k1Address([]byte("pubkey"))
+		code       = mkCid(t, "code")
+		head       = mkCid(t, "state")
+		nonce      = uint64(1)
+		valid      = []ActorChange{{Address: account, Nonce: &nonce}, {Address: created, Created: true, Code: &code, Head: &head}}
+	)
+	tv := TestVector{Post: &Postconditions{}}
+	if err := tv.Post.SetStateDiff(StateDiff{Actors: valid}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		change ActorChange
+		err    string
+	}{
+		{ActorChange{Address: robust, Nonce: &nonce}, "change at index 2 is for \"" + robust.String() + "\", which is not an id address"},
+		{ActorChange{Nonce: &nonce}, "which is not an id address"},
+		{ActorChange{Address: account, Balance: amount(1)}, "changes at indices 0 and 2 are both for actor t0100"},
+		{ActorChange{Address: mustIDAddress(t, 102), Created: true, Code: &code}, "creates actor t0102 without a code and head"},
+		{ActorChange{Address: mustIDAddress(t, 102)}, "changes nothing"},
+		{ActorChange{Address: mustIDAddress(t, 102), Balance: amount(-1)}, "negative balance -1"},
+	}
+	for _, c := range cases {
+		tv := TestVector{Post: &Postconditions{}}
+		if err := tv.Post.SetStateDiff(StateDiff{Actors: append(append([]ActorChange(nil), valid...), c.change)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got: %v", c.err, err)
+		}
+	}
+
+	tv.Post.StateDiff = []byte{0xff}
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "decoding state diff") {
+		t.Fatalf("expected a decoding error, got: %v", err)
+	}
+}
+
+func mustIDAddress(t *testing.T, id uint64) address.Address {
+	addr, err := address.NewIDAddress(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func TestApplyDiff(t *testing.T) {
+	var (
+		account = mustIDAddress(t, 100)
+		created = mustIDAddress(t, 101)
+		code    = mkCid(t, "code")
+		head    = mkCid(t, "new state")
+		nonce   = uint64(3)
+	)
+	for _, legacy := range []bool{false, true} {
+		car, root, robust := mkStateTree(t, legacy)
+		bs, err := TestVector{CAR: car}.loadCAR(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		post := &Postconditions{}
+		if r, err := post.ApplyDiff(bs, root); err != nil || !r.Equals(root) {
+			t.Fatalf("legacy=%t: expected no diff to leave the root unchanged, got: %s, %v", legacy, r, err)
+		}
+		diff := StateDiff{Actors: []ActorChange{
+			{Address: account, Nonce: &nonce, Balance: amount(10)},
+			{Address: created, Created: true, Code: &code, Head: &head},
+		}}
+		if err := post.SetStateDiff(diff); err != nil {
+			t.Fatal(err)
+		}
+		applied, err := post.ApplyDiff(bs, root)
+		if err != nil {
+			t.Fatalf("legacy=%t: unexpected error: %s", legacy, err)
+		}
+
+		st, err := loadStateTree(bs, applied)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, found, err := st.actor(robust)
+		if err != nil || !found || !bytes.Equal(raw, mkActorWith(t, code, mkCid(t, "account state"), nonce, amount(10))) {
+			t.Fatalf("legacy=%t: unexpected account actor: %x, %t, %v", legacy, raw, found, err)
+		}
+		raw, found, err = st.actor(created)
+		if err != nil || !found || !bytes.Equal(raw, mkActorWith(t, code, head, 0, amount(0))) {
+			t.Fatalf("legacy=%t: unexpected created actor: %x, %t, %v", legacy, raw, found, err)
+		}
+
+		tv := TestVector{
+			Pre:  &Preconditions{StateTree: &StateTree{RootCID: root}},
+			Post: &Postconditions{StateTree: &StateTree{RootCID: applied}, StateDiff: post.StateDiff},
+		}
+		if err := tv.checkStateDiff(bs); err != nil {
+			t.Fatalf("legacy=%t: unexpected error: %s", legacy, err)
+		}
+		tv.Post.StateTree.RootCID = root
+		if err := tv.checkStateDiff(bs); err == nil || !strings.Contains(err.Error(), "expected the postcondition state tree root "+root.String()) {
+			t.Fatalf("legacy=%t: expected a root mismatch, got: %v", legacy, err)
+		}
+
+		for _, c := range []struct {
+			change ActorChange
+			err    string
+		}{
+			{ActorChange{Address: account, Created: true, Code: &code, Head: &head}, "actor t0100: actor is created, but already exists"},
+			{ActorChange{Address: mustIDAddress(t, 200), Nonce: &nonce}, "actor t0200: actor not found"},
+		} {
+			if err := post.SetStateDiff(StateDiff{Actors: []ActorChange{c.change}}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := post.ApplyDiff(bs, root); err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("legacy=%t: expected error containing %q, got: %v", legacy, c.err, err)
+			}
+		}
+	}
+}
+
+// mkActorWith encodes an actor with the given fields.
+func mkActorWith(t *testing.T, code, head cid.Cid, nonce uint64, balance *TokenAmount) []byte {
+	var buf bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 4)
+	for _, c := range []cid.Cid{code, head} {
+		if err := cbg.WriteCid(&buf, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajUnsignedInt, nonce)
+	if err := encodeCBOR(&buf, reflect.ValueOf(*balance)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestApplyDiffCorpus is a known-answer test of ApplyDiff, and of the HAMT
+// writer beneath it: the actors that corpus vectors change, applied as a diff
+// to their precondition state trees, must yield the postcondition state tree
+// roots that Lotus produced.
+func TestApplyDiffCorpus(t *testing.T) {
+	for _, path := range []string{
+		"../corpus/transfer/basic--ok--genesis.json",
+		"../corpus/transfer/basic--ok--actorsv2.json",
+		"../corpus/actor_creation/on_transfer--ok-create-secp256k1--actorsv2.json",
+		"../corpus/actor_creation/addresses--sequential-10--genesis.json",
+	} {
+		t.Run(path, func(t *testing.T) {
+			tv, err := LoadTestVectorFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bs, err := tv.LoadCAR(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pre, err := loadStateTree(bs, tv.Pre.StateTree.RootCID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			post, err := loadStateTree(bs, tv.Post.StateTree.RootCID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var diff StateDiff
+			walkHAMT(t, bs, post.actors, func(key []byte, raw []byte) {
+				addr, err := address.NewFromBytes(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				old, found, err := pre.actor(addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if found && bytes.Equal(old, raw) {
+					return
+				}
+				diff.Actors = append(diff.Actors, decodeActorChange(t, addr, raw, !found))
+			})
+			if len(diff.Actors) == 0 {
+				t.Fatal("expected the vector to change actors")
+			}
+
+			p := &Postconditions{}
+			if err := p.SetStateDiff(diff); err != nil {
+				t.Fatal(err)
+			}
+			root, err := p.ApplyDiff(bs, tv.Pre.StateTree.RootCID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !root.Equals(tv.Post.StateTree.RootCID) {
+				t.Fatalf("expected the diff of %d actors to yield the postcondition root %s, got %s", len(diff.Actors), tv.Post.StateTree.RootCID, root)
+			}
+		})
+	}
+}
+
+// walkHAMT calls fn with every entry of the HAMT with the given root.
+func walkHAMT(t *testing.T, bs blockstore.Blockstore, root cid.Cid, fn func(key, raw []byte)) {
+	node, err := loadHAMTNode(bs, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range node.pointers {
+		if p.link.Defined() {
+			walkHAMT(t, bs, p.link, fn)
+			continue
+		}
+		for _, e := range p.bucket {
+			fn(e.key, e.value.Raw)
+		}
+	}
+}
+
+// decodeActorChange returns the change that sets every field of the actor
+// encoded in raw.
+func decodeActorChange(t *testing.T, addr address.Address, raw []byte, created bool) ActorChange {
+	br := bytes.NewReader(raw)
+	if maj, n, err := cbg.CborReadHeader(br); err != nil || maj != cbg.MajArray || n < 4 {
+		t.Fatalf("actor %s: expected a cbor array of at least 4 elements", addr)
+	}
+	c := ActorChange{Address: addr, Created: created, Balance: new(TokenAmount)}
+	code, err := cbg.ReadCid(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := cbg.ReadCid(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maj, nonce, err := cbg.CborReadHeader(br)
+	if err != nil || maj != cbg.MajUnsignedInt {
+		t.Fatalf("actor %s: expected a nonce", addr)
+	}
+	if err := decodeCBOR(br, reflect.ValueOf(c.Balance).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	c.Code, c.Head, c.Nonce = &code, &head, &nonce
+	return c
+}
@@ -0,0 +1,456 @@
+package schema
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/filecoin-project/go-address"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+const (
+	// hamtBitWidth is the bit width of the HAMTs of Filecoin state trees.
+	hamtBitWidth = 5
+
+	// hamtBucketSize is the number of entries a HAMT pointer holds before
+	// it's split into a child node.
+	hamtBucketSize = 3
+)
+
+// stateTree looks up and updates actors in a Filecoin state tree. It only
+// interprets as much of the tree as that requires, and doesn't depend on the
+// actors version, as the encoding of actors has only been extended over
+// time. Updates are written to the blockstore as they're made; Flush returns
+// the resulting root.
+type stateTree struct {
+	bs     blockstore.Blockstore
+	actors cid.Cid // the root of the HAMT of actors.
+
+	// versioned is set for state trees whose root is a state root (version,
+	// actors, info), rather than the HAMT of actors itself, as in the
+	// original format.
+	versioned bool
+	version   uint64
+	info      cbg.Deferred
+}
+
+// loadStateTree loads the state tree with the given root.
+func loadStateTree(bs blockstore.Blockstore, root cid.Cid) (*stateTree, error) {
+	blk, err := bs.Get(root)
+	if err != nil {
+		return nil, fmt.Errorf("loading state root %s: %w", root, err)
+	}
+	br := bytes.NewReader(blk.RawData())
+	maj, n, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if maj != cbg.MajArray || n != 3 {
+		return &stateTree{bs: bs, actors: root}, nil
+	}
+
+	st := &stateTree{bs: bs, versioned: true}
+	if maj, st.version, err = cbg.CborReadHeader(br); err != nil || maj != cbg.MajUnsignedInt {
+		return nil, fmt.Errorf("expected state root %s to start with a version", root)
+	}
+	if st.actors, err = cbg.ReadCid(br); err != nil {
+		return nil, fmt.Errorf("reading actors root of state root %s: %w", root, err)
+	}
+	if err := st.info.UnmarshalCBOR(br); err != nil {
+		return nil, fmt.Errorf("reading info of state root %s: %w", root, err)
+	}
+	return st, nil
+}
+
+// Flush returns the root of the state tree, writing a new state root if the
+// tree is versioned.
+func (st *stateTree) Flush() (cid.Cid, error) {
+	if !st.versioned {
+		return st.actors, nil
+	}
+	var buf bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 3)
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajUnsignedInt, st.version)
+	if err := cbg.WriteCid(&buf, st.actors); err != nil {
+		return cid.Undef, err
+	}
+	buf.Write(st.info.Raw)
+	return putBlock(st.bs, buf.Bytes())
+}
+
+// actor returns the raw CBOR encoding of the actor with the given address.
+// Addresses other than ID addresses are first resolved through the address
+// map of the init actor.
+func (st *stateTree) actor(addr address.Address) ([]byte, bool, error) {
+	if addr.Protocol() != address.ID {
+		id, found, err := st.resolve(addr)
+		if err != nil || !found {
+			return nil, false, err
+		}
+		addr = id
+	}
+	return hamtFind(st.bs, st.actors, addr.Bytes())
+}
+
+// setActor sets the raw CBOR encoding of the actor with the given ID address.
+func (st *stateTree) setActor(addr address.Address, raw []byte) error {
+	if addr.Protocol() != address.ID {
+		return fmt.Errorf("actors can only be set by id address, got %s", addr)
+	}
+	root, err := hamtSet(st.bs, st.actors, addr.Bytes(), raw)
+	if err != nil {
+		return err
+	}
+	st.actors = root
+	return nil
+}
+
+// resolve returns the ID address that the init actor assigned to addr.
+func (st *stateTree) resolve(addr address.Address) (address.Address, bool, error) {
+	raw, found, err := hamtFind(st.bs, st.actors, initActorAddr.Bytes())
+	if err != nil {
+		return address.Undef, false, err
+	}
+	if !found {
+		return address.Undef, false, fmt.Errorf("no init actor to resolve the address through")
+	}
+	head, err := readActorHead(raw)
+	if err != nil {
+		return address.Undef, false, fmt.Errorf("reading init actor: %w", err)
+	}
+	blk, err := st.bs.Get(head)
+	if err != nil {
+		return address.Undef, false, fmt.Errorf("loading init actor state %s: %w", head, err)
+	}
+	// the init actor state is (address map, next id, network name).
+	br := bytes.NewReader(blk.RawData())
+	if maj, _, err := cbg.CborReadHeader(br); err != nil || maj != cbg.MajArray {
+		return address.Undef, false, fmt.Errorf("expected init actor state %s to be a cbor array", head)
+	}
+	addrMap, err := cbg.ReadCid(br)
+	if err != nil {
+		return address.Undef, false, fmt.Errorf("reading init actor address map: %w", err)
+	}
+
+	raw, found, err = hamtFind(st.bs, addrMap, addr.Bytes())
+	if err != nil || !found {
+		return address.Undef, false, err
+	}
+	maj, id, err := cbg.CborReadHeader(bytes.NewReader(raw))
+	if err != nil || maj != cbg.MajUnsignedInt {
+		return address.Undef, false, fmt.Errorf("expected the init actor to map %s to an actor id", addr)
+	}
+	ret, err := address.NewIDAddress(id)
+	return ret, err == nil, err
+}
+
+// initActorAddr is the address of the init actor.
+var initActorAddr, _ = address.NewIDAddress(1)
+
+// readActorHead returns the head of a raw actor, i.e. (code, head, nonce,
+// balance, ...).
+func readActorHead(raw []byte) (cid.Cid, error) {
+	br := bytes.NewReader(raw)
+	if maj, _, err := cbg.CborReadHeader(br); err != nil || maj != cbg.MajArray {
+		return cid.Undef, fmt.Errorf("expected actor to be a cbor array")
+	}
+	if _, err := cbg.ReadCid(br); err != nil {
+		return cid.Undef, fmt.Errorf("reading actor code: %w", err)
+	}
+	return cbg.ReadCid(br)
+}
+
+// putBlock writes the dag-cbor block to the blockstore, and returns its CID.
+func putBlock(bs blockstore.Blockstore, data []byte) (cid.Cid, error) {
+	c, err := cidBuilder.Sum(data)
+	if err != nil {
+		return cid.Undef, err
+	}
+	blk, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return c, bs.Put(blk)
+}
+
+// hamtNode is a decoded node of a HAMT keyed by the SHA-256 of its keys, with
+// a bit width of hamtBitWidth, like all HAMTs in Filecoin state trees.
+type hamtNode struct {
+	bitfield *big.Int
+	pointers []hamtPointer
+
+	// legacy is set for nodes whose pointers are encoded in the original
+	// form: as maps, holding a link under key "0", or a bucket under key "1".
+	// Nodes without pointers are taken to be in the current form.
+	legacy bool
+}
+
+// hamtPointer is either a link to a child node, or a bucket of entries,
+// sorted by key.
+type hamtPointer struct {
+	link   cid.Cid
+	bucket []hamtEntry
+}
+
+type hamtEntry struct {
+	key   []byte
+	value cbg.Deferred
+}
+
+// loadHAMTNode loads and decodes the HAMT node with the given CID.
+func loadHAMTNode(bs blockstore.Blockstore, c cid.Cid) (*hamtNode, error) {
+	blk, err := bs.Get(c)
+	if err != nil {
+		return nil, fmt.Errorf("loading hamt node %s: %w", c, err)
+	}
+	n, err := decodeHAMTNode(blk.RawData())
+	if err != nil {
+		return nil, fmt.Errorf("decoding hamt node %s: %w", c, err)
+	}
+	return n, nil
+}
+
+func decodeHAMTNode(raw []byte) (*hamtNode, error) {
+	br := bytes.NewReader(raw)
+	if maj, n, err := cbg.CborReadHeader(br); err != nil || maj != cbg.MajArray || n != 2 {
+		return nil, fmt.Errorf("expected a cbor array of 2 elements")
+	}
+	bitfield, err := cbg.ReadByteArray(br, cbg.ByteArrayMaxLen)
+	if err != nil {
+		return nil, fmt.Errorf("reading bitfield: %w", err)
+	}
+	maj, count, err := cbg.CborReadHeader(br)
+	if err != nil || maj != cbg.MajArray || count > 1<<hamtBitWidth {
+		return nil, fmt.Errorf("expected a cbor array of at most %d pointers", 1<<hamtBitWidth)
+	}
+
+	node := &hamtNode{bitfield: new(big.Int).SetBytes(bitfield)}
+	for i := uint64(0); i < count; i++ {
+		var raw cbg.Deferred
+		if err := raw.UnmarshalCBOR(br); err != nil {
+			return nil, fmt.Errorf("reading pointer %d: %w", i, err)
+		}
+		p, legacy, err := decodeHAMTPointer(raw.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("reading pointer %d: %w", i, err)
+		}
+		node.pointers = append(node.pointers, p)
+		node.legacy = node.legacy || legacy
+	}
+	return node, nil
+}
+
+// decodeHAMTPointer decodes a HAMT pointer, in either its current form (a
+// link or a bucket), or its legacy form.
+func decodeHAMTPointer(raw []byte) (p hamtPointer, legacy bool, err error) {
+	br := bytes.NewReader(raw)
+	if raw[0]>>5 == cbg.MajMap {
+		if _, n, err := cbg.CborReadHeader(br); err != nil || n != 1 {
+			return p, true, fmt.Errorf("expected a map of 1 entry")
+		}
+		k, err := cbg.ReadString(br)
+		if err != nil {
+			return p, true, err
+		}
+		if k != "0" && k != "1" {
+			return p, true, fmt.Errorf("unexpected key %q", k)
+		}
+		legacy = true
+		raw = raw[len(raw)-br.Len():]
+	}
+
+	switch raw[0] >> 5 {
+	case cbg.MajTag:
+		p.link, err = cbg.ReadCid(br)
+		return p, legacy, err
+	case cbg.MajArray:
+		_, n, err := cbg.CborReadHeader(br)
+		if err != nil {
+			return p, legacy, err
+		}
+		for i := uint64(0); i < n; i++ {
+			if maj, n, err := cbg.CborReadHeader(br); err != nil || maj != cbg.MajArray || n != 2 {
+				return p, legacy, fmt.Errorf("expected hamt entry to be a cbor array of 2 elements")
+			}
+			var e hamtEntry
+			if e.key, err = cbg.ReadByteArray(br, cbg.ByteArrayMaxLen); err != nil {
+				return p, legacy, fmt.Errorf("reading hamt key: %w", err)
+			}
+			if err := e.value.UnmarshalCBOR(br); err != nil {
+				return p, legacy, fmt.Errorf("reading hamt value: %w", err)
+			}
+			p.bucket = append(p.bucket, e)
+		}
+		return p, legacy, nil
+	}
+	return p, legacy, fmt.Errorf("unexpected cbor major type %d", raw[0]>>5)
+}
+
+// encode returns the canonical encoding of the node.
+func (n *hamtNode) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 2)
+	_ = writeByteString(&buf, n.bitfield.Bytes())
+	_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(n.pointers)))
+	for _, p := range n.pointers {
+		if n.legacy {
+			key := "1"
+			if p.bucket == nil {
+				key = "0"
+			}
+			_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajMap, 1)
+			_ = writeTextString(&buf, key)
+		}
+		if p.bucket == nil {
+			if err := cbg.WriteCid(&buf, p.link); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(p.bucket)))
+		for _, e := range p.bucket {
+			_ = cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 2)
+			_ = writeByteString(&buf, e.key)
+			buf.Write(e.value.Raw)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// index returns the position, within the pointers of the node, of the
+// pointer for the given bit, and whether it's set.
+func (n *hamtNode) index(bit int) (int, bool) {
+	pos := 0 // the pointers of the set bits below ours precede it.
+	for i := 0; i < bit; i++ {
+		pos += int(n.bitfield.Bit(i))
+	}
+	return pos, n.bitfield.Bit(bit) == 1
+}
+
+// hamtFind looks up the raw value of key in the HAMT rooted at root.
+func hamtFind(bs blockstore.Blockstore, root cid.Cid, key []byte) ([]byte, bool, error) {
+	hash := sha256.Sum256(key)
+	c := root
+	for depth := 0; (depth+1)*hamtBitWidth <= len(hash)*8; depth++ {
+		node, err := loadHAMTNode(bs, c)
+		if err != nil {
+			return nil, false, err
+		}
+		pos, ok := node.index(hashBits(hash[:], depth*hamtBitWidth, hamtBitWidth))
+		if !ok {
+			return nil, false, nil
+		}
+		p := node.pointers[pos]
+		if p.bucket == nil {
+			c = p.link
+			continue
+		}
+		for _, e := range p.bucket {
+			if bytes.Equal(e.key, key) {
+				return e.value.Raw, true, nil
+			}
+		}
+		return nil, false, nil
+	}
+	return nil, false, fmt.Errorf("hamt %s is deeper than its hash allows", root)
+}
+
+// hamtSet sets key to the raw value in the HAMT rooted at root, writing the
+// modified nodes to the blockstore, and returns the new root. The resulting
+// HAMT is the one the reference implementation produces, as HAMTs are
+// canonical: their structure only depends on their entries.
+func hamtSet(bs blockstore.Blockstore, root cid.Cid, key, value []byte) (cid.Cid, error) {
+	node, err := loadHAMTNode(bs, root)
+	if err != nil {
+		return cid.Undef, err
+	}
+	hash := sha256.Sum256(key)
+	if err := node.set(bs, hash[:], 0, hamtEntry{key: key, value: cbg.Deferred{Raw: value}}); err != nil {
+		return cid.Undef, err
+	}
+	return node.put(bs)
+}
+
+func (n *hamtNode) put(bs blockstore.Blockstore) (cid.Cid, error) {
+	data, err := n.encode()
+	if err != nil {
+		return cid.Undef, err
+	}
+	return putBlock(bs, data)
+}
+
+// set sets the entry in the subtree of the node, which is at the given depth.
+func (n *hamtNode) set(bs blockstore.Blockstore, hash []byte, depth int, e hamtEntry) error {
+	if (depth+1)*hamtBitWidth > len(hash)*8 {
+		return fmt.Errorf("hamt is deeper than its hash allows")
+	}
+	bit := hashBits(hash, depth*hamtBitWidth, hamtBitWidth)
+	pos, ok := n.index(bit)
+	if !ok {
+		n.bitfield.SetBit(n.bitfield, bit, 1)
+		n.pointers = append(n.pointers, hamtPointer{})
+		copy(n.pointers[pos+1:], n.pointers[pos:])
+		n.pointers[pos] = hamtPointer{bucket: []hamtEntry{e}}
+		return nil
+	}
+
+	p := &n.pointers[pos]
+	if p.bucket == nil {
+		child, err := loadHAMTNode(bs, p.link)
+		if err != nil {
+			return err
+		}
+		if err := child.set(bs, hash, depth+1, e); err != nil {
+			return err
+		}
+		p.link, err = child.put(bs)
+		return err
+	}
+
+	for i := range p.bucket {
+		if bytes.Equal(p.bucket[i].key, e.key) {
+			p.bucket[i].value = e.value
+			return nil
+		}
+	}
+	if len(p.bucket) < hamtBucketSize {
+		i := 0
+		for i < len(p.bucket) && bytes.Compare(p.bucket[i].key, e.key) < 0 {
+			i++
+		}
+		p.bucket = append(p.bucket, hamtEntry{})
+		copy(p.bucket[i+1:], p.bucket[i:])
+		p.bucket[i] = e
+		return nil
+	}
+
+	// the bucket is full, so its entries move to a new child node.
+	child := &hamtNode{bitfield: new(big.Int), legacy: n.legacy}
+	for _, ce := range append(p.bucket, e) {
+		h := sha256.Sum256(ce.key)
+		if err := child.set(bs, h[:], depth+1, ce); err != nil {
+			return err
+		}
+	}
+	link, err := child.put(bs)
+	if err != nil {
+		return err
+	}
+	*p = hamtPointer{link: link}
+	return nil
+}
+
+// hashBits returns the n bits of h starting at bit offset, most significant
+// first, as HAMTs consume them.
+func hashBits(h []byte, offset, n int) int {
+	v := 0
+	for i := offset; i < offset+n; i++ {
+		v = v<<1 | int(h[i/8]>>(7-uint(i%8))&1)
+	}
+	return v
+}
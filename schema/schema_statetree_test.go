@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+func TestHAMTSet(t *testing.T) {
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key %d", i)
+	}
+
+	for _, legacy := range []bool{false, true} {
+		bs := blockstore.NewBlockstore(ds.NewMapDatastore())
+		build := func(keys []string) cid.Cid {
+			// the root starts with an entry, as the encoding of the pointers of
+			// empty nodes can't be told apart.
+			first := mkHAMT(t, map[string][]byte{keys[0]: cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(len(keys[0])))}, legacy)
+			root, err := putBlock(bs, []byte(first))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range keys[1:] {
+				if root, err = hamtSet(bs, root, []byte(k), cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(len(k)))); err != nil {
+					t.Fatalf("legacy=%t: setting %q: %s", legacy, k, err)
+				}
+			}
+			return root
+		}
+
+		// a few entries fit in the root, as mkHAMT encodes them.
+		expected := mkHAMT(t, map[string][]byte{"a": cbg.CborEncodeMajorType(cbg.MajUnsignedInt, 1), "b": cbg.CborEncodeMajorType(cbg.MajUnsignedInt, 1)}, legacy)
+		if root := build([]string{"b", "a"}); !root.Equals(mkCid(t, expected)) {
+			t.Fatalf("legacy=%t: unexpected root for two entries", legacy)
+		}
+
+		root := build(keys)
+		for _, k := range keys {
+			raw, found, err := hamtFind(bs, root, []byte(k))
+			if err != nil || !found || string(raw) != string(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(len(k)))) {
+				t.Fatalf("legacy=%t: expected to find %q, got: %x, %t, %v", legacy, k, raw, found, err)
+			}
+		}
+		if _, found, err := hamtFind(bs, root, []byte("missing")); err != nil || found {
+			t.Fatalf("legacy=%t: expected a missing key not to be found, got: %t, %v", legacy, found, err)
+		}
+		if node, err := loadHAMTNode(bs, root); err != nil || node.legacy != legacy {
+			t.Fatalf("legacy=%t: expected the pointer encoding to be kept, got: %v", legacy, err)
+		}
+
+		// HAMTs are canonical, so the order of insertion doesn't matter.
+		reversed := make([]string, len(keys))
+		for i, k := range keys {
+			reversed[len(keys)-1-i] = k
+		}
+		if other := build(reversed); !other.Equals(root) {
+			t.Fatalf("legacy=%t: expected the same root regardless of insertion order, got %s and %s", legacy, root, other)
+		}
+
+		// setting an existing key replaces its value.
+		updated, err := hamtSet(bs, root, []byte(keys[0]), cbg.CborNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw, _, _ := hamtFind(bs, updated, []byte(keys[0])); string(raw) != string(cbg.CborNull) {
+			t.Fatalf("legacy=%t: expected the value to be replaced, got %x", legacy, raw)
+		}
+	}
+}
+
+func TestLoadStateTreeFlush(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		data, root, _ := mkStateTree(t, legacy)
+		bs, err := TestVector{CAR: data}.loadCAR(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, err := loadStateTree(bs, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.versioned == legacy {
+			t.Fatalf("legacy=%t: unexpected state root detection", legacy)
+		}
+		if flushed, err := st.Flush(); err != nil || !flushed.Equals(root) {
+			t.Fatalf("legacy=%t: expected an unmodified tree to flush to its root, got: %s, %v", legacy, flushed, err)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package schema
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
 )
 
 func TestRandomnessCircularSerde(t *testing.T) {
@@ -48,3 +53,42 @@ func TestRandomnessCircularSerde(t *testing.T) {
 	}
 
 }
+
+func TestMarshalJSONDeterministic(t *testing.T) {
+	tv := TestVector{
+		Class:         ClassBlockSeq,
+		Selector:      Selector{},
+		ApplyBlockseq: &BlockSeq{MessageRepo: map[cid.Cid]Base64EncodedBytes{}},
+	}
+	for i := 0; i < 64; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		tv.Selector[key] = "value"
+		c, err := cid.V1Builder{Codec: cid.DagCBOR, MhType: multihash.BLAKE2B_MIN + 31}.Sum([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tv.ApplyBlockseq.MessageRepo[c] = []byte(key)
+	}
+
+	expected, err := tv.MarshalJSONDeterministic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 16; i++ {
+		actual, err := tv.MarshalJSONDeterministic()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Fatal("serialized bytes differ across runs")
+		}
+	}
+
+	var tv2 TestVector
+	if err := json.Unmarshal(expected, &tv2); err != nil {
+		t.Fatal(err)
+	}
+	if actual := tv2.MustMarshalJSON(); !bytes.Equal(expected, actual) {
+		t.Fatal("serialized bytes differ after a round trip")
+	}
+}
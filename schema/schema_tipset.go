@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+)
+
+// MessageCIDs computes the CIDs of the messages included in the block, in
+// order. The CID of a message is the blake2b-256 dag-cbor CID of its
+// serialized form, as in Filecoin.
+func (b Block) MessageCIDs() ([]cid.Cid, error) {
+	cids := make([]cid.Cid, 0, len(b.Messages))
+	for i, msg := range b.Messages {
+		c, err := cidBuilder.Sum(msg)
+		if err != nil {
+			return nil, fmt.Errorf("computing cid of message at index %d: %w", i, err)
+		}
+		cids = append(cids, c)
+	}
+	return cids, nil
+}
+
+// AllMessageCIDs computes the CIDs of the messages included in the blocks of
+// the tipset, in block order. Messages included in more than one block are
+// only listed once, at their first occurrence, mirroring how Filecoin
+// executes them.
+func (ts Tipset) AllMessageCIDs() ([]cid.Cid, error) {
+	var (
+		cids []cid.Cid
+		seen = make(map[cid.Cid]struct{})
+	)
+	for i, b := range ts.Blocks {
+		bcids, err := b.MessageCIDs()
+		if err != nil {
+			return nil, fmt.Errorf("block at index %d: %w", i, err)
+		}
+		for _, c := range bcids {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			cids = append(cids, c)
+		}
+	}
+	return cids, nil
+}
+
+// TotalWinCount returns the sum of the win counts of the blocks in the
+// tipset, which determines the block rewards paid out when it's applied.
+func (ts Tipset) TotalWinCount() int64 {
+	var total int64
+	for _, b := range ts.Blocks {
+		total += b.WinCount
+	}
+	return total
+}
+
+// MinerResolver reports whether a miner address resolves to an actor in the
+// precondition state tree of a vector. Implementations supply a resolver
+// backed by their own state tree types, loaded from the vector's CAR, so that
+// miners are resolved exactly as the implementation resolves them.
+type MinerResolver func(addr address.Address) (bool, error)
+
+// ValidateMiners is a stricter check on top of Validate, which verifies that
+// the miner of every block in the tipsets to apply resolves, according to the
+// supplied resolver.
+func (tv TestVector) ValidateMiners(resolve MinerResolver) error {
+	for i, ts := range tv.ApplyTipsets {
+		for j, b := range ts.Blocks {
+			ok, err := resolve(b.MinerAddr)
+			if err != nil {
+				return fmt.Errorf("resolving miner %s of block at index %d in tipset at index %d: %w", b.MinerAddr, j, i, err)
+			}
+			if !ok {
+				return fmt.Errorf("miner %s of block at index %d in tipset at index %d is not present in the precondition state tree", b.MinerAddr, j, i)
+			}
+		}
+	}
+	return nil
+}
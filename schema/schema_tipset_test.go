@@ -0,0 +1,65 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+)
+
+func TestTipsetMessageCIDs(t *testing.T) {
+	ts := Tipset{Blocks: []Block{
+		{Messages: []Base64EncodedBytes{[]byte("a"), []byte("b")}},
+		{Messages: []Base64EncodedBytes{[]byte("c"), []byte("a")}},
+		{},
+	}}
+
+	cids, err := ts.Blocks[1].MessageCIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []cid.Cid{mkCid(t, "c"), mkCid(t, "a")}; !reflect.DeepEqual(cids, expected) {
+		t.Fatalf("expected %v, got %v", expected, cids)
+	}
+
+	all, err := ts.AllMessageCIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []cid.Cid{mkCid(t, "a"), mkCid(t, "b"), mkCid(t, "c")}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("expected %v, got %v", expected, all)
+	}
+}
+
+func TestTipsetTotalWinCount(t *testing.T) {
+	ts := Tipset{Blocks: []Block{{WinCount: 1}, {WinCount: 3}}}
+	if total := ts.TotalWinCount(); total != 4 {
+		t.Fatalf("expected a total win count of 4, got %d", total)
+	}
+	if total := (Tipset{}).TotalWinCount(); total != 0 {
+		t.Fatalf("expected a total win count of 0, got %d", total)
+	}
+}
+
+func TestValidateMiners(t *testing.T) {
+	var (
+		known, _   = address.NewIDAddress(1000)
+		unknown, _ = address.NewIDAddress(1001)
+		resolve    = func(addr address.Address) (bool, error) { return addr == known, nil }
+	)
+	tv := TestVector{ApplyTipsets: []Tipset{
+		{Blocks: []Block{{MinerAddr: known}}},
+		{Blocks: []Block{{MinerAddr: known}, {MinerAddr: unknown}}},
+	}}
+	err := tv.ValidateMiners(resolve)
+	if err == nil || !strings.Contains(err.Error(), "miner t01001 of block at index 1 in tipset at index 1 is not present") {
+		t.Fatalf("expected an unresolved miner error, got: %v", err)
+	}
+
+	tv.ApplyTipsets = tv.ApplyTipsets[:1]
+	if err := tv.ValidateMiners(resolve); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,113 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// FilecoinPrecision is the number of attoFIL in a FIL.
+	FilecoinPrecision = uint64(1_000_000_000_000_000_000)
+
+	// TotalFilecoin is the maximum supply of Filecoin that will ever exist,
+	// in FIL.
+	TotalFilecoin = uint64(2_000_000_000)
+)
+
+// TotalFilecoinAmount returns TotalFilecoin as a TokenAmount of attoFIL.
+func TotalFilecoinAmount() TokenAmount {
+	v := new(big.Int).SetUint64(TotalFilecoin)
+	return TokenAmount{i: v.Mul(v, new(big.Int).SetUint64(FilecoinPrecision))}
+}
+
+// TokenAmount is an amount of attoFIL, backed by an arbitrary precision
+// integer. It must be interpreted by the driver as an abi.TokenAmount in
+// Lotus, or equivalent type in other implementations. The zero value is zero,
+// though it's told apart from an explicit zero where the schema makes amounts
+// optional, e.g. the base fee of tipsets (see IsSet).
+//
+// TokenAmounts are serialized in JSON as strings holding their decimal
+// representation, as realistic amounts overflow the integers most JSON
+// decoders handle, and unset amounts as null, so that they remain unset once
+// unmarshalled. Older vectors encoded them as plain JSON numbers; those are
+// still accepted when unmarshalling.
+type TokenAmount struct {
+	i *big.Int
+}
+
+// NewTokenAmount returns a TokenAmount of the given number of attoFIL.
+func NewTokenAmount(v int64) TokenAmount {
+	return TokenAmount{i: big.NewInt(v)}
+}
+
+// NewTokenAmountFromBig returns a TokenAmount of the given number of attoFIL.
+// The amount doesn't share memory with v. A nil v yields the zero value.
+func NewTokenAmountFromBig(v *big.Int) TokenAmount {
+	if v == nil {
+		return TokenAmount{}
+	}
+	return TokenAmount{i: new(big.Int).Set(v)}
+}
+
+// BigInt returns the amount as a big.Int. It returns nil if the TokenAmount
+// itself is nil, so that absent optional amounts remain absent.
+func (t *TokenAmount) BigInt() *big.Int {
+	switch {
+	case t == nil:
+		return nil
+	case t.i == nil:
+		return new(big.Int)
+	}
+	return t.i
+}
+
+// IsSet reports whether the amount was set, by a constructor or by
+// unmarshalling, rather than being the zero value.
+func (t TokenAmount) IsSet() bool {
+	return t.i != nil
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the amount.
+func (t TokenAmount) Sign() int {
+	return t.BigInt().Sign()
+}
+
+// Cmp compares the amount with o, returning -1, 0 or +1 as it's less than,
+// equal to, or greater than o.
+func (t TokenAmount) Cmp(o TokenAmount) int {
+	return t.BigInt().Cmp(o.BigInt())
+}
+
+// String returns the decimal representation of the amount.
+func (t TokenAmount) String() string {
+	return t.BigInt().String()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t TokenAmount) MarshalJSON() ([]byte, error) {
+	if !t.IsSet() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts both decimal strings
+// and plain JSON numbers.
+func (t *TokenAmount) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	switch {
+	case s == "null":
+		return nil
+	case len(b) > 0 && b[0] == '"':
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("invalid token amount: %s", b)
+	}
+	t.i = i
+	return nil
+}
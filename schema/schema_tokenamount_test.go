@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestTokenAmountJSON(t *testing.T) {
+	const supply = "1000000000000000000000000000" // 1bn FIL, overflows int64.
+
+	var pre Preconditions
+	if err := json.Unmarshal([]byte(`{"variants":[],"basefee":100,"circ_supply":"`+supply+`"}`), &pre); err != nil {
+		t.Fatal(err)
+	}
+	if s := pre.CircSupply.String(); s != supply {
+		t.Fatalf("expected circulating supply %s, got %s", supply, s)
+	}
+	if s := pre.BaseFee.String(); s != "100" {
+		t.Fatalf("expected legacy numeric base fee to decode as 100, got %s", s)
+	}
+
+	b, err := json.Marshal(pre)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"variants":[],"basefee":"100","circ_supply":"` + supply + `"}`; string(b) != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// unset amounts are serialized as null, and explicit zeros as zero.
+	if b, _ := json.Marshal(Tipset{}); string(b) != `{"epoch_offset":0,"basefee":null}` {
+		t.Fatalf("unexpected zero tipset encoding: %s", b)
+	}
+	if b, _ := json.Marshal(Tipset{BaseFee: NewTokenAmount(0)}); string(b) != `{"epoch_offset":0,"basefee":"0"}` {
+		t.Fatalf("unexpected zero base fee encoding: %s", b)
+	}
+
+	var amt TokenAmount
+	for _, invalid := range []string{`"1.5"`, `1e21`, `"abc"`, `true`} {
+		if err := json.Unmarshal([]byte(invalid), &amt); err == nil {
+			t.Errorf("expected %s to be rejected", invalid)
+		}
+	}
+	if amt.BigInt().Sign() != 0 || (*TokenAmount)(nil).BigInt() != nil {
+		t.Fatal("unexpected BigInt values")
+	}
+}
+
+func TestTokenAmountZeroValue(t *testing.T) {
+	var zero TokenAmount
+	if zero.IsSet() || zero.Sign() != 0 || zero.String() != "0" || zero.Cmp(NewTokenAmount(0)) != 0 {
+		t.Fatalf("expected the zero value to be an unset zero, got %s", zero)
+	}
+	if zero.Cmp(NewTokenAmount(1)) >= 0 || NewTokenAmount(-1).Sign() >= 0 {
+		t.Fatal("unexpected comparison results")
+	}
+	if !NewTokenAmount(0).IsSet() || NewTokenAmountFromBig(nil).IsSet() {
+		t.Fatal("unexpected IsSet results")
+	}
+}
+
+func TestTokenAmountRoundTripIsSet(t *testing.T) {
+	tv := fullTestVector(t)
+	tv.ApplyTipsets = append(tv.ApplyTipsets, Tipset{EpochOffset: 4, Blocks: tv.ApplyTipsets[0].Blocks})
+	tv.ApplyTipsets[0].BaseFee = NewTokenAmount(0)
+	if err := AssertRoundTrip(tv); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded TestVector
+	if err := json.Unmarshal(tv.MustMarshalJSON(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tv.EncodeCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fromCBOR, err := DecodeCBOR(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range map[string]*TestVector{"json": &decoded, "cbor": fromCBOR} {
+		if !v.ApplyTipsets[0].BaseFee.IsSet() || v.ApplyTipsets[1].BaseFee.IsSet() {
+			t.Errorf("%s: expected only the explicit zero base fee to be set, got %v", name, v.ApplyTipsets)
+		}
+	}
+
+	// an unset amount is told apart from an explicit zero.
+	tv.ApplyTipsets[0].BaseFee = TokenAmount{}
+	if err := AssertRoundTrip(tv); err != nil {
+		t.Fatal(err)
+	}
+	other := tv.Clone()
+	other.ApplyTipsets[0].BaseFee = NewTokenAmount(0)
+	if tv.Equal(other) {
+		t.Fatal("expected an unset base fee to differ from a zero one")
+	}
+}
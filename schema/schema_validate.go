@@ -0,0 +1,588 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+// ValidateOptions determine which validation rules ValidateWithOptions
+// applies, on top of the rules that are always applied.
+type ValidateOptions struct {
+	// RequireGenData requires the vector to record its provenance in its
+	// generation metadata (see Metadata.ValidateGen with GenRequireSource).
+	RequireGenData bool
+
+	// CheckCARReachability requires every root referenced by the vector,
+	// including receipts and events roots, to be present in its CAR (see
+	// ValidateCARReachability). It needs to decode the CAR, so it's costly.
+	CheckCARReachability bool
+
+	// RejectUnknownHints rejects hints that are neither standard, nor carry
+	// the HintVendorPrefix.
+	RejectUnknownHints bool
+
+	// AllowLegacyCircSupply skips the bounds check on the circulating
+	// supply, which vectors produced before it was introduced may fail.
+	AllowLegacyCircSupply bool
+
+	// SkipCARChecksum skips checking the CAR against the CARChecksum in the
+	// metadata, e.g. for vectors whose CAR is not held in memory (see
+	// LazyTestVector).
+	SkipCARChecksum bool
+
+	// MaxCARBytes, MaxMessages and MaxTipsets, if non-zero, are the budgets
+	// the vector must fit in: the size of its CARs as stored, i.e. gzipped,
+	// the number of messages it applies, and the number of tipsets it applies
+	// (see TestVector.MessageCount). They let suites bound the cost of running
+	// their vectors.
+	MaxCARBytes int
+	MaxMessages int
+	MaxTipsets  int
+
+	// Logger, if set, is called with a printf-style message at the start of
+	// each major check, naming the vector and the check, to trace slow or
+	// failing validations (e.g. with log.Printf).
+	Logger func(format string, args ...interface{})
+}
+
+// logCheck reports the start of a check of the vector to the Logger, if any.
+func (opts ValidateOptions) logCheck(tv *TestVector, check string) {
+	if opts.Logger == nil {
+		return
+	}
+	id := "<no id>"
+	if tv.Meta != nil && tv.Meta.ID != "" {
+		id = tv.Meta.ID
+	}
+	opts.Logger("validating test vector %s: checking %s", id, check)
+}
+
+// DefaultValidateOptions returns the options Validate uses: unknown hints are
+// rejected, and every other option is off.
+func DefaultValidateOptions() ValidateOptions {
+	return ValidateOptions{RejectUnknownHints: true}
+}
+
+// Validate applies the validation rules that cannot be enforced through JSON
+// Schema, with the DefaultValidateOptions. Use SchemaValidate to check the
+// serialized form of a vector against the JSON Schema itself.
+func (tv TestVector) Validate() error {
+	return tv.ValidateWithOptions(DefaultValidateOptions())
+}
+
+// ValidateWithOptions is like Validate, but it applies the optional rules
+// selected by opts.
+func (tv TestVector) ValidateWithOptions(opts ValidateOptions) error {
+	if opts.RejectUnknownHints {
+		opts.logCheck(&tv, "hints")
+		for _, h := range tv.Hints {
+			if !h.IsKnown() {
+				return fmt.Errorf("unknown hint %q; non-standard hints must carry the %q prefix", h, HintVendorPrefix)
+			}
+		}
+	}
+
+	if opts.RequireGenData {
+		opts.logCheck(&tv, "generation data")
+		if err := tv.Meta.ValidateGen(GenRequireSource); err != nil {
+			return err
+		}
+	}
+	if tv.Meta != nil {
+		opts.logCheck(&tv, "metadata")
+		if err := tv.Meta.validateID(); err != nil {
+			return err
+		}
+		if err := tv.Meta.validateRelated(); err != nil {
+			return err
+		}
+	}
+	if opts.MaxCARBytes > 0 || opts.MaxMessages > 0 || opts.MaxTipsets > 0 {
+		opts.logCheck(&tv, "budget")
+		if err := tv.validateBudget(opts); err != nil {
+			return err
+		}
+	}
+	if len(tv.CARs) > 0 {
+		opts.logCheck(&tv, "named cars")
+		if err := tv.validateCARs(); err != nil {
+			return err
+		}
+	}
+	if !opts.SkipCARChecksum {
+		opts.logCheck(&tv, "car checksum")
+		if err := tv.VerifyCARChecksum(); err != nil {
+			return err
+		}
+	}
+
+	if tv.Pre != nil && tv.Pre.NetworkVersion != nil {
+		opts.logCheck(&tv, "network version")
+		if err := tv.validateNetworkVersion(); err != nil {
+			return err
+		}
+	}
+
+	opts.logCheck(&tv, "amounts")
+	if err := tv.validateAmounts(opts.AllowLegacyCircSupply); err != nil {
+		return err
+	}
+
+	opts.logCheck(&tv, "root cids")
+	if err := tv.validateRootCIDs(); err != nil {
+		return err
+	}
+
+	opts.logCheck(&tv, "partial state")
+	if err := tv.validatePartialState(); err != nil {
+		return err
+	}
+
+	opts.logCheck(&tv, "state diff")
+	if err := tv.validateStateDiff(); err != nil {
+		return err
+	}
+
+	opts.logCheck(&tv, "custom checks")
+	if err := tv.validateCustomChecks(); err != nil {
+		return err
+	}
+
+	opts.logCheck(&tv, fmt.Sprintf("%s class rules", tv.Class))
+	if err := tv.validateClass(); err != nil {
+		return err
+	}
+
+	if opts.CheckCARReachability {
+		opts.logCheck(&tv, "car reachability")
+		return tv.ValidateCARReachability()
+	}
+	return nil
+}
+
+// BatchError is the validation error of a vector in a batch validated by
+// ValidateBatch.
+type BatchError struct {
+	// Index is the index of the vector within the batch.
+	Index int
+	// ID is the metadata ID of the vector, if any.
+	ID  string
+	Err error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("vector %q at index %d: %s", e.ID, e.Index, e.Err)
+}
+
+func (e BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateBatch validates every vector in the batch, and returns the errors
+// of all the invalid ones, in batch order, rather than stopping at the first.
+// It returns no errors if all vectors are valid.
+func ValidateBatch(vs []*TestVector) []BatchError {
+	var errs []BatchError
+	for i, tv := range vs {
+		if tv == nil {
+			errs = append(errs, BatchError{Index: i, Err: fmt.Errorf("nil vector")})
+			continue
+		}
+		if err := tv.Validate(); err != nil {
+			e := BatchError{Index: i, Err: err}
+			if tv.Meta != nil {
+				e.ID = tv.Meta.ID
+			}
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// validateClass applies the validation rules that depend on the class of the
+// vector.
+func (tv TestVector) validateClass() error {
+	if err := tv.validateApplyFields(); err != nil {
+		return err
+	}
+	if tv.Class != ClassMessage && len(tv.NullRounds) > 0 {
+		return fmt.Errorf("only message vectors can have null rounds, got a %s vector", tv.Class)
+	}
+	switch tv.Class {
+	case ClassMessage:
+		if tv.Post == nil {
+			return fmt.Errorf("message vectors must have postconditions")
+		}
+		if len(tv.Post.Receipts) != len(tv.ApplyMessages) {
+			return fmt.Errorf("length of postcondition receipts must match length of messages to apply")
+		}
+		if err := tv.validateApplyMessageFailures(); err != nil {
+			return err
+		}
+		if err := tv.validateMessageEpochs(); err != nil {
+			return err
+		}
+		if err := tv.validateMessageSignatures(); err != nil {
+			return err
+		}
+	case ClassTipset:
+		if err := tv.validateTipsets(); err != nil {
+			return err
+		}
+	case ClassBlockSeq:
+		return tv.validateBlockSeq()
+	}
+	if err := tv.validateReceiptsRoots(); err != nil {
+		return err
+	}
+	return tv.validateReceipts()
+}
+
+// validateApplyFields checks that, of the apply_* fields, the vector only
+// populates the one its class calls for.
+func (tv TestVector) validateApplyFields() error {
+	switch tv.Class {
+	case ClassMessage, ClassTipset, ClassBlockSeq:
+	default:
+		return nil
+	}
+	fields := []struct {
+		name  string
+		class Class
+		set   bool
+	}{
+		{"apply_messages", ClassMessage, len(tv.ApplyMessages) > 0},
+		{"apply_tipsets", ClassTipset, len(tv.ApplyTipsets) > 0},
+		{"apply_blockseq", ClassBlockSeq, tv.ApplyBlockseq != nil},
+	}
+	for _, f := range fields {
+		if f.set && f.class != tv.Class {
+			return fmt.Errorf("%s vectors must not carry %s, which is reserved for %s vectors", tv.Class, f.name, f.class)
+		}
+	}
+	return nil
+}
+
+// validateApplyMessageFailures checks that the indices of expected failures
+// point to messages to apply, and that none is repeated.
+func (tv TestVector) validateApplyMessageFailures() error {
+	seen := make(map[int]struct{}, len(tv.Post.ApplyMessageFailures))
+	for _, idx := range tv.Post.ApplyMessageFailures {
+		if idx < 0 || idx >= len(tv.ApplyMessages) {
+			return fmt.Errorf("apply message failure index %d out of range; vector has %d messages to apply", idx, len(tv.ApplyMessages))
+		}
+		if _, ok := seen[idx]; ok {
+			return fmt.Errorf("duplicate apply message failure index %d", idx)
+		}
+		seen[idx] = struct{}{}
+	}
+	return nil
+}
+
+// validateMessageEpochs checks that messages are applied in epoch order, and
+// that null rounds are strictly increasing, and hold no messages.
+func (tv TestVector) validateMessageEpochs() error {
+	for i := 1; i < len(tv.ApplyMessages); i++ {
+		if prev, cur := tv.ApplyMessages[i-1].epochOffset(), tv.ApplyMessages[i].epochOffset(); cur < prev {
+			return fmt.Errorf("message at index %d has epoch offset %d, which precedes the epoch offset %d of the previous message", i, cur, prev)
+		}
+	}
+	for i, r := range tv.NullRounds {
+		if i > 0 && r <= tv.NullRounds[i-1] {
+			return fmt.Errorf("null round at index %d has epoch offset %d; null rounds must be strictly increasing", i, r)
+		}
+	}
+	for i, m := range tv.ApplyMessages {
+		if tv.IsNullRound(m.epochOffset()) {
+			return fmt.Errorf("message at index %d is applied at epoch offset %d, which is a null round", i, m.epochOffset())
+		}
+	}
+	return nil
+}
+
+// validateMessageSignatures checks that message signatures are of a known
+// type, and that sender overrides are actual addresses. Signatures are not
+// verified, as vectors may purposely carry invalid ones.
+func (tv TestVector) validateMessageSignatures() error {
+	for i, m := range tv.ApplyMessages {
+		if sig := m.Signature; sig != nil && sig.Type != SignatureSecp256k1 && sig.Type != SignatureBLS {
+			return fmt.Errorf("message at index %d has signature of unknown type %q; expected %q or %q", i, sig.Type, SignatureSecp256k1, SignatureBLS)
+		}
+		if m.From != nil && *m.From == address.Undef {
+			return fmt.Errorf("message at index %d has an empty sender override", i)
+		}
+	}
+	return nil
+}
+
+// validateReceiptsRoots checks the number of receipts roots, if any, against
+// the class of the vector (see Postconditions.ReceiptsRoots), that the root
+// of message vectors commits to their receipts (see ComputeReceiptsRoot), and
+// that receipts only carry an events root alongside events.
+func (tv TestVector) validateReceiptsRoots() error {
+	if tv.Post == nil {
+		return nil
+	}
+	for i, r := range tv.Post.Receipts {
+		if r != nil && r.EventsRoot != nil && len(r.Events) == 0 {
+			return fmt.Errorf("receipt at index %d has an events root, but no events", i)
+		}
+	}
+	if len(tv.Post.ReceiptsRoots) == 0 {
+		return nil
+	}
+	switch n := len(tv.Post.ReceiptsRoots); tv.Class {
+	case ClassMessage:
+		if n != 1 {
+			return fmt.Errorf("message vectors must have a single receipts root, got %d", n)
+		}
+		computed, err := tv.Post.ComputeReceiptsRoot()
+		if err != nil {
+			return fmt.Errorf("computing receipts root: %w", err)
+		}
+		if !tv.Post.ReceiptsRoots[0].Equals(computed) {
+			return fmt.Errorf("receipts root %s doesn't match the receipts, whose root is %s", tv.Post.ReceiptsRoots[0], computed)
+		}
+	case ClassTipset:
+		if n != len(tv.ApplyTipsets) {
+			return fmt.Errorf("tipset vectors must have one receipts root per tipset; got %d roots for %d tipsets", n, len(tv.ApplyTipsets))
+		}
+	}
+	return nil
+}
+
+// validateRootCIDs checks that the state tree and receipts roots are CIDs of
+// dag-cbor blocks, hashed with blake2b-256 like every Filecoin object, as
+// blocks of any other form can't be part of the state, and roots of the
+// wrong form point at a vector built from the wrong CID prefix.
+func (tv TestVector) validateRootCIDs() error {
+	type root struct {
+		name string
+		c    cid.Cid
+	}
+	var roots []root
+	if tv.Pre != nil && tv.Pre.StateTree != nil {
+		roots = append(roots, root{"preconditions state_tree root_cid", tv.Pre.StateTree.RootCID})
+	}
+	if tv.Post != nil {
+		if tv.Post.StateTree != nil {
+			roots = append(roots, root{"postconditions state_tree root_cid", tv.Post.StateTree.RootCID})
+		}
+		for i, c := range tv.Post.ReceiptsRoots {
+			roots = append(roots, root{fmt.Sprintf("postconditions receipts_roots[%d]", i), c})
+		}
+	}
+
+	for _, r := range roots {
+		if !r.c.Defined() {
+			continue
+		}
+		p := r.c.Prefix()
+		if p.Codec != cidBuilder.Codec {
+			return fmt.Errorf("%s has codec %s, expected %s", r.name, codecName(p.Codec), codecName(cidBuilder.Codec))
+		}
+		if p.MhType != cidBuilder.MhType || p.MhLength != 32 {
+			return fmt.Errorf("%s has multihash %s of %d bytes, expected %s of 32 bytes", r.name, multihash.Codes[p.MhType], p.MhLength, multihash.Codes[cidBuilder.MhType])
+		}
+	}
+	return nil
+}
+
+// codecName returns the multicodec name of the codec.
+func codecName(c uint64) string {
+	switch c {
+	case cid.DagCBOR:
+		return "dag-cbor"
+	case cid.DagProtobuf:
+		return "dag-pb"
+	}
+	if name, ok := cid.CodecToStr[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", c)
+}
+
+// validateAmounts checks that the base fee, if set, is not negative, and
+// that the circulating supply, if set, is within zero and TotalFilecoin,
+// unless legacy circulating supplies are allowed.
+func (tv TestVector) validateAmounts(allowLegacyCircSupply bool) error {
+	if tv.Pre == nil {
+		return nil
+	}
+	if fee := tv.Pre.BaseFee.BigInt(); fee != nil && fee.Sign() < 0 {
+		return fmt.Errorf("base fee must not be negative, got %s attoFIL", fee)
+	}
+	if tv.Pre.CircSupply == nil || allowLegacyCircSupply {
+		return nil
+	}
+	supply := tv.Pre.CircSupply.BigInt()
+	if supply.Sign() < 0 {
+		return fmt.Errorf("circulating supply must not be negative, got %s attoFIL", supply)
+	}
+	if max := TotalFilecoinAmount(); supply.Cmp(max.BigInt()) > 0 {
+		return fmt.Errorf("circulating supply %s attoFIL exceeds the total supply of %s attoFIL", supply, max)
+	}
+	return nil
+}
+
+// validateReceipts checks that receipts carry exit codes the VM could
+// produce. Exit codes are never negative; codes below
+// ExitFirstActorErrorCode are system codes, and anything above is actor
+// defined. Gas used tolerances can't be negative either. Vectors hinted as
+// incorrect are exempt from these assertions, as they may purposely assert
+// garbage, but their receipts must still be well-formed: only failed messages
+// can carry error messages, and gas breakdowns must add up to the gas used.
+func (tv TestVector) validateReceipts() error {
+	if tv.Post == nil {
+		return nil
+	}
+	incorrect := tv.HasHint(HintIncorrect)
+	for i, r := range tv.Post.Receipts {
+		if r == nil {
+			continue
+		}
+		if r.ErrorMessage != "" && r.ExitCode == ExitOK {
+			return fmt.Errorf("receipt at index %d has an error message, but exit code %s", i, r.ExitCode)
+		}
+		if len(r.GasBreakdown) > 0 {
+			if err := r.validateGasBreakdown(); err != nil {
+				return fmt.Errorf("receipt at index %d: %w", i, err)
+			}
+		}
+		if incorrect {
+			continue
+		}
+		if r.ExitCode < ExitOK {
+			return fmt.Errorf("receipt at index %d has negative exit code %d", i, r.ExitCode)
+		}
+		if r.GasUsedTolerance < 0 {
+			return fmt.Errorf("receipt at index %d has negative gas used tolerance %d", i, r.GasUsedTolerance)
+		}
+	}
+	return nil
+}
+
+// validateGasBreakdown checks that the components of the gas breakdown are
+// named, not negative, and add up to the gas used.
+func (r Receipt) validateGasBreakdown() error {
+	for name, g := range r.GasBreakdown {
+		if name == "" {
+			return fmt.Errorf("gas breakdown has a component without a name")
+		}
+		if g < 0 {
+			return fmt.Errorf("gas breakdown component %q is negative: %d", name, g)
+		}
+	}
+	if total := r.GasBreakdownTotal(); total != r.GasUsed {
+		return fmt.Errorf("gas breakdown adds up to %d, but gas used is %d", total, r.GasUsed)
+	}
+	return nil
+}
+
+// validateTipsets applies the validation rules specific to tipset-class
+// vectors.
+func (tv TestVector) validateTipsets() error {
+	if len(tv.ApplyTipsets) == 0 {
+		return fmt.Errorf("tipset vectors must have at least one tipset to apply")
+	}
+	for i, ts := range tv.ApplyTipsets {
+		if !ts.BaseFee.IsSet() && tv.beyondGenesis(ts.EpochOffset) {
+			return fmt.Errorf("tipset at index %d has no base fee; it's required for tipsets beyond genesis", i)
+		}
+		if ts.BaseFee.BigInt().Sign() < 0 {
+			return fmt.Errorf("tipset at index %d has negative base fee %s", i, ts.BaseFee)
+		}
+		if len(ts.Blocks) == 0 {
+			return fmt.Errorf("tipset at index %d has no blocks", i)
+		}
+		for j, b := range ts.Blocks {
+			if b.WinCount <= 0 {
+				return fmt.Errorf("block at index %d in tipset at index %d has non-positive win count %d", j, i, b.WinCount)
+			}
+			switch p := b.MinerAddr.Protocol(); {
+			case b.MinerAddr == address.Undef:
+				return fmt.Errorf("block at index %d in tipset at index %d has no miner address", j, i)
+			case p != address.ID && p != address.Actor:
+				return fmt.Errorf("block at index %d in tipset at index %d has miner address %s, which is neither an id nor an actor address", j, i, b.MinerAddr)
+			}
+		}
+	}
+	return tv.validateExpectedStateRoots()
+}
+
+// validateExpectedStateRoots checks that the tipsets of the vector carry an
+// expected state root either all or none of them, and that the last one is
+// the root of the postcondition state tree.
+func (tv TestVector) validateExpectedStateRoots() error {
+	n := 0
+	for _, ts := range tv.ApplyTipsets {
+		if ts.ExpectedStateRoot != nil {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	for i, ts := range tv.ApplyTipsets {
+		if ts.ExpectedStateRoot == nil {
+			return fmt.Errorf("tipset at index %d has no expected state root; it's required on every tipset when any carries one", i)
+		}
+	}
+	last := *tv.ApplyTipsets[len(tv.ApplyTipsets)-1].ExpectedStateRoot
+	if tv.Post == nil || tv.Post.StateTree == nil {
+		return fmt.Errorf("tipsets carry expected state roots, but there's no postcondition state tree for the last one to match")
+	}
+	if !last.Equals(tv.Post.StateTree.RootCID) {
+		return fmt.Errorf("expected state root %s of the last tipset doesn't match the postcondition state tree root %s", last, tv.Post.StateTree.RootCID)
+	}
+	return nil
+}
+
+// beyondGenesis reports whether the epoch offset is past the genesis epoch in
+// any of the variants of the vector.
+func (tv TestVector) beyondGenesis(offset int64) bool {
+	if tv.Pre == nil || len(tv.Pre.Variants) == 0 {
+		return offset > 0
+	}
+	for _, v := range tv.Pre.Variants {
+		if v.Epoch+offset > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBlockSeq applies the validation rules specific to blockseq-class
+// vectors.
+func (tv TestVector) validateBlockSeq() error {
+	if tv.Pre == nil || tv.Pre.PreconditionsBlockSeq == nil {
+		return fmt.Errorf("blockseq vectors must have blockseq preconditions")
+	}
+	if tv.Pre.GenesisTs == 0 {
+		return fmt.Errorf("blockseq vectors must have a genesis timestamp")
+	}
+	if tv.ApplyBlockseq == nil {
+		return fmt.Errorf("blockseq vectors must have a block sequence to apply")
+	}
+	if len(tv.ApplyBlockseq.Blocks) == 0 {
+		return fmt.Errorf("blockseq vectors must have at least one block to apply")
+	}
+	var prev OffsetMillis
+	for i, b := range tv.ApplyBlockseq.Blocks {
+		if len(b.Bytes) == 0 {
+			return fmt.Errorf("block at index %d has no bytes", i)
+		}
+		if err := b.OffsetMs.check(); err != nil {
+			return fmt.Errorf("block at index %d: %w", i, err)
+		}
+		if b.OffsetMs < prev {
+			return fmt.Errorf("block at index %d arrives at offset %s, before the previous block at offset %s", i, time.Duration(b.OffsetMs), time.Duration(prev))
+		}
+		prev = b.OffsetMs
+	}
+	return nil
+}
@@ -0,0 +1,524 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+func TestValidateTipset(t *testing.T) {
+	var (
+		miner, _  = address.NewIDAddress(1000)
+		robust, _ = address.NewSecp256k1Address([]byte("pubkey"))
+		block     = Block{MinerAddr: miner, WinCount: 1}
+		mid       = mkCid(t, "mid")
+		final     = mkCid(t, "final")
+	)
+	cases := []struct {
+		name string
+		tv   TestVector
+		err  string
+	}{
+		{
+			name: "ok",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block}}}},
+		},
+		{
+			name: "no tipsets",
+			tv:   TestVector{Class: ClassTipset},
+			err:  "at least one tipset",
+		},
+		{
+			name: "no blocks",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block}}, {}}},
+			err:  "tipset at index 1 has no blocks",
+		},
+		{
+			name: "zero win count",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block, {}}}}},
+			err:  "block at index 1 in tipset at index 0 has non-positive win count",
+		},
+		{
+			name: "no miner address",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block, {WinCount: 1}}}}},
+			err:  "block at index 1 in tipset at index 0 has no miner address",
+		},
+		{
+			name: "robust miner address",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{{MinerAddr: robust, WinCount: 1}}}}},
+			err:  "neither an id nor an actor address",
+		},
+		{
+			name: "no base fee beyond genesis",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block}}, {EpochOffset: 1, Blocks: []Block{block}}}},
+			err:  "tipset at index 1 has no base fee",
+		},
+		{
+			name: "no base fee beyond genesis in a variant",
+			tv: TestVector{
+				Class:        ClassTipset,
+				Pre:          &Preconditions{Variants: []Variant{{ID: "genesis"}, {ID: "later", Epoch: 100}}},
+				ApplyTipsets: []Tipset{{Blocks: []Block{block}}},
+			},
+			err: "tipset at index 0 has no base fee",
+		},
+		{
+			name: "negative base fee",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{EpochOffset: 1, BaseFee: NewTokenAmount(-1), Blocks: []Block{block}}}},
+			err:  "tipset at index 0 has negative base fee -1",
+		},
+		{
+			name: "expected state roots",
+			tv: TestVector{
+				Class:        ClassTipset,
+				ApplyTipsets: []Tipset{{Blocks: []Block{block}, ExpectedStateRoot: &mid}, {Blocks: []Block{block}, ExpectedStateRoot: &final}},
+				Post:         &Postconditions{StateTree: &StateTree{RootCID: final}},
+			},
+		},
+		{
+			name: "missing expected state root",
+			tv: TestVector{
+				Class:        ClassTipset,
+				ApplyTipsets: []Tipset{{Blocks: []Block{block}}, {Blocks: []Block{block}, ExpectedStateRoot: &final}},
+				Post:         &Postconditions{StateTree: &StateTree{RootCID: final}},
+			},
+			err: "tipset at index 0 has no expected state root",
+		},
+		{
+			name: "last expected state root mismatch",
+			tv: TestVector{
+				Class:        ClassTipset,
+				ApplyTipsets: []Tipset{{Blocks: []Block{block}, ExpectedStateRoot: &final}, {Blocks: []Block{block}, ExpectedStateRoot: &mid}},
+				Post:         &Postconditions{StateTree: &StateTree{RootCID: final}},
+			},
+			err: "expected state root " + mid.String() + " of the last tipset doesn't match",
+		},
+		{
+			name: "expected state roots without a postcondition state tree",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block}, ExpectedStateRoot: &final}}},
+			err:  "no postcondition state tree",
+		},
+		{
+			name: "stray messages",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block}}}, ApplyMessages: []Message{{}}},
+			err:  "tipset vectors must not carry apply_messages",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.tv.Validate()
+			switch {
+			case c.err == "" && err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			case c.err != "" && err == nil:
+				t.Fatalf("expected error containing %q", c.err)
+			case c.err != "" && !strings.Contains(err.Error(), c.err):
+				t.Fatalf("expected error containing %q, got: %s", c.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateBlockSeq(t *testing.T) {
+	pre := &Preconditions{PreconditionsBlockSeq: &PreconditionsBlockSeq{GenesisTs: 1598306400}}
+	blocks := func(offsets ...int64) *BlockSeq {
+		bs := new(BlockSeq)
+		for _, ms := range offsets {
+			o := OffsetMillis(time.Duration(ms) * time.Millisecond)
+			bs.Blocks = append(bs.Blocks, TimestampedRawBlock{OffsetMs: o, Bytes: []byte{0x83}})
+		}
+		return bs
+	}
+
+	tv := TestVector{Class: ClassBlockSeq, Pre: pre, ApplyBlockseq: blocks(0, 1000, 1000, 2000)}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tv.ApplyBlockseq = blocks(0, 2000, 1000)
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "block at index 2 arrives at offset 1s") {
+		t.Fatalf("expected ordering error, got: %v", err)
+	}
+
+	tv.ApplyBlockseq = blocks(0)
+	tv.ApplyBlockseq.Blocks[0].Bytes = nil
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "block at index 0 has no bytes") {
+		t.Fatalf("expected empty bytes error, got: %v", err)
+	}
+
+	tv.ApplyBlockseq = blocks(0)
+	tv.ApplyTipsets = []Tipset{{}}
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "blockseq vectors must not carry apply_tipsets, which is reserved for tipset vectors") {
+		t.Fatalf("expected a mismatched apply field error, got: %v", err)
+	}
+
+	tv.ApplyTipsets = nil
+	tv.Pre = &Preconditions{}
+	if err := tv.Validate(); err == nil {
+		t.Fatal("expected error for missing blockseq preconditions")
+	}
+
+	msg := TestVector{Class: ClassMessage, ApplyBlockseq: blocks(0), Post: &Postconditions{}}
+	if err := msg.Validate(); err == nil || !strings.Contains(err.Error(), "message vectors must not carry apply_blockseq") {
+		t.Fatalf("expected a mismatched apply field error, got: %v", err)
+	}
+}
+
+func TestValidateMessageSignatures(t *testing.T) {
+	from, _ := address.NewSecp256k1Address([]byte("pubkey"))
+	tv := TestVector{
+		Class: ClassMessage,
+		ApplyMessages: []Message{
+			{Bytes: []byte("msg"), Signature: &Signature{Type: SignatureSecp256k1, Data: []byte("sig")}, From: &from},
+			{Bytes: []byte("msg"), Signature: &Signature{Type: SignatureBLS, Data: []byte("sig")}},
+			{Bytes: []byte("msg")},
+		},
+		Post: &Postconditions{Receipts: []*Receipt{{}, {}, {}}},
+	}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := AssertRoundTrip(&tv); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tv.EncodeCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := DecodeCBOR(&buf); err != nil || !reflect.DeepEqual(decoded.ApplyMessages, tv.ApplyMessages) {
+		t.Fatalf("expected messages to survive a cbor round trip, got %v (%v)", decoded, err)
+	}
+
+	tv.ApplyMessages[1].Signature.Type = "ed25519"
+	err := tv.Validate()
+	if err == nil || !strings.Contains(err.Error(), `message at index 1 has signature of unknown type "ed25519"`) {
+		t.Fatalf("expected an unknown signature type error, got: %v", err)
+	}
+
+	tv.ApplyMessages[1].Signature.Type = SignatureBLS
+	tv.ApplyMessages[2].From = &address.Undef
+	err = tv.Validate()
+	if err == nil || !strings.Contains(err.Error(), "message at index 2 has an empty sender override") {
+		t.Fatalf("expected an empty sender error, got: %v", err)
+	}
+}
+
+func TestValidateExitCodes(t *testing.T) {
+	tv := TestVector{
+		Class:         ClassMessage,
+		ApplyMessages: []Message{{}, {}, {}},
+		Post: &Postconditions{Receipts: []*Receipt{
+			{ExitCode: ExitOK},
+			nil,
+			{ExitCode: ExitFirstActorSpecificExitCode + 1},
+		}},
+	}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tv.Post.Receipts[2].ExitCode = -1
+	err := tv.Validate()
+	if err == nil || !strings.Contains(err.Error(), "receipt at index 2 has negative exit code -1") {
+		t.Fatalf("expected a negative exit code error, got: %v", err)
+	}
+
+	tv.Post.Receipts[2].ExitCode = ExitOK
+	tv.Post.Receipts[2].GasUsedTolerance = -1
+	err = tv.Validate()
+	if err == nil || !strings.Contains(err.Error(), "receipt at index 2 has negative gas used tolerance -1") {
+		t.Fatalf("expected a negative tolerance error, got: %v", err)
+	}
+
+	tv.Post.Receipts[2].GasUsedTolerance = 0
+	tv.Post.Receipts[2].ErrorMessage = "not the owner"
+	err = tv.Validate()
+	if err == nil || !strings.Contains(err.Error(), "receipt at index 2 has an error message, but exit code Ok") {
+		t.Fatalf("expected an error message error, got: %v", err)
+	}
+
+	// incorrect vectors may purposely assert impossible exit codes, but their
+	// receipts must still be well-formed.
+	tv.Hints = []Hint{HintIncorrect, HintNegate}
+	err = tv.Validate()
+	if err == nil || !strings.Contains(err.Error(), "receipt at index 2 has an error message, but exit code Ok") {
+		t.Fatalf("expected an error message error, got: %v", err)
+	}
+	tv.Post.Receipts[2].ErrorMessage = ""
+	tv.Post.Receipts[2].ExitCode = -1
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestValidateHints(t *testing.T) {
+	tv := TestVector{Hints: []Hint{HintIncorrect, HintNegate, "x-lotus-slow"}}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !tv.HasHint(HintNegate) || !tv.HasHint("x-lotus-slow") || tv.HasHint("negated") {
+		t.Fatal("unexpected HasHint results")
+	}
+
+	tv.Hints = append(tv.Hints, "negated")
+	err := tv.Validate()
+	if err == nil || !strings.Contains(err.Error(), `unknown hint "negated"`) {
+		t.Fatalf("expected an unknown hint error, got: %v", err)
+	}
+}
+
+func TestValidateReceiptsRoots(t *testing.T) {
+	var (
+		root     = mkCid(t, "receipts")
+		miner, _ = address.NewIDAddress(1000)
+		block    = Block{MinerAddr: miner, WinCount: 1}
+	)
+	computed, err := (&Postconditions{Receipts: []*Receipt{{}, {}}}).ComputeReceiptsRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		tv   TestVector
+		err  string
+	}{
+		{
+			name: "message ok",
+			tv:   TestVector{Class: ClassMessage, ApplyMessages: []Message{{}, {}}, Post: &Postconditions{Receipts: []*Receipt{{}, {}}, ReceiptsRoots: []cid.Cid{computed}}},
+		},
+		{
+			name: "message with a mismatching root",
+			tv:   TestVector{Class: ClassMessage, ApplyMessages: []Message{{}, {}}, Post: &Postconditions{Receipts: []*Receipt{{}, {}}, ReceiptsRoots: []cid.Cid{root}}},
+			err:  "doesn't match the receipts, whose root is " + computed.String(),
+		},
+		{
+			name: "message with a root per receipt",
+			tv:   TestVector{Class: ClassMessage, ApplyMessages: []Message{{}, {}}, Post: &Postconditions{Receipts: []*Receipt{{}, {}}, ReceiptsRoots: []cid.Cid{root, root}}},
+			err:  "single receipts root, got 2",
+		},
+		{
+			name: "tipset ok",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block}}, {Blocks: []Block{block}}}, Post: &Postconditions{ReceiptsRoots: []cid.Cid{root, root}}},
+		},
+		{
+			name: "tipset missing a root",
+			tv:   TestVector{Class: ClassTipset, ApplyTipsets: []Tipset{{Blocks: []Block{block}}, {Blocks: []Block{block}}}, Post: &Postconditions{ReceiptsRoots: []cid.Cid{root}}},
+			err:  "got 1 roots for 2 tipsets",
+		},
+		{
+			name: "events root with events",
+			tv:   TestVector{Class: ClassMessage, ApplyMessages: []Message{{}}, Post: &Postconditions{Receipts: []*Receipt{{Events: []Base64EncodedBytes{{0x80}}, EventsRoot: &root}}}},
+		},
+		{
+			name: "events root without events",
+			tv:   TestVector{Class: ClassMessage, ApplyMessages: []Message{{}}, Post: &Postconditions{Receipts: []*Receipt{{EventsRoot: &root}}}},
+			err:  "receipt at index 0 has an events root, but no events",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.tv.Validate()
+			switch {
+			case c.err == "" && err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			case c.err != "" && err == nil:
+				t.Fatalf("expected error containing %q", c.err)
+			case c.err != "" && !strings.Contains(err.Error(), c.err):
+				t.Fatalf("expected error containing %q, got: %s", c.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateApplyMessageFailures(t *testing.T) {
+	tv := TestVector{
+		Class:         ClassMessage,
+		ApplyMessages: []Message{{}, {}, {}},
+		Post:          &Postconditions{Receipts: []*Receipt{{}, nil, nil}, ApplyMessageFailures: []int{1, 2}},
+	}
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for want, failures := range map[string][]int{
+		"index -1 out of range":                 {-1},
+		"index 3 out of range; vector has 3":    {1, 3},
+		"duplicate apply message failure index": {2, 1, 2},
+	} {
+		tv.Post.ApplyMessageFailures = failures
+		if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error containing %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestValidateCircSupply(t *testing.T) {
+	tv := TestVector{Pre: &Preconditions{}}
+	total := TotalFilecoinAmount()
+	for _, c := range []struct {
+		supply TokenAmount
+		err    string
+	}{
+		{supply: NewTokenAmount(0)},
+		{supply: total},
+		{supply: NewTokenAmount(-1), err: "must not be negative, got -1 attoFIL"},
+		{supply: NewTokenAmountFromBig(new(big.Int).Add(total.BigInt(), big.NewInt(1))), err: "exceeds the total supply of 2000000000000000000000000000 attoFIL"},
+	} {
+		supply := c.supply
+		tv.Pre.CircSupply = &supply
+		err := tv.Validate()
+		switch {
+		case c.err == "" && err != nil:
+			t.Fatalf("unexpected error for supply %s: %s", supply, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Fatalf("expected error containing %q for supply %s, got: %v", c.err, supply, err)
+		}
+	}
+}
+
+func TestValidateBaseFee(t *testing.T) {
+	fee := NewTokenAmount(-100)
+	tv := TestVector{Pre: &Preconditions{BaseFee: &fee}}
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), "base fee must not be negative, got -100 attoFIL") {
+		t.Fatalf("expected a negative base fee error, got: %v", err)
+	}
+
+	fee = NewTokenAmount(0)
+	if err := tv.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestValidateWithOptions(t *testing.T) {
+	supply := NewTokenAmount(-1)
+	tv := TestVector{
+		Hints: []Hint{"bespoke"},
+		Meta:  &Metadata{ID: "options"},
+		Pre:   &Preconditions{CircSupply: &supply, StateTree: &StateTree{RootCID: mkCid(t, "pre")}},
+	}
+
+	lenient := ValidateOptions{AllowLegacyCircSupply: true}
+	if err := tv.ValidateWithOptions(lenient); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := tv.Validate(); err == nil || !strings.Contains(err.Error(), `unknown hint "bespoke"`) {
+		t.Fatalf("expected the default options to reject unknown hints, got: %v", err)
+	}
+
+	for name, c := range map[string]struct {
+		opts ValidateOptions
+		err  string
+	}{
+		"unknown hints":    {opts: ValidateOptions{RejectUnknownHints: true, AllowLegacyCircSupply: true}, err: `unknown hint "bespoke"`},
+		"gen data":         {opts: ValidateOptions{RequireGenData: true, AllowLegacyCircSupply: true}, err: "at least one generation entry"},
+		"circ supply":      {opts: ValidateOptions{}, err: "circulating supply must not be negative"},
+		"car reachability": {opts: ValidateOptions{CheckCARReachability: true, AllowLegacyCircSupply: true}, err: "test vector has no car"},
+	} {
+		if err := tv.ValidateWithOptions(c.opts); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error containing %q, got: %v", name, c.err, err)
+		}
+	}
+
+	tv.CAR, _ = mkCAR(t, []cid.Cid{mkCid(t, "pre")}, "pre")
+	if err := tv.ValidateWithOptions(ValidateOptions{CheckCARReachability: true, AllowLegacyCircSupply: true}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestValidateLogger(t *testing.T) {
+	tv, err := LoadTestVector(strings.NewReader(testMessageVector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	opts := DefaultValidateOptions()
+	opts.Logger = func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	if err := tv.ValidateWithOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"validating test vector test-vector: checking hints",
+		"validating test vector test-vector: checking metadata",
+		"validating test vector test-vector: checking car checksum",
+		"validating test vector test-vector: checking amounts",
+		"validating test vector test-vector: checking root cids",
+		"validating test vector test-vector: checking partial state",
+		"validating test vector test-vector: checking state diff",
+		"validating test vector test-vector: checking custom checks",
+		"validating test vector test-vector: checking message class rules",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected log lines:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	valid, err := LoadTestVector(strings.NewReader(testMessageVector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := ValidateBatch([]*TestVector{valid, valid}); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	invalid := valid.Clone()
+	invalid.Meta.ID = "invalid"
+	invalid.Post.Receipts = nil
+	errs := ValidateBatch([]*TestVector{valid, invalid, nil, invalid})
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got: %v", errs)
+	}
+	if e := errs[0]; e.Index != 1 || e.ID != "invalid" || !strings.Contains(e.Error(), `vector "invalid" at index 1: length of postcondition receipts`) {
+		t.Fatalf("unexpected error: %s", e)
+	}
+	if e := errs[1]; e.Index != 2 || e.ID != "" || !strings.Contains(e.Error(), "nil vector") {
+		t.Fatalf("unexpected error: %s", e)
+	}
+	if errs[2].Index != 3 {
+		t.Fatalf("unexpected error: %s", errs[2])
+	}
+}
+
+func TestValidateRootCIDs(t *testing.T) {
+	root := mkCid(t, "root")
+	tv := TestVector{
+		Pre:  &Preconditions{StateTree: &StateTree{RootCID: root}},
+		Post: &Postconditions{StateTree: &StateTree{RootCID: root}, ReceiptsRoots: []cid.Cid{root}},
+	}
+	if err := tv.validateRootCIDs(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	raw := cid.NewCidV1(cid.Raw, root.Hash())
+	sha, err := cid.V1Builder{Codec: cid.DagCBOR, MhType: multihash.SHA2_256}.Sum([]byte("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		mutate func(*TestVector)
+		err    string
+	}{
+		{func(tv *TestVector) { tv.Pre.StateTree.RootCID = raw }, "preconditions state_tree root_cid has codec raw, expected dag-cbor"},
+		{func(tv *TestVector) { tv.Post.StateTree.RootCID = sha }, "postconditions state_tree root_cid has multihash sha2-256 of 32 bytes, expected blake2b-256 of 32 bytes"},
+		{func(tv *TestVector) { tv.Post.ReceiptsRoots = []cid.Cid{root, raw} }, "postconditions receipts_roots[1] has codec raw"},
+	}
+	for _, c := range cases {
+		v := tv.Clone()
+		c.mutate(v)
+		if err := v.Validate(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got: %v", c.err, err)
+		}
+	}
+}
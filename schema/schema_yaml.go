@@ -0,0 +1,265 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlBase64LineLength is the width at which base64 blobs are wrapped when
+// rendered as YAML block scalars.
+const yamlBase64LineLength = 76
+
+// yamlDecimalInt matches integers that are valid JSON numbers as they are.
+var yamlDecimalInt = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+
+// MarshalToYAML encodes the test vector as a YAML document, for authoring and
+// reviewing vectors by hand. The document has the same structure and field
+// names as the JSON form. Base64EncodedBytes are rendered as literal block
+// scalars wrapped at 76 columns, and OffsetMillis, like every other number,
+// as plain integers.
+//
+// YAML is an authoring format only; vectors are distributed as canonical JSON.
+// See LoadFromYAML for the reverse direction.
+func MarshalToYAML(tv *TestVector) ([]byte, error) {
+	b, err := tv.MarshalJSONDeterministic()
+	if err != nil {
+		return nil, fmt.Errorf("encoding test vector: %w", err)
+	}
+
+	// JSON is valid YAML, so parsing it yields a node tree carrying the JSON
+	// values verbatim (e.g. integers beyond 64 bits). Only the styles, which
+	// are all flow and double-quoted, need rewriting.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("converting test vector to yaml: %w", err)
+	}
+	for _, n := range doc.Content {
+		styleYAML(n, reflect.TypeOf(TestVector{}))
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding yaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding yaml: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadFromYAML decodes a YAML test vector from the supplied reader, as
+// produced by MarshalToYAML or written by hand, and validates it.
+//
+// The YAML document is converted to JSON, and decoded from there, so the
+// rules of the JSON form apply. Integers are carried over verbatim, so big
+// values lose no precision. Whitespace within base64 blobs is ignored, so
+// they can be wrapped freely. Anchors and aliases are expanded.
+func LoadFromYAML(r io.Reader) (*TestVector, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding yaml: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := yamlToJSON(&buf, &doc); err != nil {
+		return nil, fmt.Errorf("converting yaml to json: %w", err)
+	}
+
+	var tv TestVector
+	if err := json.Unmarshal(buf.Bytes(), &tv); err != nil {
+		return nil, fmt.Errorf("decoding test vector: %w", err)
+	}
+	if err := tv.Validate(); err != nil {
+		return nil, fmt.Errorf("validating test vector: %w", err)
+	}
+	return &tv, nil
+}
+
+// ConvertYAMLToJSON reads a YAML test vector from r, validates it, and writes
+// it to w as canonical JSON (see WriteIndented). The conversion is lossless:
+// the output decodes to the same vector the YAML document describes.
+func ConvertYAMLToJSON(r io.Reader, w io.Writer) error {
+	tv, err := LoadFromYAML(r)
+	if err != nil {
+		return err
+	}
+	return WriteIndented(w, tv)
+}
+
+// styleYAML switches the node tree to block style, guided by the Go type the
+// node was encoded from to spot base64 blobs.
+func styleYAML(n *yaml.Node, t reflect.Type) {
+	n.Style = 0
+
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == base64BytesType && n.Kind == yaml.ScalarNode:
+		if n.Value != "" {
+			n.Value = wrapLines(n.Value, yamlBase64LineLength)
+			n.Style = yaml.LiteralStyle
+		}
+		return
+	case t == randomnessRuleType && n.Kind == yaml.SequenceNode:
+		for i, c := range n.Content {
+			var ft reflect.Type
+			if i < t.NumField() {
+				ft = t.Field(i).Type
+			}
+			styleYAML(c, ft)
+		}
+		return
+	case t == cidType, t == tokenAmountType:
+		t = nil // custom representations; nothing to look into.
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			var vt reflect.Type
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				vt = t.Elem()
+			case t.Kind() == reflect.Struct:
+				vt = yamlFieldType(t, n.Content[i].Value)
+			}
+			styleYAML(n.Content[i], nil)
+			styleYAML(n.Content[i+1], vt)
+		}
+	case yaml.SequenceNode:
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		}
+		for _, c := range n.Content {
+			styleYAML(c, et)
+		}
+	}
+}
+
+// yamlFieldType returns the type of the field of struct type t with the given
+// JSON name, or nil if there's none.
+func yamlFieldType(t reflect.Type, name string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if n, _, ok := jsonFieldName(f); ok && n == name {
+			return f.Type
+		}
+	}
+	return nil
+}
+
+func wrapLines(s string, width int) string {
+	var sb strings.Builder
+	for len(s) > width {
+		sb.WriteString(s[:width])
+		sb.WriteByte('\n')
+		s = s[width:]
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+// yamlToJSON writes the JSON equivalent of the YAML node to w.
+func yamlToJSON(w *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) != 1 {
+			return fmt.Errorf("empty yaml document")
+		}
+		return yamlToJSON(w, n.Content[0])
+
+	case yaml.AliasNode:
+		return yamlToJSON(w, n.Alias)
+
+	case yaml.MappingNode:
+		w.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: mapping keys must be scalars", k.Line)
+			}
+			if k.Value == "<<" && k.ShortTag() == "!!merge" {
+				return fmt.Errorf("line %d: merge keys are not supported", k.Line)
+			}
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			key, _ := json.Marshal(k.Value)
+			w.Write(key)
+			w.WriteByte(':')
+			if err := yamlToJSON(w, v); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+		return nil
+
+	case yaml.SequenceNode:
+		w.WriteByte('[')
+		for i, c := range n.Content {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := yamlToJSON(w, c); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+		return nil
+
+	case yaml.ScalarNode:
+		// Integers are written verbatim when possible; YAML resolves ones
+		// too large for 64 bits as floats.
+		if tag := n.ShortTag(); (tag == "!!int" || tag == "!!float") && yamlDecimalInt.MatchString(n.Value) {
+			w.WriteString(n.Value)
+			return nil
+		}
+
+		var v interface{}
+		switch n.ShortTag() {
+		case "!!null":
+			w.WriteString("null")
+			return nil
+		case "!!int":
+			var i int64
+			if err := n.Decode(&i); err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			v = i
+		case "!!bool":
+			var b bool
+			if err := n.Decode(&b); err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			v = b
+		case "!!float":
+			var f float64
+			if err := n.Decode(&f); err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			v = f
+		default:
+			v = n.Value
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		w.Write(b)
+		return nil
+	}
+	return fmt.Errorf("line %d: unexpected yaml node kind %d", n.Line, n.Kind)
+}
@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestYAMLRoundtrip(t *testing.T) {
+	tv, err := LoadTestVector(strings.NewReader(testMessageVector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tv.CAR = bytes.Repeat([]byte("car"), 100)
+	supply := NewTokenAmountFromBig(new(big.Int).Lsh(big.NewInt(1), 80))
+	tv.Pre.CircSupply = &supply
+
+	y, err := MarshalToYAML(tv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"class: message\n", "car: |-\n", "circ_supply: \"1208925819614629174706176\"\n", "exit_code: 7\n"} {
+		if !bytes.Contains(y, []byte(s)) {
+			t.Errorf("expected yaml to contain %q, got:\n%s", s, y)
+		}
+	}
+
+	decoded, err := LoadFromYAML(bytes.NewReader(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.MustMarshalJSON(), tv.MustMarshalJSON()) {
+		t.Fatalf("yaml roundtrip mismatch:\n%s\n%s", decoded.MustMarshalJSON(), tv.MustMarshalJSON())
+	}
+}
+
+func TestMarshalToYAMLOffsetMillis(t *testing.T) {
+	y, err := MarshalToYAML(fullTestVector(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(y, []byte("offset_ms: 1500\n")) {
+		t.Fatalf("expected offset_ms to be rendered as an integer, got:\n%s", y)
+	}
+}
+
+func TestLoadFromYAML(t *testing.T) {
+	const doc = `
+class: message
+_meta: {id: yaml-vector, gen: [{source: test}]}
+car: ""
+preconditions:
+  variants: [{id: genesis, epoch: 0, nv: 0}]
+  state_tree:
+    root_cid: &root {/: bafy2bzacect5q7wezd7b4kgztxijz6kyupmsolvvpkc2lxphpzhx6zgvdanfe}
+  basefee: 340282366920938463463374607431768211456
+apply_messages:
+  - bytes: |
+      igBCAGRCAGQA
+      QgAKCEIAyEIAAQBA
+    epoch_offset: 0x10
+postconditions:
+  state_tree:
+    root_cid: *root
+  receipts: [{exit_code: 0, return: "", gas_used: 0}]
+`
+	tv, err := LoadFromYAML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tv.Pre.BaseFee.String(); got != "340282366920938463463374607431768211456" {
+		t.Errorf("unexpected basefee: %s", got)
+	}
+	if got := tv.ApplyMessages[0].Bytes.String(); got != "igBCAGRCAGQAQgAKCEIAyEIAAQBA" {
+		t.Errorf("unexpected message bytes: %s", got)
+	}
+	if got := *tv.ApplyMessages[0].EpochOffset; got != 16 {
+		t.Errorf("unexpected epoch offset: %d", got)
+	}
+	if !tv.Post.StateTree.RootCID.Equals(tv.Pre.StateTree.RootCID) {
+		t.Errorf("expected alias to resolve to the precondition root")
+	}
+
+	invalid := strings.Replace(doc, "receipts: [{exit_code: 0, return: \"\", gas_used: 0}]", "receipts: []", 1)
+	if _, err := LoadFromYAML(strings.NewReader(invalid)); err == nil || !strings.Contains(err.Error(), "validating test vector") {
+		t.Fatalf("expected validation error, got: %v", err)
+	}
+}
+
+func TestConvertYAMLToJSON(t *testing.T) {
+	b := NewBlockSeqVector(1598306400).WithMeta(Metadata{ID: "yaml", Gen: []GenerationData{{Source: "test"}}})
+	c, err := b.RegisterMessage([]byte("bls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tv, err := b.AddBlock(time.Second, mkBlockMsg(t, []cid.Cid{c}, nil)).WithChainHead(mkCid(t, "head")).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee := NewTokenAmountFromBig(new(big.Int).Lsh(big.NewInt(1), 80))
+	tv.Pre.BaseFee = &fee
+
+	y, err := MarshalToYAML(tv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want bytes.Buffer
+	if err := ConvertYAMLToJSON(bytes.NewReader(y), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndented(&want, tv); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Fatalf("conversion is not lossless; got:\n%s\nwant:\n%s", got.String(), want.String())
+	}
+
+	if err := ConvertYAMLToJSON(strings.NewReader("class: ["), &got); err == nil || !strings.Contains(err.Error(), "decoding yaml") {
+		t.Fatalf("expected a yaml decoding error, got: %v", err)
+	}
+}